@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"examples/bloggy/pkg/site"
+	"examples/bloggy/pkg/storage"
+)
+
+// openStore opens the store posts are read from. Stores that do not exist
+// are not created, which would export an empty site
+func openStore(ctx context.Context, backend string, dataDir string, sqlitePath string) (storage.Storage, error) {
+	switch backend {
+	case "memory":
+		if dataDir == "" {
+			return nil, errors.New("the memory store needs -data to read posts from")
+		}
+
+		// The log is left as it is, the server must not have it open
+		store, err := storage.CreateReadOnlyMemoryStore(dataDir)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no memory store in %s: %w", dataDir, err)
+		}
+
+		return store, err
+	case "mongo":
+		return storage.CreateMongoStore(ctx, "test", "test")
+	case "sqlite":
+		_, err := os.Stat(sqlitePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return storage.CreateSQLiteStore(ctx, sqlitePath)
+	}
+
+	return nil, fmt.Errorf("unknown store %q", backend)
+}
+
+func run() error {
+	out := flag.String("out", "public", "directory to write the site to, pages of an earlier export to it are only rewritten if they changed")
+	backend := flag.String("store", "memory", "store to read posts from, one of memory, mongo and sqlite")
+	dataDir := flag.String("data", "", "directory the memory store persists posts in, it can not be exported while a server has it open")
+	sqlitePath := flag.String("sqlite", "bloggy.db", "database file of the SQLite store")
+	timeout := flag.Duration("timeout", 5*time.Minute, "time after which the export is given up")
+	themeDir := flag.String("theme", "", "directory of templates overriding those of the default theme")
+	siteTitle := flag.String("site-title", "Bloggy", "title of the site")
+	siteURL := flag.String("site-url", "", "absolute URL the site is served at, feeds, sitemaps and robots.txt are only written if set")
+	feedLimit := flag.Int("feed-limit", site.DefaultFeedLimit, "number of newest posts feeds hold")
+	feedSummary := flag.Bool("feed-summary", false, "have feeds hold summaries of posts rather than their full content")
+	robotsFile := flag.String("robots", "", "file of the rules of robots.txt of the site, crawlers are only kept out of the API if empty")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	store, err := openStore(ctx, *backend, *dataDir, *sqlitePath)
+	if err != nil {
+		return err
+	}
+
+	defer store.Disconnect(context.Background())
+
+	theme, err := site.LoadTheme(*themeDir)
+	if err != nil {
+		return err
+	}
+
+	robots, err := site.LoadRobots(*robotsFile)
+	if err != nil {
+		return err
+	}
+
+	// Write the site, skipping pages that did not change since the last export
+	opts := site.Options{Title: *siteTitle, URL: *siteURL, FeedLimit: *feedLimit, FeedSummary: *feedSummary, Robots: robots}
+
+	result, err := site.New(store, theme, opts).Export(ctx, *out)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Exported to %s: %d pages written, %d unchanged, %d removed\n", *out, result.Written, result.Unchanged, result.Removed)
+
+	return nil
+}
+
+func main() {
+	err := run()
+
+	if err != nil {
+		log.Fatalf("ERROR Unable to export site: %s\n", err)
+	}
+}
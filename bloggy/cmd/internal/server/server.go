@@ -0,0 +1,112 @@
+// Package server serves the API and the public site of a store. It holds
+// the flags and setup shared by the servers of each storage backend
+package server
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"examples/bloggy/pkg/auth"
+	v1 "examples/bloggy/pkg/routes/v1"
+	"examples/bloggy/pkg/scheduler"
+	"examples/bloggy/pkg/site"
+	"examples/bloggy/pkg/storage"
+)
+
+// Options configure the server, they are filled in by the flags added by
+// Flags once the command line is parsed
+type Options struct {
+	TrashRetention time.Duration
+	PurgeEvery     time.Duration
+	ScheduleEvery  time.Duration
+	APIKeys        string
+	JWTHMACKey     string
+	JWTRSAKey      string
+	PrivateReads   bool
+	ThemeDir       string
+	SiteTitle      string
+	SiteURL        string
+	FeedLimit      int
+	FeedSummary    bool
+	RobotsFile     string
+}
+
+// Flags adds the flags of the server to the command line. Backends add
+// their own flags before calling flag.Parse
+func Flags() *Options {
+	opts := &Options{}
+
+	flag.DurationVar(&opts.TrashRetention, "trash-retention", 30*24*time.Hour, "how long removed posts are kept in the trash before they are purged, zero keeps them until purged by hand")
+	flag.DurationVar(&opts.PurgeEvery, "purge-every", time.Hour, "interval at which the trash is checked for posts to purge")
+	flag.DurationVar(&opts.ScheduleEvery, "schedule-every", time.Minute, "interval at which new schedules are picked up, scheduled posts are published when due")
+	flag.StringVar(&opts.APIKeys, "api-keys", "", "file of API keys allowed to write, one key, subject and role per line")
+	flag.StringVar(&opts.JWTHMACKey, "jwt-hmac-key", "", "file holding the secret of HMAC signed JWT bearer tokens allowed to write")
+	flag.StringVar(&opts.JWTRSAKey, "jwt-rsa-key", "", "PEM file holding the public key of RSA signed JWT bearer tokens allowed to write")
+	flag.BoolVar(&opts.PrivateReads, "private-reads", false, "require credentials for reads of the API, the public site and its feeds too, only writes do if not set")
+	flag.StringVar(&opts.ThemeDir, "theme", "", "directory of templates overriding those of the default theme of the public site")
+	flag.StringVar(&opts.SiteTitle, "site-title", "Bloggy", "title of the public site")
+	flag.StringVar(&opts.SiteURL, "site-url", "", "absolute URL the public site is served at, feeds link to the URL of each request if empty")
+	flag.IntVar(&opts.FeedLimit, "feed-limit", site.DefaultFeedLimit, "number of newest posts feeds hold unless readers ask for fewer or more")
+	flag.BoolVar(&opts.FeedSummary, "feed-summary", false, "have feeds hold summaries of posts unless readers ask for their full content")
+	flag.StringVar(&opts.RobotsFile, "robots", "", "file of the rules of robots.txt of the public site, crawlers are only kept out of the API if empty")
+
+	return opts
+}
+
+// Run purges the trash, publishes scheduled posts and serves the API and
+// the public site of the stores until serving fails
+func Run(opts *Options, store storage.Storage, authors storage.AuthorStorage, comments storage.CommentStorage) error {
+	// Authenticate requests if credentials are configured
+	authenticator, err := auth.Load(opts.APIKeys, opts.JWTHMACKey, opts.JWTRSAKey)
+	if err != nil {
+		return err
+	}
+
+	var middleware, siteMiddleware []gin.HandlerFunc
+
+	if authenticator != nil {
+		public := auth.PublicReads
+
+		if opts.PrivateReads {
+			public = nil
+		}
+
+		middleware = append(middleware, auth.Middleware(authenticator, public...))
+
+		// The site only reads, it is left public unless reads are private
+		if opts.PrivateReads {
+			siteMiddleware = middleware
+		}
+	}
+
+	// Load the theme and the robots.txt rules of the public site
+	theme, err := site.LoadTheme(opts.ThemeDir)
+	if err != nil {
+		return err
+	}
+
+	robots, err := site.LoadRobots(opts.RobotsFile)
+	if err != nil {
+		return err
+	}
+
+	siteOpts := site.Options{Title: opts.SiteTitle, URL: opts.SiteURL, FeedLimit: opts.FeedLimit, FeedSummary: opts.FeedSummary, Robots: robots}
+
+	// Purge posts kept in the trash for longer than retention
+	if opts.TrashRetention > 0 {
+		go storage.PurgeTrash(context.Background(), store, comments, opts.TrashRetention, opts.PurgeEvery)
+	}
+
+	// Publish scheduled posts when they are due
+	go scheduler.New(store, scheduler.SystemClock, opts.ScheduleEvery).Run(context.Background())
+
+	// Create routes and start serving
+	router := gin.Default()
+	v1.CreateRoutes(store, authors, comments, router, middleware...)
+	site.New(store, theme, siteOpts).CreateRoutes(router, siteMiddleware...)
+
+	return router.Run()
+}
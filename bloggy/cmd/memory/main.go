@@ -1,23 +1,45 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"path/filepath"
 
-	"github.com/gin-gonic/gin"
-
-	v1 "examples/bloggy/pkg/routes/v1"
+	"examples/bloggy/cmd/internal/server"
 	"examples/bloggy/pkg/storage"
 )
 
 func run() error {
-	store := storage.CreateMemoryStore()
-
-	router := gin.Default()
-	v1.CreateRoutes(store, router)
+	opts := server.Flags()
+	dataDir := flag.String("data", "", "directory to persist posts, authors and comments in, they are kept only in memory if empty")
+	compactEvery := flag.Int("compact-every", 1000, "number of logged changes after which the log is compacted into a snapshot")
+	flag.Parse()
 
-	router.Run()
-
-	return nil
+	store := storage.CreateMemoryStore()
+	authors := storage.CreateMemoryAuthorStore()
+	comments := storage.CreateMemoryCommentStore()
+
+	if *dataDir != "" {
+		var err error
+
+		store, err = storage.CreateDurableMemoryStore(*dataDir, *compactEvery)
+		if err != nil {
+			return err
+		}
+
+		// Authors and comments are logged apart from posts
+		authors, err = storage.CreateDurableMemoryAuthorStore(filepath.Join(*dataDir, "authors"), *compactEvery)
+		if err != nil {
+			return err
+		}
+
+		comments, err = storage.CreateDurableMemoryCommentStore(filepath.Join(*dataDir, "comments"), *compactEvery)
+		if err != nil {
+			return err
+		}
+	}
+
+	return server.Run(opts, store, authors, comments)
 }
 
 func main() {
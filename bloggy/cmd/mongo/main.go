@@ -2,16 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"time"
 
-	"github.com/gin-gonic/gin"
-
-	v1 "examples/bloggy/pkg/routes/v1"
+	"examples/bloggy/cmd/internal/server"
 	"examples/bloggy/pkg/storage"
 )
 
 func run() error {
+	opts := server.Flags()
+	flag.Parse()
+
 	// Create Mongo store
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -21,13 +23,17 @@ func run() error {
 		return err
 	}
 
-	// Create routes and start serving
-	router := gin.Default()
-	v1.CreateRoutes(store, router)
+	authors, err := storage.CreateMongoAuthorStore(ctx, "test", "test_authors")
+	if err != nil {
+		return err
+	}
 
-	router.Run()
+	comments, err := storage.CreateMongoCommentStore(ctx, "test", "test_comments")
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return server.Run(opts, store, authors, comments)
 }
 
 func main() {
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"examples/bloggy/cmd/internal/server"
+	"examples/bloggy/pkg/storage"
+)
+
+func run() error {
+	opts := server.Flags()
+	flag.Parse()
+
+	// Create SQLite store
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store, err := storage.CreateSQLiteStore(ctx, "bloggy.db")
+	if err != nil {
+		return err
+	}
+
+	authors, err := storage.CreateSQLiteAuthorStore(ctx, "bloggy-authors.db")
+	if err != nil {
+		return err
+	}
+
+	comments, err := storage.CreateSQLiteCommentStore(ctx, "bloggy-comments.db")
+	if err != nil {
+		return err
+	}
+
+	return server.Run(opts, store, authors, comments)
+}
+
+func main() {
+	log.Fatalf(run().Error())
+}
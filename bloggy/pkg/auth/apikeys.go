@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"examples/bloggy/pkg/models"
+)
+
+// APIKeyHeader is the header API keys are sent in
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by static API keys. Keys are kept hashed
+// so that looking them up takes the same time whatever the key sent
+type APIKeys struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// NewAPIKeys creates an authenticator accepting the keys of principals,
+// which maps each key to the principal it is issued to
+func NewAPIKeys(principals map[string]Principal) *APIKeys {
+	keys := map[[sha256.Size]byte]Principal{}
+
+	for key, principal := range principals {
+		keys[sha256.Sum256([]byte(key))] = principal
+	}
+
+	return &APIKeys{keys}
+}
+
+// LoadAPIKeys reads API keys from the file at path, which holds a key, the
+// subject it is issued to and optionally their role per line separated by
+// whitespace. Blank lines and lines starting with # are skipped
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	principals := map[string]Principal{}
+	scanner := bufio.NewScanner(file)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("auth: %s:%d: expected a key, a subject and a role", path, n)
+		}
+
+		principal := Principal{Subject: fields[1], Role: models.RoleReader}
+
+		if len(fields) == 3 {
+			principal.Role = models.Role(fields[2])
+		}
+
+		if !principal.Role.Valid() {
+			return nil, fmt.Errorf("auth: %s:%d: invalid role %s", path, n, principal.Role)
+		}
+
+		principals[fields[0]] = principal
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAPIKeys(principals), nil
+}
+
+func (k *APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	principal, ok := k.keys[sha256.Sum256([]byte(key))]
+
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	return principal, nil
+}
@@ -0,0 +1,77 @@
+// Package auth authenticates requests by static API keys or signed JWT
+// bearer tokens
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"examples/bloggy/pkg/models"
+)
+
+var ErrNoCredentials = errors.New("auth: missing credentials")
+var ErrInvalidCredentials = errors.New("auth: invalid credentials")
+var ErrForbidden = errors.New("auth: forbidden")
+
+// Principal is who a request is authenticated as. Subject is the subject of
+// the token or the one the API key was issued to, the ID of their author
+// profile for those who write posts. Credentials without a role are readers
+type Principal struct {
+	Subject string      `json:"subject"`
+	Role    models.Role `json:"role"`
+}
+
+// Authenticator checks the credentials carried by requests. Authenticate
+// returns ErrNoCredentials if a request carries none of the kind the
+// authenticator understands and ErrInvalidCredentials if they do not check
+// out, whatever the reason
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Chain tries its authenticators in order, the first one finding credentials
+// in the request decides
+type Chain []Authenticator
+
+func (chain Chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range chain {
+		principal, err := a.Authenticate(r)
+
+		if err != ErrNoCredentials {
+			return principal, err
+		}
+	}
+
+	return Principal{}, ErrNoCredentials
+}
+
+// Load creates an authenticator from the API keys file and JWT key files at
+// the given paths, empty paths are skipped. Returns nil if all are empty,
+// requests are then not authenticated
+func Load(apiKeysPath string, hmacKeyPath string, rsaKeyPath string) (Authenticator, error) {
+	var chain Chain
+
+	if apiKeysPath != "" {
+		keys, err := LoadAPIKeys(apiKeysPath)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, keys)
+	}
+
+	if hmacKeyPath != "" || rsaKeyPath != "" {
+		verifier, err := LoadJWT(hmacKeyPath, rsaKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, verifier)
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+
+	"examples/bloggy/pkg/models"
+)
+
+var testHMACKey = []byte("0123456789abcdef0123456789abcdef")
+
+func writeFile(t *testing.T, name string, content []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	err := os.WriteFile(path, content, 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// publicKeyPEM encodes the public part of key as a PEM file would hold it
+func publicKeyPEM(t *testing.T, key *rsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return r
+}
+
+func assertAuthenticated(t *testing.T, a Authenticator, r *http.Request, subject string) {
+	t.Helper()
+
+	assertPrincipal(t, a, r, Principal{Subject: subject, Role: models.RoleReader})
+}
+
+func assertPrincipal(t *testing.T, a Authenticator, r *http.Request, expected Principal) {
+	t.Helper()
+
+	principal, err := a.Authenticate(r)
+
+	if err != nil {
+		t.Errorf("Expected %s to be authenticated but got %s", expected.Subject, err)
+		return
+	}
+
+	if principal != expected {
+		t.Errorf("Expected principal to be %+v but got %+v", expected, principal)
+	}
+}
+
+func assertRejected(t *testing.T, a Authenticator, r *http.Request, expected error) {
+	t.Helper()
+
+	_, err := a.Authenticate(r)
+
+	if err != expected {
+		t.Errorf("Expected error to be %v but got %v", expected, err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	path := writeFile(t, "keys", []byte("# Editors\nsecret-one vishnu editor\n\n  secret-two   shankar  \n"))
+
+	keys, err := LoadAPIKeys(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/", nil)
+	assertRejected(t, keys, r, ErrNoCredentials)
+
+	r.Header.Set(APIKeyHeader, "secret-two")
+	assertAuthenticated(t, keys, r, "shankar")
+
+	r.Header.Set(APIKeyHeader, "secret-one")
+	assertPrincipal(t, keys, r, Principal{Subject: "vishnu", Role: models.RoleEditor})
+
+	r.Header.Set(APIKeyHeader, "secret")
+	assertRejected(t, keys, r, ErrInvalidCredentials)
+
+	_, err = LoadAPIKeys(writeFile(t, "keys", []byte("secret-one\n")))
+
+	if err == nil {
+		t.Error("Expected a line without subject to be rejected")
+	}
+
+	_, err = LoadAPIKeys(writeFile(t, "keys", []byte("secret-one vishnu owner\n")))
+
+	if err == nil {
+		t.Error("Expected a line with an unknown role to be rejected")
+	}
+}
+
+func TestJWTHMAC(t *testing.T) {
+	verifier, err := LoadJWT(writeFile(t, "secret", append(testHMACKey, '\n')), "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRejected(t, verifier, httptest.NewRequest("GET", "/", nil), ErrNoCredentials)
+
+	valid := jwt.RegisteredClaims{Subject: "vishnu", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS256, jwt.SigningMethodHS384, jwt.SigningMethodHS512} {
+		assertAuthenticated(t, verifier, bearer(sign(t, method, testHMACKey, valid)), "vishnu")
+	}
+
+	expired := jwt.RegisteredClaims{Subject: "vishnu", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}
+	notYet := jwt.RegisteredClaims{Subject: "vishnu", NotBefore: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	tokens := []string{
+		"not a token",
+		sign(t, jwt.SigningMethodHS256, testHMACKey, expired),
+		sign(t, jwt.SigningMethodHS256, testHMACKey, notYet),
+		sign(t, jwt.SigningMethodHS256, testHMACKey, jwt.RegisteredClaims{}),
+		sign(t, jwt.SigningMethodHS256, []byte("another secret of thirty-two bytes"), valid),
+		sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid),
+		// RSA tokens are not accepted without an RSA key
+		sign(t, jwt.SigningMethodRS256, generateRSAKey(t), valid),
+	}
+
+	for _, token := range tokens {
+		assertRejected(t, verifier, bearer(token), ErrInvalidCredentials)
+	}
+
+	claims := jwtClaims{RegisteredClaims: valid, Role: models.RoleAuthor}
+	assertPrincipal(t, verifier, bearer(sign(t, jwt.SigningMethodHS256, testHMACKey, claims)), Principal{Subject: "vishnu", Role: models.RoleAuthor})
+
+	claims.Role = "owner"
+	assertRejected(t, verifier, bearer(sign(t, jwt.SigningMethodHS256, testHMACKey, claims)), ErrInvalidCredentials)
+
+	_, err = NewJWT([]byte("short"), nil)
+
+	if err != errHMACKeyTooShort {
+		t.Errorf("Expected error to be %v but got %v", errHMACKeyTooShort, err)
+	}
+}
+
+func TestJWTRSA(t *testing.T) {
+	key := generateRSAKey(t)
+	keyPEM := publicKeyPEM(t, key)
+
+	verifier, err := LoadJWT("", writeFile(t, "key.pem", keyPEM))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := jwt.RegisteredClaims{Subject: "shankar"}
+
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512} {
+		assertAuthenticated(t, verifier, bearer(sign(t, method, key, valid)), "shankar")
+	}
+
+	tokens := []string{
+		sign(t, jwt.SigningMethodRS256, generateRSAKey(t), valid),
+		// Tokens signed with the public key as HMAC secret must not pass
+		sign(t, jwt.SigningMethodHS256, keyPEM, valid),
+	}
+
+	for _, token := range tokens {
+		assertRejected(t, verifier, bearer(token), ErrInvalidCredentials)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	a, err := Load("", "", "")
+
+	if a != nil || err != nil {
+		t.Errorf("Expected no authenticator but got %v, %v", a, err)
+	}
+
+	a, err = Load(writeFile(t, "keys", []byte("secret vishnu\n")), writeFile(t, "secret", testHMACKey), "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set(APIKeyHeader, "secret")
+	assertAuthenticated(t, a, r, "vishnu")
+
+	r = bearer(sign(t, jwt.SigningMethodHS256, testHMACKey, jwt.RegisteredClaims{Subject: "shankar"}))
+	assertAuthenticated(t, a, r, "shankar")
+
+	assertRejected(t, a, httptest.NewRequest("POST", "/", nil), ErrNoCredentials)
+
+	_, err = Load("", filepath.Join(t.TempDir(), "missing"), "")
+
+	if err == nil {
+		t.Error("Expected a missing key file to be reported")
+	}
+}
+
+func getRouter(a Authenticator, public ...string) *gin.Engine {
+	router := gin.New()
+	router.Use(Middleware(a, public...))
+
+	handler := func(c *gin.Context) {
+		principal, _ := PrincipalOf(c)
+		c.String(http.StatusOK, "%s", principal.Subject)
+	}
+
+	router.GET("/posts", handler)
+	router.POST("/posts", handler)
+
+	return router
+}
+
+func serve(router *gin.Engine, method string, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/posts", nil)
+
+	if key != "" {
+		r.Header.Set(APIKeyHeader, key)
+	}
+
+	router.ServeHTTP(w, r)
+
+	return w
+}
+
+func assertResponse(t *testing.T, w *httptest.ResponseRecorder, status int, body string) {
+	t.Helper()
+
+	if w.Code != status || w.Body.String() != body {
+		t.Errorf("Expected %d %q but got %d %q", status, body, w.Code, w.Body.String())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := NewAPIKeys(map[string]Principal{"secret": {Subject: "vishnu"}})
+	router := getRouter(keys, PublicReads...)
+
+	assertResponse(t, serve(router, "GET", ""), 200, "")
+	assertResponse(t, serve(router, "GET", "secret"), 200, "vishnu")
+	assertResponse(t, serve(router, "POST", "secret"), 200, "vishnu")
+
+	// Every rejection looks the same whatever the reason
+	for _, w := range []*httptest.ResponseRecorder{serve(router, "POST", ""), serve(router, "POST", "wrong"), serve(router, "GET", "wrong")} {
+		if w.Code != 401 || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected unauthorized with a challenge but got %d %v", w.Code, w.Header())
+		}
+	}
+
+	assertResponse(t, serve(router, "POST", ""), 401, "auth: missing credentials")
+	assertResponse(t, serve(router, "POST", "wrong"), 401, "auth: invalid credentials")
+
+	// Reads can require credentials too
+	router = getRouter(keys)
+
+	assertResponse(t, serve(router, "GET", ""), 401, "auth: missing credentials")
+	assertResponse(t, serve(router, "GET", "secret"), 200, "vishnu")
+}
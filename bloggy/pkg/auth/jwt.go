@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"examples/bloggy/pkg/models"
+)
+
+// MinHMACKeySize is the smallest HMAC secret accepted, shorter secrets are
+// open to brute force
+const MinHMACKeySize = 32
+
+var errHMACKeyTooShort = errors.New("auth: HMAC key is too short")
+
+// JWT authenticates requests by signed bearer tokens in the Authorization
+// header. Tokens are signed with HMAC (HS256, HS384, HS512) or RSA (RS256,
+// RS384, RS512), only algorithms of the configured keys are accepted. The
+// expiry and not before claims are checked if present, the subject claim is
+// required and the role claim optional
+type JWT struct {
+	hmacKey []byte
+	rsaKey  *rsa.PublicKey
+	methods []string
+}
+
+// NewJWT creates a verifier of tokens signed with hmacKey or by the private
+// key of rsaKey, either may be nil
+func NewJWT(hmacKey []byte, rsaKey *rsa.PublicKey) (*JWT, error) {
+	v := &JWT{hmacKey: hmacKey, rsaKey: rsaKey}
+
+	if hmacKey != nil {
+		if len(hmacKey) < MinHMACKeySize {
+			return nil, errHMACKeyTooShort
+		}
+
+		v.methods = append(v.methods, "HS256", "HS384", "HS512")
+	}
+
+	if rsaKey != nil {
+		v.methods = append(v.methods, "RS256", "RS384", "RS512")
+	}
+
+	return v, nil
+}
+
+// LoadJWT creates a verifier with the HMAC secret in the file at hmacPath
+// and the PEM encoded RSA public key in the file at rsaPath, empty paths are
+// skipped. Surrounding whitespace is trimmed from the secret
+func LoadJWT(hmacPath string, rsaPath string) (*JWT, error) {
+	var hmacKey []byte
+	var rsaKey *rsa.PublicKey
+
+	if hmacPath != "" {
+		raw, err := os.ReadFile(hmacPath)
+		if err != nil {
+			return nil, err
+		}
+
+		hmacKey = bytes.TrimSpace(raw)
+	}
+
+	if rsaPath != "" {
+		raw, err := os.ReadFile(rsaPath)
+		if err != nil {
+			return nil, err
+		}
+
+		rsaKey, err = jwt.ParseRSAPublicKeyFromPEM(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewJWT(hmacKey, rsaKey)
+}
+
+// jwtClaims are the claims of tokens, the registered ones along with the
+// role of the subject
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Role models.Role `json:"role,omitempty"`
+}
+
+// key returns the key token is verified with. The parser has already
+// checked that the algorithm is one of the configured keys
+func (v *JWT) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.hmacKey, nil
+	case *jwt.SigningMethodRSA:
+		return v.rsaKey, nil
+	}
+
+	return nil, ErrInvalidCredentials
+}
+
+func (v *JWT) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	if !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+
+	var claims jwtClaims
+
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), &claims, v.key, jwt.WithValidMethods(v.methods))
+
+	if err != nil || claims.Subject == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	if claims.Role == "" {
+		claims.Role = models.RoleReader
+	}
+
+	if !claims.Role.Valid() {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	return Principal{Subject: claims.Subject, Role: claims.Role}, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key the authenticated principal is kept
+// under
+const principalKey = "auth.principal"
+
+// enabledKey is the gin context key marking requests that went through the
+// middleware, with or without a principal
+const enabledKey = "auth.enabled"
+
+// PublicReads are the methods of requests that only read, which are served
+// without credentials by default
+var PublicReads = []string{http.MethodGet, http.MethodHead}
+
+// Middleware authenticates requests with a. Requests without credentials
+// are only served if their method is one of public, those with credentials
+// that do not check out never are. Rejected requests get unauthorized with
+// the authentication error as body
+func Middleware(a Authenticator, public ...string) gin.HandlerFunc {
+	publicMethods := map[string]bool{}
+
+	for _, method := range public {
+		publicMethods[method] = true
+	}
+
+	return func(c *gin.Context) {
+		c.Set(enabledKey, true)
+
+		principal, err := a.Authenticate(c.Request)
+
+		if err == ErrNoCredentials && publicMethods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		if err != nil {
+			Unauthorized(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// PrincipalOf returns the principal the request of c is authenticated as,
+// false if it is not authenticated
+func PrincipalOf(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+
+	if !ok {
+		return Principal{}, false
+	}
+
+	principal, ok := value.(Principal)
+
+	return principal, ok
+}
+
+// Enabled reports whether the request of c went through the middleware, so
+// that a request without a principal is anonymous rather than served with
+// authentication disabled
+func Enabled(c *gin.Context) bool {
+	return c.GetBool(enabledKey)
+}
+
+// Unauthorized responds with unauthorized, challenging the client to
+// authenticate, with err as body
+func Unauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="bloggy"`)
+	c.String(http.StatusUnauthorized, "%s", err.Error())
+}
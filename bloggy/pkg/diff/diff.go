@@ -0,0 +1,118 @@
+// Package diff computes line based differences between texts using the
+// Myers algorithm
+package diff
+
+import "strings"
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Line is a line of either text, Equal lines are in both
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Text returns the shortest edit script turning text a into text b
+func Text(a string, b string) []Line {
+	return Lines(strings.Split(a, "\n"), strings.Split(b, "\n"))
+}
+
+// Lines returns the shortest edit script turning lines a into lines b
+func Lines(a []string, b []string) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+
+	// v holds the furthest x reached on each diagonal k = x - y, trace keeps
+	// diagonals -d-1 to d+1 of v as they were before each edit distance d
+	// was explored, the others are not read when backtracking from d. The
+	// trace grows with the square of the edit distance rather than of the
+	// length of the texts
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack follows the trace of Lines from the end of both texts back to
+// their start
+func backtrack(a []string, b []string, trace [][]int) []Line {
+	var lines []Line
+
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+
+		var prevK int
+
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, Line{Equal, a[x-1]})
+			x--
+			y--
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			lines = append(lines, Line{Insert, b[y-1]})
+		} else {
+			lines = append(lines, Line{Delete, a[x-1]})
+		}
+
+		x, y = prevX, prevY
+	}
+
+	// Lines were collected from the end
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return lines
+}
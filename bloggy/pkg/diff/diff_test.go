@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func assertLines(t *testing.T, lines []Line, expectedLines []Line) {
+	if !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("Expected %v but got %v", expectedLines, lines)
+	}
+}
+
+func TestTextEqual(t *testing.T) {
+	lines := Text("Hello\nworld", "Hello\nworld")
+
+	assertLines(t, lines, []Line{{Equal, "Hello"}, {Equal, "world"}})
+}
+
+func TestTextInsert(t *testing.T) {
+	lines := Text("Hello\nworld", "Hello\ngolang\nworld")
+
+	assertLines(t, lines, []Line{{Equal, "Hello"}, {Insert, "golang"}, {Equal, "world"}})
+}
+
+func TestTextDelete(t *testing.T) {
+	lines := Text("Hello\ngolang\nworld", "Hello\nworld")
+
+	assertLines(t, lines, []Line{{Equal, "Hello"}, {Delete, "golang"}, {Equal, "world"}})
+}
+
+func TestLinesShortest(t *testing.T) {
+	from := []string{"a", "b", "c", "a", "b", "b", "a"}
+	to := []string{"c", "b", "a", "b", "a", "c"}
+
+	lines := Lines(from, to)
+
+	// Shortest edit script has 5 edits
+	edits := 0
+
+	var a, b []string
+
+	for _, line := range lines {
+		if line.Op != Equal {
+			edits++
+		}
+
+		if line.Op != Insert {
+			a = append(a, line.Text)
+		}
+
+		if line.Op != Delete {
+			b = append(b, line.Text)
+		}
+	}
+
+	if edits != 5 {
+		t.Errorf("Expected 5 edits but got %d in %v", edits, lines)
+	}
+
+	// Script applies to the texts it was made from
+	if !reflect.DeepEqual(a, from) || !reflect.DeepEqual(b, to) {
+		t.Errorf("Expected script from %v to %v but got %v", from, to, lines)
+	}
+}
+
+func TestLinesEmpty(t *testing.T) {
+	lines := Lines(nil, []string{"Hello"})
+
+	assertLines(t, lines, []Line{{Insert, "Hello"}})
+
+	lines = Lines([]string{"Hello"}, nil)
+
+	assertLines(t, lines, []Line{{Delete, "Hello"}})
+}
+
+func TestLinesLongTexts(t *testing.T) {
+	const n = 100000
+	const edits = 1000
+
+	from := make([]string, n)
+	to := make([]string, n)
+
+	for i := range from {
+		from[i] = fmt.Sprint(i)
+		to[i] = from[i]
+
+		if i%(n/edits) == 0 {
+			to[i] = "changed"
+		}
+	}
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+	lines := Lines(from, to)
+	runtime.ReadMemStats(&after)
+
+	// Only the diagonals within the edit distance are traced
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 256<<20 {
+		t.Errorf("Expected less than 256MB to be allocated but got %dMB", allocated>>20)
+	}
+
+	if len(lines) != n+edits {
+		t.Errorf("Expected %d lines but got %d", n+edits, len(lines))
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Author is the profile of a user who writes posts. ID and timestamps are
+// managed by the storage backends, values sent by clients are ignored.
+// Email is left out of the profiles anyone may read
+type Author struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Email     string             `json:"email,omitempty" bson:"email"`
+	Bio       string             `json:"bio" bson:"bio"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
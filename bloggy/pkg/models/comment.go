@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CommentStatus is the stage of a comment in moderation. Comments wait for
+// moderation as pending and are only public once approved
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentRejected CommentStatus = "rejected"
+)
+
+// Valid reports whether s is one of the moderation statuses
+func (s CommentStatus) Valid() bool {
+	return s == CommentPending || s == CommentApproved || s == CommentRejected
+}
+
+// Comment is left by a reader on the post with PostID. Replies link to the
+// comment they answer by ParentID, which is zero for top level comments.
+// ID, status and timestamps are managed by the storage backends, values sent
+// by clients are ignored
+type Comment struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PostID    primitive.ObjectID `json:"postId" bson:"postId"`
+	ParentID  primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Content   string             `json:"content" bson:"content"`
+	Status    CommentStatus      `json:"status" bson:"status"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -1,17 +1,54 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Post ID, status, timestamps and version are managed by the storage
+// backends, values sent by clients are ignored. Slug is the unique, URL-safe
+// key of the post, generated from the free-form title unless given. Version
+// starts at 1 and is incremented by every modification. DeletedAt is set
+// while the post is in the trash. Tags and Category are stored lowercase,
+// tags sorted and without duplicates. PublishedAt is set when the post is
+// last published, PublishAt while it is scheduled to be. AuthorID links the
+// post to the profile of its author, Name is then the name of the author
+// when the post was last written
 type Post struct {
-	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Name    string             `json:"name" bson:"name"`
-	Title   string             `json:"title" bson:"title"`
-	Content string             `json:"content" bson:"content"`
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Slug        string             `json:"slug" bson:"slug"`
+	AuthorID    primitive.ObjectID `json:"authorId,omitempty" bson:"authorId,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Title       string             `json:"title" bson:"title"`
+	Content     string             `json:"content" bson:"content"`
+	Tags        []string           `json:"tags" bson:"tags"`
+	Category    string             `json:"category" bson:"category"`
+	Status      Status             `json:"status" bson:"status"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+	PublishedAt *time.Time         `json:"publishedAt,omitempty" bson:"publishedAt,omitempty"`
+	PublishAt   *time.Time         `json:"publishAt,omitempty" bson:"publishAt,omitempty"`
+	DeletedAt   *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	Version     int64              `json:"version" bson:"version"`
 }
 
+// IsEqual compares the fields set by clients, ID, slug, timestamps and
+// version are ignored. No tags and an empty list of tags are equal
 func (LHS Post) IsEqual(RHS Post) bool {
-	LHS.ID = RHS.ID
-	return LHS == RHS
+	if len(LHS.Tags) != len(RHS.Tags) {
+		return false
+	}
+
+	for i := range LHS.Tags {
+		if LHS.Tags[i] != RHS.Tags[i] {
+			return false
+		}
+	}
+
+	return LHS.AuthorID == RHS.AuthorID &&
+		LHS.Name == RHS.Name &&
+		LHS.Title == RHS.Title &&
+		LHS.Content == RHS.Content &&
+		LHS.Category == RHS.Category
 }
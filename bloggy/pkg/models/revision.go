@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Revision is an immutable copy of a post as it was stored by an insert or
+// modify. Revisions of a post are numbered from 1 in the order they were made
+type Revision struct {
+	PostID    primitive.ObjectID `json:"postId" bson:"postId"`
+	Number    int                `json:"number" bson:"number"`
+	Author    string             `json:"author" bson:"author"`
+	Title     string             `json:"title" bson:"title"`
+	Content   string             `json:"content" bson:"content"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Role is what an authenticated user may do. Readers only read, authors
+// write and submit their own posts, editors edit, publish and archive any
+// post and admins also manage author profiles. Each role may do everything
+// the roles before it may
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleAuthor Role = "author"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleReader: 1,
+	RoleAuthor: 2,
+	RoleEditor: 3,
+	RoleAdmin:  4,
+}
+
+// Valid reports whether r is one of the roles
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+
+	return ok
+}
+
+// Includes reports whether r may do everything role may
+func (r Role) Includes(role Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[role]
+}
@@ -0,0 +1,41 @@
+package models
+
+// Status is the stage of a post in the editorial workflow. Posts are written
+// as drafts, submitted for review, published and eventually archived. Only
+// published posts are public
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusInReview  Status = "in_review"
+	StatusPublished Status = "published"
+	StatusArchived  Status = "archived"
+)
+
+// transitions lists the statuses a post can move to from each status.
+// Reviewers send posts back to draft, archived posts go back to draft to be
+// reviewed again before they are republished
+var transitions = map[Status][]Status{
+	StatusDraft:     {StatusInReview},
+	StatusInReview:  {StatusDraft, StatusPublished},
+	StatusPublished: {StatusArchived},
+	StatusArchived:  {StatusDraft},
+}
+
+// Valid reports whether s is one of the workflow statuses
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+
+	return ok
+}
+
+// CanTransitionTo reports whether a post can move from s to status
+func (s Status) CanTransitionTo(status Status) bool {
+	for _, next := range transitions[s] {
+		if next == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,137 @@
+// Package render turns the CommonMark content of posts into sanitized HTML
+package render
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"examples/bloggy/pkg/models"
+)
+
+// markdown parses CommonMark with GitHub tables and footnotes. Raw HTML in
+// the content is omitted, fenced code gets the class language-<info> and
+// table cells are aligned by attribute as the policy strips styles
+var markdown = goldmark.New(goldmark.WithExtensions(
+	extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+	extension.Footnote,
+))
+
+// policy allows the elements markdown renders to along with the attributes
+// of fenced code, footnotes and tables, anything else is stripped. Links
+// are made nofollow, URLs with schemes other than http, https and mailto
+// are dropped
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^fn(ref\d*)?:\d+$`)).OnElements("li", "sup")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^footnote(s|-ref|-backref)$`)).OnElements("a", "div")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-(noteref|backlink|endnotes)$`)).OnElements("a", "div")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+
+	return p
+}
+
+// HTML renders content as sanitized HTML
+func HTML(content string) (string, error) {
+	var buf bytes.Buffer
+
+	err := markdown.Convert([]byte(content), &buf)
+
+	if err != nil {
+		return "", err
+	}
+
+	return policy.Sanitize(buf.String()), nil
+}
+
+// textPolicy strips every element, leaving the text of rendered content
+var textPolicy = bluemonday.StrictPolicy()
+
+// Summary turns rendered content into plain text of at most n characters,
+// cut at a word boundary and ended with an ellipsis if shortened
+func Summary(rendered string, n int) string {
+	text := strings.Join(strings.Fields(html.UnescapeString(textPolicy.Sanitize(rendered))), " ")
+	runes := []rune(text)
+
+	if len(runes) <= n {
+		return text
+	}
+
+	cut := string(runes[:n])
+
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+type cached struct {
+	version int64
+	html    string
+}
+
+// Cache keeps the renders of posts by their ID. A render is only reused
+// for the version of the post it was made for, so modified posts are never
+// served stale, Invalidate frees the render of a post once it is modified.
+// Cache is safe for concurrent use
+type Cache struct {
+	mu      sync.Mutex
+	renders map[string]cached
+}
+
+func NewCache() *Cache {
+	return &Cache{renders: map[string]cached{}}
+}
+
+// HTML renders the content of post as sanitized HTML, reusing the render of
+// the same version of the post if there is one
+func (c *Cache) HTML(post models.Post) (string, error) {
+	key := post.ID.Hex()
+
+	c.mu.Lock()
+	render, ok := c.renders[key]
+	c.mu.Unlock()
+
+	if ok && render.version == post.Version {
+		return render.html, nil
+	}
+
+	html, err := HTML(post.Content)
+
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.renders[key] = cached{post.Version, html}
+	c.mu.Unlock()
+
+	return html, nil
+}
+
+// Invalidate drops the render of post
+func (c *Cache) Invalidate(post models.Post) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.renders, post.ID.Hex())
+}
+
+// Len returns the number of renders kept
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.renders)
+}
@@ -0,0 +1,142 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+func assertHTML(t *testing.T, content string, expected ...string) string {
+	t.Helper()
+
+	html, err := HTML(content)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fragment := range expected {
+		if !strings.Contains(html, fragment) {
+			t.Errorf("Expected %q in %q", fragment, html)
+		}
+	}
+
+	return html
+}
+
+func assertNotHTML(t *testing.T, html string, unexpected ...string) {
+	t.Helper()
+
+	for _, fragment := range unexpected {
+		if strings.Contains(html, fragment) {
+			t.Errorf("Expected no %q in %q", fragment, html)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	assertHTML(t, "# Hello\n\nHello *world*", "<h1>Hello</h1>", "<p>Hello <em>world</em></p>")
+
+	assertHTML(t, "| Name | Age |\n|:-----|----:|\n| Vishnu | 30 |\n",
+		"<table>", `<th align="left">Name</th>`, `<td align="right">30</td>`)
+
+	html := assertHTML(t, "```go\nfmt.Println(\"<hello>\")\n```\n",
+		`<pre><code class="language-go">`, "&lt;hello&gt;")
+
+	assertNotHTML(t, html, "<hello>")
+
+	assertHTML(t, "Hello[^1] and again[^1]\n\n[^1]: World\n",
+		`<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref"`,
+		`<sup id="fnref1:1">`,
+		`<div class="footnotes" role="doc-endnotes">`,
+		`<li id="fn:1">`,
+		`<a href="#fnref:1" class="footnote-backref" role="doc-backlink"`)
+}
+
+func TestHTMLSanitized(t *testing.T) {
+	contents := []string{
+		"<script>alert(1)</script>",
+		"Hello <img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"<a href=\"javascript:alert(1)\">click</a>",
+		"```go\" onmouseover=\"alert(1)\nHello\n```\n",
+		"<div class=\"footnotes\" style=\"position:fixed\">Hello</div>",
+	}
+
+	for _, content := range contents {
+		html := assertHTML(t, content)
+
+		assertNotHTML(t, html, "<script", "alert(1)\"", "javascript:", "onerror", "onmouseover", "style=")
+	}
+
+	assertHTML(t, "[Bloggy](https://example.com)", `<a href="https://example.com" rel="nofollow">Bloggy</a>`)
+}
+
+func TestSummary(t *testing.T) {
+	cases := []struct {
+		content  string
+		n        int
+		expected string
+	}{
+		{"# Hello\n\nHello *world* & <b>you</b>", 100, "Hello Hello world & you"},
+		{"Hello world, how are you", 14, "Hello world…"},
+		{"Hello world, how are you", 24, "Hello world, how are you"},
+		{"Héllo wörld", 8, "Héllo…"},
+		{"Helloworld", 5, "Hello…"},
+	}
+
+	for _, c := range cases {
+		html, err := HTML(c.content)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		summary := Summary(html, c.n)
+
+		if summary != c.expected {
+			t.Errorf("Expected summary of %q to be %q but got %q", c.content, c.expected, summary)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache()
+	post := models.Post{ID: primitive.NewObjectID(), Content: "Hello *world*", Version: 1}
+
+	html, err := cache.HTML(post)
+
+	if err != nil || html != "<p>Hello <em>world</em></p>\n" {
+		t.Fatalf("Expected rendered content but got %q, %v", html, err)
+	}
+
+	// Renders are reused for the same version only
+	post.Content = "Bye"
+
+	html, _ = cache.HTML(post)
+
+	if html != "<p>Hello <em>world</em></p>\n" {
+		t.Errorf("Expected the render of version 1 to be reused but got %q", html)
+	}
+
+	post.Version = 2
+
+	html, _ = cache.HTML(post)
+
+	if html != "<p>Bye</p>\n" {
+		t.Errorf("Expected version 2 to be rendered but got %q", html)
+	}
+
+	if cache.Len() != 1 {
+		t.Errorf("Expected one render per post but got %d", cache.Len())
+	}
+
+	cache.Invalidate(post)
+
+	if cache.Len() != 0 {
+		t.Errorf("Expected render to be invalidated but got %d", cache.Len())
+	}
+}
@@ -0,0 +1,221 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// linkAuthor fills in the name of the author post links to, responding with
+// bad request if there is no such author. Posts without an author keep the
+// name they were sent with
+func linkAuthor(c *gin.Context, authors storage.AuthorStorage, post *models.Post) bool {
+	if post.AuthorID.IsZero() {
+		return true
+	}
+
+	author, err := authors.Find(c.Request.Context(), post.AuthorID)
+
+	if err == storage.ErrAuthorDoesNotExist {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return false
+	}
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to find author in store: %s\n", err)
+		return false
+	}
+
+	post.Name = author.Name
+
+	return true
+}
+
+// authorID parses the id path parameter, responding with bad request if it
+// can not be the ID of an author
+func authorID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.String(http.StatusBadRequest, "%s", storage.ErrAuthorDoesNotExist.Error())
+		return id, false
+	}
+
+	return id, true
+}
+
+func CreateAuthorHandler(authors storage.AuthorStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var newAuthor models.Author
+
+		err := c.BindJSON(&newAuthor)
+
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		insertedAuthor, err := authors.Insert(c.Request.Context(), newAuthor)
+
+		if err == storage.ErrInvalidAuthor {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to insert author into store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, insertedAuthor)
+	}
+}
+
+func AuthorsHandler(authors storage.AuthorStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allAuthors, err := authors.All(c.Request.Context())
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list authors in store: %s\n", err)
+			return
+		}
+
+		for i := range allAuthors {
+			allAuthors[i] = profileOf(c, allAuthors[i])
+		}
+
+		c.JSON(http.StatusOK, allAuthors)
+	}
+}
+
+func FindAuthorHandler(authors storage.AuthorStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := authorID(c)
+
+		if !ok {
+			return
+		}
+
+		foundAuthor, err := authors.Find(c.Request.Context(), id)
+
+		if err == storage.ErrAuthorDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to find author in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, profileOf(c, foundAuthor))
+	}
+}
+
+func ModifyAuthorHandler(authors storage.AuthorStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := authorID(c)
+
+		if !ok || !ownsProfile(c, id) {
+			return
+		}
+
+		var newAuthor models.Author
+
+		err := c.BindJSON(&newAuthor)
+
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		modifiedAuthor, err := authors.Modify(c.Request.Context(), id, newAuthor)
+
+		if err == storage.ErrAuthorDoesNotExist || err == storage.ErrInvalidAuthor {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to modify author in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, modifiedAuthor)
+	}
+}
+
+// RemoveAuthorHandler removes authors that have no posts outside the trash,
+// the posts of an author would otherwise link to nothing
+func RemoveAuthorHandler(authors storage.AuthorStorage, s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := authorID(c)
+
+		if !ok {
+			return
+		}
+
+		page, err := s.List(c.Request.Context(), storage.ListOptions{AuthorID: id, Limit: 1})
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list store: %s\n", err)
+			return
+		}
+
+		if len(page.Posts) != 0 {
+			c.String(http.StatusConflict, "author has posts")
+			return
+		}
+
+		err = authors.Remove(c.Request.Context(), id)
+
+		if err == storage.ErrAuthorDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to delete author from store: %s\n", err)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+func AuthorPostsHandler(authors storage.AuthorStorage, s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := authorID(c)
+
+		if !ok {
+			return
+		}
+
+		_, err := authors.Find(c.Request.Context(), id)
+
+		if err == storage.ErrAuthorDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to find author in store: %s\n", err)
+			return
+		}
+
+		listPosts(c, s, storage.ListOptions{Status: models.StatusPublished, AuthorID: id})
+	}
+}
@@ -0,0 +1,251 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+func insertAuthor(t *testing.T, authors storage.AuthorStorage, name string) models.Author {
+	author, err := authors.Insert(context.Background(), models.Author{Name: name, Email: strings.ToLower(name) + "@example.com"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return author
+}
+
+func serve(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func assertAuthorBody(t *testing.T, w *httptest.ResponseRecorder, expected models.Author) models.Author {
+	var author models.Author
+
+	err := json.Unmarshal(w.Body.Bytes(), &author)
+
+	if err != nil {
+		t.Errorf("Invalid json body: %s", err.Error())
+	}
+
+	if author.Name != expected.Name || author.Email != expected.Email || author.Bio != expected.Bio {
+		t.Errorf("Expected %v but got %v", expected, author)
+	}
+
+	return author
+}
+
+func TestAuthors(t *testing.T) {
+	router, store, authors, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	testAuthor := models.Author{Name: "Vishnu", Email: "vishnu@example.com", Bio: "Writes about Go"}
+	body, _ := json.Marshal(testAuthor)
+
+	w := serve(router, "POST", "/v1/authors", string(body))
+
+	assertStatus(t, w, 200)
+	author := assertAuthorBody(t, w, testAuthor)
+
+	if author.ID.IsZero() {
+		t.Fatal("Expected author to have an ID")
+	}
+
+	path := "/v1/authors/" + author.ID.Hex()
+
+	w = serve(router, "GET", path, "")
+
+	assertStatus(t, w, 200)
+	assertAuthorBody(t, w, testAuthor)
+
+	insertAuthor(t, authors, "Bob")
+
+	w = serve(router, "GET", "/v1/authors", "")
+
+	assertStatus(t, w, 200)
+
+	var allAuthors []models.Author
+
+	json.Unmarshal(w.Body.Bytes(), &allAuthors)
+
+	if len(allAuthors) != 2 || allAuthors[0].Name != "Bob" || allAuthors[1].ID != author.ID {
+		t.Errorf("Expected Bob and %v but got %v", author, allAuthors)
+	}
+
+	testAuthor.Bio = "Writes about Rust"
+	body, _ = json.Marshal(testAuthor)
+
+	w = serve(router, "PATCH", path, string(body))
+
+	assertStatus(t, w, 200)
+	assertAuthorBody(t, w, testAuthor)
+
+	w = serve(router, "DELETE", path, "")
+
+	assertStatus(t, w, 200)
+
+	w = serve(router, "GET", path, "")
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, "storage: author does not exist")
+}
+
+func TestAuthorsInvalid(t *testing.T) {
+	router, store, authors, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	author := insertAuthor(t, authors, "Vishnu")
+	unknown := "/v1/authors/" + primitive.NewObjectID().Hex()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/v1/authors", `{"name":" "}`},
+		{"POST", "/v1/authors", `not json`},
+		{"PATCH", "/v1/authors/" + author.ID.Hex(), `{"name":""}`},
+		{"PATCH", unknown, `{"name":"Vishnu"}`},
+		{"GET", unknown, ""},
+		{"GET", "/v1/authors/vishnu", ""},
+		{"DELETE", unknown, ""},
+		{"GET", unknown + "/posts", ""},
+	}
+
+	for _, r := range requests {
+		w := serve(router, r.method, r.path, r.body)
+
+		if w.Code != 400 {
+			t.Errorf("Expected %s %s to respond 400 but got %d", r.method, r.path, w.Code)
+		}
+	}
+}
+
+func TestCreateWithAuthor(t *testing.T) {
+	router, store, authors, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	author := insertAuthor(t, authors, "Vishnu")
+
+	// The name of the author is taken from the profile
+	testPost := models.Post{AuthorID: author.ID, Name: "Someone else", Title: "Hello", Content: "Hello world"}
+	body, _ := json.Marshal(testPost)
+
+	w := serve(router, "POST", "/v1/create", string(body))
+
+	assertStatus(t, w, 200)
+
+	testPost.Name = "Vishnu"
+	assertBody(t, w, testPost)
+
+	testPost.AuthorID = primitive.NewObjectID()
+	body, _ = json.Marshal(testPost)
+
+	for _, r := range []struct{ method, path string }{{"POST", "/v1/create"}, {"PATCH", "/v1/modify/hello"}} {
+		w = serve(router, r.method, r.path, string(body))
+
+		assertStatus(t, w, 400)
+		assertBodyErrorMessage(t, w, "storage: author does not exist")
+	}
+
+	// Authors can be changed, edits without one keep the author
+	other := insertAuthor(t, authors, "Shankar")
+
+	testPost.AuthorID = other.ID
+	body, _ = json.Marshal(testPost)
+
+	w = serve(router, "PATCH", "/v1/modify/hello", string(body))
+
+	assertStatus(t, w, 200)
+
+	testPost.Name = "Shankar"
+	assertBody(t, w, testPost)
+
+	testPost.AuthorID = primitive.NilObjectID
+	testPost.Content = "Hello again"
+	body, _ = json.Marshal(testPost)
+
+	w = serve(router, "PATCH", "/v1/modify/hello", string(body))
+
+	assertStatus(t, w, 200)
+
+	testPost.AuthorID = other.ID
+	assertBody(t, w, testPost)
+}
+
+func TestAuthorPosts(t *testing.T) {
+	router, store, authors, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	author := insertAuthor(t, authors, "Vishnu")
+	other := insertAuthor(t, authors, "Shankar")
+
+	for _, post := range []models.Post{
+		{AuthorID: author.ID, Name: "Vishnu", Title: "A", Content: "Hello world"},
+		{AuthorID: author.ID, Name: "Vishnu", Title: "B", Content: "Hello world"},
+		{AuthorID: author.ID, Name: "Vishnu", Title: "C", Content: "Hello world"},
+		{AuthorID: other.ID, Name: "Shankar", Title: "D", Content: "Hello world"},
+	} {
+		store.Insert(context.Background(), post)
+	}
+
+	// Only published posts are public
+	publish(t, store, "a")
+	publish(t, store, "c")
+	publish(t, store, "d")
+
+	w := serve(router, "GET", "/v1/authors/"+author.ID.Hex()+"/posts?limit=1", "")
+
+	assertStatus(t, w, 200)
+	page := assertPage(t, w, []string{"A"})
+
+	w = serve(router, "GET", "/v1/authors/"+author.ID.Hex()+"/posts?limit=1&cursor="+page.Next, "")
+
+	assertStatus(t, w, 200)
+	assertPage(t, w, []string{"C"})
+
+	w = serve(router, "GET", "/v1/authors/"+other.ID.Hex()+"/posts?cursor="+page.Next, "")
+
+	assertStatus(t, w, 400)
+}
+
+func TestRemoveAuthorWithPosts(t *testing.T) {
+	router, store, authors, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	author := insertAuthor(t, authors, "Vishnu")
+	path := "/v1/authors/" + author.ID.Hex()
+
+	body, _ := json.Marshal(models.Post{AuthorID: author.ID, Title: "Hello", Content: "Hello world"})
+
+	w := serve(router, "POST", "/v1/create", string(body))
+
+	assertStatus(t, w, 200)
+
+	w = serve(router, "DELETE", path, "")
+
+	assertStatus(t, w, 409)
+	assertBodyErrorMessage(t, w, "author has posts")
+
+	w = serve(router, "DELETE", "/v1/remove/hello", "")
+
+	assertStatus(t, w, 200)
+
+	w = serve(router, "DELETE", path, "")
+
+	assertStatus(t, w, 200)
+}
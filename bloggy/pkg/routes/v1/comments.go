@@ -0,0 +1,240 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// CommentThread is a comment along with the replies to it, oldest first
+type CommentThread struct {
+	models.Comment
+	Replies []CommentThread `json:"replies"`
+}
+
+// threads nests comments, which are sorted oldest first, into the threads
+// they start. Replies to comments that are not among them are left out
+func threads(comments []models.Comment) []CommentThread {
+	replies := map[primitive.ObjectID][]models.Comment{}
+
+	for _, comment := range comments {
+		replies[comment.ParentID] = append(replies[comment.ParentID], comment)
+	}
+
+	var nest func(parentID primitive.ObjectID) []CommentThread
+
+	nest = func(parentID primitive.ObjectID) []CommentThread {
+		nested := []CommentThread{}
+
+		for _, reply := range replies[parentID] {
+			nested = append(nested, CommentThread{reply, nest(reply.ID)})
+		}
+
+		return nested
+	}
+
+	return nest(primitive.NilObjectID)
+}
+
+// commentedPost finds the post at the slug path parameter, responding with
+// bad request if there is none or it is not published and published is set
+func commentedPost(c *gin.Context, s storage.Storage, published bool) (models.Post, bool) {
+	foundPost, err := s.Find(c.Request.Context(), c.Param("slug"))
+
+	if err == nil && published && foundPost.Status != models.StatusPublished {
+		err = storage.ErrDoesNotExist
+	}
+
+	if err == storage.ErrDoesNotExist {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return foundPost, false
+	}
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to find in store: %s\n", err)
+		return foundPost, false
+	}
+
+	return foundPost, true
+}
+
+// commentID parses the id path parameter, responding with bad request if it
+// can not be the ID of a comment on post
+func commentID(c *gin.Context, comments storage.CommentStorage, post models.Post) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.String(http.StatusBadRequest, "%s", storage.ErrCommentDoesNotExist.Error())
+		return id, false
+	}
+
+	foundComment, err := comments.Find(c.Request.Context(), id)
+
+	if err == nil && foundComment.PostID != post.ID {
+		err = storage.ErrCommentDoesNotExist
+	}
+
+	if err == storage.ErrCommentDoesNotExist {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return id, false
+	}
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to find comment in store: %s\n", err)
+		return id, false
+	}
+
+	return id, true
+}
+
+// CommentsHandler responds with the approved comments on published posts,
+// nested into threads
+func CommentsHandler(s storage.Storage, comments storage.CommentStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := commentedPost(c, s, true)
+
+		if !ok {
+			return
+		}
+
+		approvedComments, err := comments.List(c.Request.Context(), post.ID, models.CommentApproved)
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list comments in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, threads(approvedComments))
+	}
+}
+
+// CreateCommentHandler queues comments on published posts for moderation
+func CreateCommentHandler(s storage.Storage, comments storage.CommentStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var newComment models.Comment
+
+		err := c.BindJSON(&newComment)
+
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		post, ok := commentedPost(c, s, true)
+
+		if !ok {
+			return
+		}
+
+		newComment.PostID = post.ID
+
+		insertedComment, err := comments.Insert(c.Request.Context(), newComment)
+
+		if err == storage.ErrInvalidComment || err == storage.ErrInvalidParent {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to insert comment into store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, insertedComment)
+	}
+}
+
+// ModerateCommentHandler moves comments to status
+func ModerateCommentHandler(s storage.Storage, comments storage.CommentStorage, status models.CommentStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := commentedPost(c, s, false)
+
+		if !ok {
+			return
+		}
+
+		id, ok := commentID(c, comments, post)
+
+		if !ok {
+			return
+		}
+
+		moderatedComment, err := comments.Moderate(c.Request.Context(), id, status)
+
+		if err == storage.ErrCommentDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to moderate comment in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, moderatedComment)
+	}
+}
+
+// RemoveCommentHandler removes comments along with the replies to them
+func RemoveCommentHandler(s storage.Storage, comments storage.CommentStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := commentedPost(c, s, false)
+
+		if !ok {
+			return
+		}
+
+		id, ok := commentID(c, comments, post)
+
+		if !ok {
+			return
+		}
+
+		err := comments.Remove(c.Request.Context(), id)
+
+		if err == storage.ErrCommentDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to delete comment from store: %s\n", err)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+// CommentStatusListHandler responds with the comments on every post that
+// have the status path parameter, oldest first. Listing pending comments
+// gives the moderation queue
+func CommentStatusListHandler(comments storage.CommentStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statusComments, err := comments.List(c.Request.Context(), primitive.NilObjectID, models.CommentStatus(c.Param("status")))
+
+		if err == storage.ErrInvalidStatus {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list comments in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, statusComments)
+	}
+}
@@ -0,0 +1,280 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// insertPublished inserts and publishes a post with title
+func insertPublished(t *testing.T, store storage.Storage, title string) models.Post {
+	post, err := store.Insert(context.Background(), models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, store, post.Slug)
+
+	return post
+}
+
+// postComment comments on the post with slug through the router, returning
+// the inserted comment
+func postComment(t *testing.T, router *gin.Engine, slug string, parentID primitive.ObjectID, content string) models.Comment {
+	body, _ := json.Marshal(models.Comment{ParentID: parentID, Name: "Shankar", Content: content})
+
+	w := serve(router, "POST", "/v1/posts/"+slug+"/comments", string(body))
+
+	assertStatus(t, w, 200)
+
+	var comment models.Comment
+
+	err := json.Unmarshal(w.Body.Bytes(), &comment)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	return comment
+}
+
+func approve(t *testing.T, router *gin.Engine, slug string, comment models.Comment) {
+	assertStatus(t, serve(router, "POST", "/v1/posts/"+slug+"/comments/"+comment.ID.Hex()+"/approve", ""), 200)
+}
+
+// assertThreads checks the threads in the body of w, formatted as content
+// followed by the replies in brackets
+func assertThreads(t *testing.T, w *httptest.ResponseRecorder, expected string) {
+	t.Helper()
+
+	var threads []CommentThread
+
+	err := json.Unmarshal(w.Body.Bytes(), &threads)
+
+	if err != nil {
+		t.Errorf("Invalid json body: %s", err.Error())
+	}
+
+	var format func(threads []CommentThread) string
+
+	format = func(threads []CommentThread) string {
+		formatted := ""
+
+		for _, thread := range threads {
+			formatted += fmt.Sprintf("%s[%s]", thread.Content, format(thread.Replies))
+		}
+
+		return formatted
+	}
+
+	if format(threads) != expected {
+		t.Errorf("Expected threads %s but got %s", expected, format(threads))
+	}
+}
+
+func TestComments(t *testing.T) {
+	router, store, _, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	insertPublished(t, store, "Hello")
+
+	first := postComment(t, router, "hello", primitive.NilObjectID, "First")
+
+	if first.Status != models.CommentPending || first.Name != "Shankar" {
+		t.Errorf("Expected a pending comment by Shankar but got %v", first)
+	}
+
+	// Comments are public once approved
+	w := serve(router, "GET", "/v1/posts/hello/comments", "")
+
+	assertStatus(t, w, 200)
+	assertThreads(t, w, "")
+
+	w = serve(router, "GET", "/v1/comments/pending", "")
+
+	assertStatus(t, w, 200)
+
+	approve(t, router, "hello", first)
+
+	second := postComment(t, router, "hello", primitive.NilObjectID, "Second")
+	reply := postComment(t, router, "hello", first.ID, "Reply")
+	approve(t, router, "hello", second)
+	approve(t, router, "hello", reply)
+
+	replyToReply := postComment(t, router, "hello", reply.ID, "Reply to reply")
+	approve(t, router, "hello", replyToReply)
+
+	rejected := postComment(t, router, "hello", first.ID, "Spam")
+	assertStatus(t, serve(router, "POST", "/v1/posts/hello/comments/"+rejected.ID.Hex()+"/reject", ""), 200)
+
+	w = serve(router, "GET", "/v1/posts/hello/comments", "")
+
+	assertStatus(t, w, 200)
+	assertThreads(t, w, "First[Reply[Reply to reply[]]]Second[]")
+
+	// Rejected comments leave the moderation queue
+	postComment(t, router, "hello", second.ID, "Pending")
+
+	w = serve(router, "GET", "/v1/comments/pending", "")
+
+	var pending []models.Comment
+
+	err := json.Unmarshal(w.Body.Bytes(), &pending)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if len(pending) != 1 || pending[0].Content != "Pending" {
+		t.Errorf("Expected the pending comment to be queued but got %v", pending)
+	}
+
+	assertStatus(t, serve(router, "GET", "/v1/comments/spam", ""), 400)
+}
+
+func TestCommentsInvalid(t *testing.T) {
+	router, store, _, _ := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	insertPublished(t, store, "Hello")
+	insertPublished(t, store, "World")
+
+	_, err := store.Insert(context.Background(), models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only published posts take comments
+	for _, path := range []string{"/v1/posts/draft/comments", "/v1/posts/missing/comments"} {
+		w := serve(router, "GET", path, "")
+
+		assertStatus(t, w, 400)
+		assertBodyErrorMessage(t, w, storage.ErrDoesNotExist.Error())
+
+		w = serve(router, "POST", path, `{"name": "Shankar", "content": "Hello"}`)
+
+		assertStatus(t, w, 400)
+	}
+
+	w := serve(router, "POST", "/v1/posts/hello/comments", `{"name": "Shankar", "content": " "}`)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrInvalidComment.Error())
+
+	w = serve(router, "POST", "/v1/posts/hello/comments", `{"name": "Shankar", "content":`)
+
+	assertStatus(t, w, 400)
+
+	// Replies answer approved comments on the same post
+	comment := postComment(t, router, "hello", primitive.NilObjectID, "First")
+
+	body, _ := json.Marshal(models.Comment{ParentID: comment.ID, Name: "Shankar", Content: "Reply"})
+
+	w = serve(router, "POST", "/v1/posts/hello/comments", string(body))
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrInvalidParent.Error())
+
+	approve(t, router, "hello", comment)
+
+	w = serve(router, "POST", "/v1/posts/world/comments", string(body))
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrInvalidParent.Error())
+
+	// Comments are moderated through the post they are on
+	for _, path := range []string{"/v1/posts/world/comments/" + comment.ID.Hex(), "/v1/posts/hello/comments/" + primitive.NewObjectID().Hex(), "/v1/posts/hello/comments/first"} {
+		w = serve(router, "POST", path+"/approve", "")
+
+		assertStatus(t, w, 400)
+		assertBodyErrorMessage(t, w, storage.ErrCommentDoesNotExist.Error())
+
+		w = serve(router, "DELETE", path, "")
+
+		assertStatus(t, w, 400)
+		assertBodyErrorMessage(t, w, storage.ErrCommentDoesNotExist.Error())
+	}
+}
+
+func TestRemoveComment(t *testing.T) {
+	router, store, _, comments := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	insertPublished(t, store, "Hello")
+
+	first := postComment(t, router, "hello", primitive.NilObjectID, "First")
+	approve(t, router, "hello", first)
+
+	second := postComment(t, router, "hello", primitive.NilObjectID, "Second")
+	approve(t, router, "hello", second)
+
+	reply := postComment(t, router, "hello", first.ID, "Reply")
+	approve(t, router, "hello", reply)
+
+	assertStatus(t, serve(router, "DELETE", "/v1/posts/hello/comments/"+first.ID.Hex(), ""), 200)
+
+	w := serve(router, "GET", "/v1/posts/hello/comments", "")
+
+	assertStatus(t, w, 200)
+	assertThreads(t, w, "Second[]")
+
+	_, err := comments.Find(context.Background(), reply.ID)
+
+	if err != storage.ErrCommentDoesNotExist {
+		t.Errorf("Expected reply to be removed with its parent but got %v", err)
+	}
+}
+
+// assertCommentCount checks that the post with id has count comments
+func assertCommentCount(t *testing.T, comments storage.CommentStorage, id primitive.ObjectID, count int) {
+	t.Helper()
+
+	postComments, err := comments.List(context.Background(), id, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(postComments) != count {
+		t.Errorf("Expected %d comments but got %v", count, postComments)
+	}
+}
+
+func TestPurgeCascadesComments(t *testing.T) {
+	router, store, _, comments := getRouterAndStores()
+	defer store.Disconnect(context.Background())
+
+	hello := insertPublished(t, store, "Hello")
+	world := insertPublished(t, store, "World")
+
+	comment := postComment(t, router, "hello", primitive.NilObjectID, "First")
+	approve(t, router, "hello", comment)
+	postComment(t, router, "hello", comment.ID, "Reply")
+	postComment(t, router, "world", primitive.NilObjectID, "Other")
+
+	// Comments of trashed posts come back when they are restored
+	assertStatus(t, serve(router, "DELETE", "/v1/remove/hello", ""), 200)
+	assertCommentCount(t, comments, hello.ID, 2)
+
+	assertStatus(t, serve(router, "POST", "/v1/restore/hello", ""), 200)
+	assertCommentCount(t, comments, hello.ID, 2)
+
+	// And are deleted when they are purged
+	assertStatus(t, serve(router, "DELETE", "/v1/remove/hello", ""), 200)
+	assertStatus(t, serve(router, "DELETE", "/v1/purge/hello", ""), 200)
+	assertCommentCount(t, comments, hello.ID, 0)
+	assertCommentCount(t, comments, world.ID, 1)
+
+	// Posts that do not exist have no comments to delete
+	assertStatus(t, serve(router, "DELETE", "/v1/purge/hello", ""), 400)
+}
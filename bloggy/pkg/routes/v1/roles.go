@@ -0,0 +1,157 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/auth"
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// forbidden responds with forbidden
+func forbidden(c *gin.Context) {
+	c.String(http.StatusForbidden, "%s", auth.ErrForbidden.Error())
+}
+
+// requireRole only lets requests through whose principal has role.
+// Anonymous requests are unauthorized, unless authentication is disabled in
+// which case every request passes
+func requireRole(role models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalOf(c)
+
+		if !ok && auth.Enabled(c) {
+			auth.Unauthorized(c, auth.ErrNoCredentials)
+			c.Abort()
+			return
+		}
+
+		if ok && !principal.Role.Includes(role) {
+			forbidden(c)
+			c.Abort()
+		}
+	}
+}
+
+// isEditor reports whether the principal of the request may edit any post,
+// which is also the case when authentication is disabled
+func isEditor(c *gin.Context) bool {
+	principal, ok := auth.PrincipalOf(c)
+
+	if !ok {
+		return !auth.Enabled(c)
+	}
+
+	return principal.Role.Includes(models.RoleEditor)
+}
+
+// claimPost makes the principal the author of post unless they are an
+// editor, responding with forbidden if post is linked to another author or
+// the principal has no author profile
+func claimPost(c *gin.Context, post *models.Post) bool {
+	if isEditor(c) {
+		return true
+	}
+
+	principal, _ := auth.PrincipalOf(c)
+
+	id, err := primitive.ObjectIDFromHex(principal.Subject)
+
+	if err != nil || (!post.AuthorID.IsZero() && post.AuthorID != id) {
+		forbidden(c)
+		return false
+	}
+
+	post.AuthorID = id
+
+	return true
+}
+
+// ownsPost checks that the principal is the author of the post at slug
+// unless they are an editor, responding with forbidden if not
+func ownsPost(c *gin.Context, s storage.Storage, slug string) bool {
+	if isEditor(c) {
+		return true
+	}
+
+	foundPost, err := s.Find(c.Request.Context(), slug)
+
+	if err == storage.ErrDoesNotExist {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return false
+	}
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to find in store: %s\n", err)
+		return false
+	}
+
+	principal, _ := auth.PrincipalOf(c)
+
+	if foundPost.AuthorID.IsZero() || foundPost.AuthorID.Hex() != principal.Subject {
+		forbidden(c)
+		return false
+	}
+
+	return true
+}
+
+// ownPosts narrows opts to the posts of the principal unless they are an
+// editor, responding with forbidden if the principal has no author profile
+func ownPosts(c *gin.Context, opts *storage.ListOptions) bool {
+	if isEditor(c) {
+		return true
+	}
+
+	principal, _ := auth.PrincipalOf(c)
+
+	id, err := primitive.ObjectIDFromHex(principal.Subject)
+
+	if err != nil {
+		forbidden(c)
+		return false
+	}
+
+	opts.AuthorID = id
+
+	return true
+}
+
+// isProfileOwner reports whether the principal is the author of the profile
+// with id or an admin
+func isProfileOwner(c *gin.Context, id primitive.ObjectID) bool {
+	principal, ok := auth.PrincipalOf(c)
+
+	if !ok && !auth.Enabled(c) {
+		return true
+	}
+
+	return principal.Role.Includes(models.RoleAdmin) || principal.Subject == id.Hex()
+}
+
+// ownsProfile checks that the principal is the author of the profile with
+// id unless they are an admin, responding with forbidden if not
+func ownsProfile(c *gin.Context, id primitive.ObjectID) bool {
+	if isProfileOwner(c, id) {
+		return true
+	}
+
+	forbidden(c)
+
+	return false
+}
+
+// profileOf returns author as the principal may read it, without the email
+// unless they are its author or an admin
+func profileOf(c *gin.Context, author models.Author) models.Author {
+	if !isProfileOwner(c, author.ID) {
+		author.Email = ""
+	}
+
+	return author
+}
@@ -0,0 +1,315 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"examples/bloggy/pkg/auth"
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// getRouterWithRoles creates a router authenticating by the API keys reader,
+// alice and bob who are authors, editor, admin and nobody, an author
+// without a profile
+func getRouterWithRoles(t *testing.T) (*gin.Engine, storage.Storage, models.Author, models.Author) {
+	store := storage.CreateMemoryStore()
+	authors := storage.CreateMemoryAuthorStore()
+
+	alice := insertAuthor(t, authors, "Alice")
+	bob := insertAuthor(t, authors, "Bob")
+
+	keys := auth.NewAPIKeys(map[string]auth.Principal{
+		"reader": {Subject: "reader", Role: models.RoleReader},
+		"alice":  {Subject: alice.ID.Hex(), Role: models.RoleAuthor},
+		"bob":    {Subject: bob.ID.Hex(), Role: models.RoleAuthor},
+		"editor": {Subject: "editor", Role: models.RoleEditor},
+		"admin":  {Subject: "admin", Role: models.RoleAdmin},
+		"nobody": {Subject: "nobody", Role: models.RoleAuthor},
+	})
+
+	router := gin.Default()
+	CreateRoutes(store, authors, storage.CreateMemoryCommentStore(), router, auth.Middleware(keys, auth.PublicReads...))
+
+	return router, store, alice, bob
+}
+
+func serveAs(router *gin.Engine, key string, method string, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(auth.APIKeyHeader, key)
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func assertForbidden(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	assertStatus(t, w, 403)
+	assertBodyErrorMessage(t, w, auth.ErrForbidden.Error())
+}
+
+func findPost(t *testing.T, store storage.Storage, slug string) models.Post {
+	post, err := store.Find(context.Background(), slug)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return post
+}
+
+func TestRoles(t *testing.T) {
+	router, store, _, _ := getRouterWithRoles(t)
+	defer store.Disconnect(context.Background())
+
+	body := `{"title": "Hello", "content": "Hello world"}`
+
+	assertForbidden(t, serveAs(router, "reader", "POST", "/v1/create", body))
+	assertForbidden(t, serveAs(router, "reader", "POST", "/v1/authors", `{"name": "Carol"}`))
+	assertCount(t, store, 0)
+
+	// Authors without a profile can not write posts
+	assertForbidden(t, serveAs(router, "nobody", "POST", "/v1/create", body))
+
+	assertStatus(t, serveAs(router, "alice", "POST", "/v1/create", body), 200)
+	assertStatus(t, serveAs(router, "alice", "POST", "/v1/submit/hello", ""), 200)
+
+	// Publishing, archiving and scheduling are up to editors
+	assertForbidden(t, serveAs(router, "alice", "POST", "/v1/publish/hello", ""))
+	assertForbidden(t, serveAs(router, "alice", "PUT", "/v1/schedule/hello", `{"publishAt": "2030-01-01T00:00:00Z"}`))
+	assertForbidden(t, serveAs(router, "alice", "POST", "/v1/rename-tags", `{"from": ["go"], "to": "golang"}`))
+
+	assertStatus(t, serveAs(router, "editor", "POST", "/v1/publish/hello", ""), 200)
+
+	// Readers comment, editors moderate
+	w := serveAs(router, "reader", "POST", "/v1/posts/hello/comments", `{"name": "Reader", "content": "Nice post"}`)
+
+	assertStatus(t, w, 200)
+
+	var comment models.Comment
+
+	err := json.Unmarshal(w.Body.Bytes(), &comment)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	moderatePath := "/v1/posts/hello/comments/" + comment.ID.Hex() + "/approve"
+
+	assertForbidden(t, serveAs(router, "alice", "POST", moderatePath, ""))
+	assertStatus(t, serveAs(router, "editor", "POST", moderatePath, ""), 200)
+	assertForbidden(t, serveAs(router, "editor", "POST", "/v1/authors", `{"name": "Carol"}`))
+
+	assertStatus(t, serveAs(router, "admin", "POST", "/v1/authors", `{"name": "Carol"}`), 200)
+	assertStatus(t, serveAs(router, "admin", "POST", "/v1/archive/hello", ""), 200)
+
+	// Published posts are read by anyone
+	assertStatus(t, serve(router, "GET", "/v1/posts", ""), 200)
+}
+
+func TestRestrictedReads(t *testing.T) {
+	router, store, _, _ := getRouterWithRoles(t)
+	defer store.Disconnect(context.Background())
+
+	assertStatus(t, serveAs(router, "alice", "POST", "/v1/create", `{"title": "Hello", "content": "Hello world"}`), 200)
+	assertStatus(t, serveAs(router, "alice", "PATCH", "/v1/modify/hello", `{"title": "Hello", "content": "Hello again"}`), 200)
+
+	history := []string{"/v1/revisions/hello", "/v1/revision/hello/1", "/v1/diff/hello/1/2"}
+	editorial := []string{"/v1/scheduled", "/v1/trash", "/v1/comments/pending"}
+
+	for _, path := range append(history, editorial...) {
+		w := serve(router, "GET", path, "")
+
+		assertStatus(t, w, 401)
+		assertBodyErrorMessage(t, w, auth.ErrNoCredentials.Error())
+
+		assertForbidden(t, serveAs(router, "reader", "GET", path, ""))
+		assertStatus(t, serveAs(router, "editor", "GET", path, ""), 200)
+	}
+
+	// Authors only see the history of their own posts
+	for _, path := range history {
+		assertStatus(t, serveAs(router, "alice", "GET", path, ""), 200)
+		assertForbidden(t, serveAs(router, "bob", "GET", path, ""))
+	}
+
+	for _, path := range editorial {
+		assertForbidden(t, serveAs(router, "alice", "GET", path, ""))
+	}
+}
+
+func TestStatusListRoles(t *testing.T) {
+	router, store, _, _ := getRouterWithRoles(t)
+	defer store.Disconnect(context.Background())
+
+	assertStatus(t, serveAs(router, "alice", "POST", "/v1/create", `{"title": "Hello", "content": "Hello world"}`), 200)
+	assertStatus(t, serveAs(router, "bob", "POST", "/v1/create", `{"title": "World", "content": "Hello world"}`), 200)
+
+	// Drafts are not listed to anyone
+	w := serve(router, "GET", "/v1/status/draft", "")
+
+	assertStatus(t, w, 401)
+	assertBodyErrorMessage(t, w, auth.ErrNoCredentials.Error())
+	assertForbidden(t, serveAs(router, "reader", "GET", "/v1/status/draft", ""))
+	assertForbidden(t, serveAs(router, "nobody", "GET", "/v1/status/draft", ""))
+
+	// Authors list their own posts, editors those of everyone
+	cases := []struct {
+		key      string
+		expected []string
+	}{
+		{"alice", []string{"hello"}},
+		{"bob", []string{"world"}},
+		{"editor", []string{"hello", "world"}},
+	}
+
+	for _, c := range cases {
+		w := serveAs(router, c.key, "GET", "/v1/status/draft?sort=title", "")
+
+		assertStatus(t, w, 200)
+
+		var page storage.Page
+
+		err := json.Unmarshal(w.Body.Bytes(), &page)
+
+		if err != nil {
+			t.Fatalf("Invalid json body: %s", err.Error())
+		}
+
+		var slugs []string
+
+		for _, post := range page.Posts {
+			slugs = append(slugs, post.Slug)
+		}
+
+		if strings.Join(slugs, " ") != strings.Join(c.expected, " ") {
+			t.Errorf("Expected %s to list drafts %v but got %v", c.key, c.expected, slugs)
+		}
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	router, store, alice, bob := getRouterWithRoles(t)
+	defer store.Disconnect(context.Background())
+
+	w := serveAs(router, "alice", "POST", "/v1/create", `{"title": "Hello", "content": "Hello world"}`)
+
+	assertStatus(t, w, 200)
+
+	post := findPost(t, store, "hello")
+
+	if post.AuthorID != alice.ID || post.Name != alice.Name {
+		t.Fatalf("Expected post to be written by %v but got %v", alice, post)
+	}
+
+	// Authors can not write posts in the name of others
+	body, _ := json.Marshal(models.Post{Title: "Bye", Content: "Bye world", AuthorID: bob.ID})
+
+	assertForbidden(t, serveAs(router, "alice", "POST", "/v1/create", string(body)))
+
+	// Nor touch posts of others
+	modifyBody := `{"title": "Hello", "content": "Hello again"}`
+
+	assertForbidden(t, serveAs(router, "bob", "PATCH", "/v1/modify/hello", modifyBody))
+	assertForbidden(t, serveAs(router, "bob", "POST", "/v1/submit/hello", ""))
+	assertForbidden(t, serveAs(router, "bob", "POST", "/v1/restore/hello/1", ""))
+	assertForbidden(t, serveAs(router, "bob", "DELETE", "/v1/remove/hello", ""))
+	assertForbidden(t, serveAs(router, "alice", "PATCH", "/v1/modify/hello", string(body)))
+
+	assertStatus(t, serveAs(router, "bob", "PATCH", "/v1/modify/missing", modifyBody), 400)
+	assertStatus(t, serveAs(router, "alice", "PATCH", "/v1/modify/hello", modifyBody), 200)
+
+	post = findPost(t, store, "hello")
+
+	if post.AuthorID != alice.ID || post.Content != "Hello again" {
+		t.Errorf("Expected post to be modified by its author but got %v", post)
+	}
+
+	// Editors modify any post
+	body, _ = json.Marshal(models.Post{Title: "Hello", Content: "Edited", AuthorID: alice.ID})
+
+	assertStatus(t, serveAs(router, "editor", "PATCH", "/v1/modify/hello", string(body)), 200)
+
+	post = findPost(t, store, "hello")
+
+	if post.AuthorID != alice.ID || post.Content != "Edited" {
+		t.Errorf("Expected post to be modified by the editor but got %v", post)
+	}
+
+	// Edits without an author keep the author of the post
+	assertStatus(t, serveAs(router, "editor", "PATCH", "/v1/modify/hello", `{"title": "Hello", "content": "Edited again"}`), 200)
+
+	post = findPost(t, store, "hello")
+
+	if post.AuthorID != alice.ID || post.Content != "Edited again" {
+		t.Errorf("Expected post to be kept by its author but got %v", post)
+	}
+
+	assertStatus(t, serveAs(router, "alice", "DELETE", "/v1/remove/hello", ""), 200)
+	assertCount(t, store, 0)
+}
+
+func TestProfileOwnership(t *testing.T) {
+	router, store, alice, bob := getRouterWithRoles(t)
+	defer store.Disconnect(context.Background())
+
+	assertStatus(t, serveAs(router, "alice", "PATCH", "/v1/authors/"+alice.ID.Hex(), `{"name": "Alice", "bio": "Writes"}`), 200)
+	assertForbidden(t, serveAs(router, "alice", "PATCH", "/v1/authors/"+bob.ID.Hex(), `{"name": "Bobby"}`))
+	assertForbidden(t, serveAs(router, "editor", "PATCH", "/v1/authors/"+bob.ID.Hex(), `{"name": "Bobby"}`))
+	assertStatus(t, serveAs(router, "admin", "PATCH", "/v1/authors/"+bob.ID.Hex(), `{"name": "Bobby"}`), 200)
+
+	assertForbidden(t, serveAs(router, "alice", "DELETE", "/v1/authors/"+bob.ID.Hex(), ""))
+	assertStatus(t, serveAs(router, "admin", "DELETE", "/v1/authors/"+bob.ID.Hex(), ""), 200)
+}
+
+func TestProfileEmails(t *testing.T) {
+	router, store, _, bob := getRouterWithRoles(t)
+	defer store.Disconnect(context.Background())
+
+	// Emails are only read by admins and the authors themselves
+	for _, key := range []string{"", "reader", "alice", "editor", "admin"} {
+		w := serveAs(router, key, "GET", "/v1/authors", "")
+
+		assertStatus(t, w, 200)
+
+		var authors []models.Author
+
+		err := json.Unmarshal(w.Body.Bytes(), &authors)
+
+		if err != nil {
+			t.Fatalf("Invalid json body: %s", err.Error())
+		}
+
+		emails := map[string]string{}
+
+		for _, author := range authors {
+			emails[author.Name] = author.Email
+		}
+
+		expectAlice, expectBob := key == "alice" || key == "admin", key == "admin"
+
+		if (emails["Alice"] != "") != expectAlice || (emails["Bob"] != "") != expectBob {
+			t.Errorf("Expected %q to read the emails of Alice %t and Bob %t but got %v", key, expectAlice, expectBob, emails)
+		}
+
+		if strings.Contains(w.Body.String(), `"email"`) != expectAlice {
+			t.Errorf("Expected %q to be sent emails %t but got %s", key, expectAlice, w.Body.String())
+		}
+
+		w = serveAs(router, key, "GET", "/v1/authors/"+bob.ID.Hex(), "")
+
+		assertStatus(t, w, 200)
+
+		if strings.Contains(w.Body.String(), bob.Email) != expectBob {
+			t.Errorf("Expected %q to read the email of Bob %t but got %s", key, expectBob, w.Body.String())
+		}
+	}
+}
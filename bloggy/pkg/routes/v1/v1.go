@@ -1,16 +1,50 @@
 package v1
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"examples/bloggy/pkg/diff"
 	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/render"
+	"examples/bloggy/pkg/slug"
 	"examples/bloggy/pkg/storage"
 )
 
-func CreateHandler(s storage.Storage) gin.HandlerFunc {
+// etag formats the version of a post as a strong entity tag
+func etag(post models.Post) string {
+	return fmt.Sprintf("\"%d\"", post.Version)
+}
+
+// expectedVersion parses the If-Match header into the version the post is
+// expected to be at, a missing header or * matches any version. Responds
+// with precondition failed if the header can not match any version
+func expectedVersion(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+
+	if header == "" || header == "*" {
+		return storage.AnyVersion, true
+	}
+
+	// Weak tags never match as If-Match uses the strong comparison
+	version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(header, "\""), "\""), 10, 64)
+
+	if err != nil || version < 1 || header != fmt.Sprintf("\"%d\"", version) {
+		c.String(http.StatusPreconditionFailed, "%s", storage.ErrVersionConflict.Error())
+		return 0, false
+	}
+
+	return version, true
+}
+
+func CreateHandler(s storage.Storage, authors storage.AuthorStorage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var newPost models.Post
 
@@ -21,9 +55,13 @@ func CreateHandler(s storage.Storage) gin.HandlerFunc {
 			return
 		}
 
-		err = s.Insert(c.Request.Context(), newPost)
+		if !claimPost(c, &newPost) || !linkAuthor(c, authors, &newPost) {
+			return
+		}
+
+		insertedPost, err := s.Insert(c.Request.Context(), newPost)
 
-		if err == storage.ErrAlreadyExists {
+		if err == storage.ErrAlreadyExists || err == storage.ErrInvalidSlug || err == storage.ErrInvalidTag {
 			c.String(http.StatusBadRequest, "%s", err.Error())
 			return
 		}
@@ -33,14 +71,36 @@ func CreateHandler(s storage.Storage) gin.HandlerFunc {
 			log.Printf("ERROR Unable to insert into store: %s\n", err)
 			return
 		}
+
+		c.Header("ETag", etag(insertedPost))
+		c.JSON(http.StatusOK, insertedPost)
 	}
 }
 
-func FindHandler(s storage.Storage) gin.HandlerFunc {
+// RenderedPost is a post along with its content rendered as sanitized HTML
+type RenderedPost struct {
+	models.Post
+	HTML string `json:"html"`
+}
+
+// FindHandler responds with published posts, along with their content
+// rendered as HTML if the format query parameter is html
+func FindHandler(s storage.Storage, renders *render.Cache) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		title := c.Param("title")
+		key := c.Param("slug")
+		format := c.Query("format")
+
+		if format != "" && format != "html" {
+			c.String(http.StatusBadRequest, "invalid format")
+			return
+		}
+
+		foundPost, err := s.Find(c.Request.Context(), key)
 
-		foundPost, err := s.Find(c.Request.Context(), title)
+		// Links made before there were slugs have the title instead
+		if err == storage.ErrDoesNotExist && !slug.Valid(key) {
+			foundPost, err = s.Find(c.Request.Context(), slug.Make(key))
+		}
 
 		if err == storage.ErrDoesNotExist {
 			c.String(http.StatusBadRequest, "%s", err.Error())
@@ -53,21 +113,62 @@ func FindHandler(s storage.Storage) gin.HandlerFunc {
 			return
 		}
 
-		h
+		// Posts that are not published are not public
+		if foundPost.Status != models.StatusPublished {
+			c.String(http.StatusBadRequest, "%s", storage.ErrDoesNotExist.Error())
+			return
+		}
+
+		// Old slugs and titles redirect to the current slug
+		if foundPost.Slug != key {
+			location := url.URL{Path: "/v1/find/" + foundPost.Slug, RawQuery: c.Request.URL.RawQuery}
+			c.Redirect(http.StatusMovedPermanently, location.String())
+			return
+		}
+
+		c.Header("ETag", etag(foundPost))
+
+		if format == "" {
+			c.JSON(http.StatusOK, foundPost)
+			return
+		}
+
+		html, err := renders.HTML(foundPost)
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to render post: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, RenderedPost{foundPost, html})
 	}
 }
 
+// RemoveHandler moves posts to the trash, their comments come back along
+// with them if they are restored
 func RemoveHandler(s storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		title := c.Param("title")
+		slug := c.Param("slug")
+
+		version, ok := expectedVersion(c)
+
+		if !ok || !ownsPost(c, s, slug) {
+			return
+		}
 
-		err := s.Remove(c.Request.Context(), title)
+		err := s.Remove(c.Request.Context(), slug, version)
 
 		if err == storage.ErrDoesNotExist {
 			c.String(http.StatusBadRequest, "%s", err.Error())
 			return
 		}
 
+		if err == storage.ErrVersionConflict {
+			c.String(http.StatusPreconditionFailed, "%s", err.Error())
+			return
+		}
+
 		if err != nil {
 			c.Status(http.StatusInternalServerError)
 			log.Printf("ERROR Unable to delete from store: %s\n", err)
@@ -78,9 +179,10 @@ func RemoveHandler(s storage.Storage) gin.HandlerFunc {
 	}
 }
 
-func ModifyHandler(s storage.Storage) gin.HandlerFunc {
+// ModifyHandler modifies posts, dropping the render of their previous content
+func ModifyHandler(s storage.Storage, authors storage.AuthorStorage, renders *render.Cache) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		title := c.Param("title")
+		slug := c.Param("slug")
 
 		var newPost models.Post
 
@@ -91,28 +193,632 @@ func ModifyHandler(s storage.Storage) gin.HandlerFunc {
 			return
 		}
 
-		err = s.Modify(c.Request.Context(), title, newPost)
+		if !ownsPost(c, s, slug) || !claimPost(c, &newPost) || !linkAuthor(c, authors, &newPost) {
+			return
+		}
+
+		version, ok := expectedVersion(c)
 
-		if err == storage.ErrDoesNotExist {
+		if !ok {
+			return
+		}
+
+		modifiedPost, err := s.Modify(c.Request.Context(), slug, version, newPost)
+
+		if err == storage.ErrDoesNotExist || err == storage.ErrAlreadyExists || err == storage.ErrInvalidSlug || err == storage.ErrInvalidTag {
 			c.String(http.StatusBadRequest, "%s", err.Error())
 			return
 		}
 
+		if err == storage.ErrVersionConflict {
+			c.String(http.StatusPreconditionFailed, "%s", err.Error())
+			return
+		}
+
 		if err != nil {
 			c.Status(http.StatusInternalServerError)
 			log.Printf("ERROR Unable to modify in store: %s\n", err)
 			return
 		}
 
+		renders.Invalidate(modifiedPost)
+
+		c.Header("ETag", etag(modifiedPost))
+		c.JSON(http.StatusOK, modifiedPost)
+	}
+}
+
+// pageLink formats a Link header value pointing to the current URL with the
+// cursor query parameter replaced
+func pageLink(c *gin.Context, cursor string, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set("cursor", cursor)
+
+	link := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=\"%s\"", link.String(), rel)
+}
+
+// pageLinks sets the Link header to the pages at the next and prev cursors,
+// if there are any
+func pageLinks(c *gin.Context, next string, prev string) {
+	var links []string
+
+	if next != "" {
+		links = append(links, pageLink(c, next, "next"))
+	}
+
+	if prev != "" {
+		links = append(links, pageLink(c, prev, "prev"))
+	}
+
+	if len(links) != 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLimit parses the limit query parameter, responding with bad request
+// if it is not valid. Zero means the default limit
+func pageLimit(c *gin.Context) (int, bool) {
+	if c.Query("limit") == "" {
+		return 0, true
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+
+	if err != nil || limit <= 0 {
+		c.String(http.StatusBadRequest, "invalid limit")
+		return 0, false
+	}
+
+	return limit, true
+}
+
+// listPosts responds with the page of posts matching opts selected by the
+// query parameters
+func listPosts(c *gin.Context, s storage.Storage, opts storage.ListOptions) {
+	limit, ok := pageLimit(c)
+
+	if !ok {
+		return
+	}
+
+	opts.Cursor = c.Query("cursor")
+	opts.Sort = c.Query("sort")
+	opts.Tag = c.Query("tag")
+	opts.Category = c.Query("category")
+	opts.Limit = limit
+
+	page, err := s.List(c.Request.Context(), opts)
+
+	if err == storage.ErrInvalidCursor || err == storage.ErrInvalidSort || err == storage.ErrInvalidTag || err == storage.ErrInvalidStatus {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return
+	}
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to list store: %s\n", err)
+		return
+	}
+
+	pageLinks(c, page.Next, page.Prev)
+
+	c.JSON(http.StatusOK, page)
+}
+
+func ListHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listPosts(c, s, storage.ListOptions{Status: models.StatusPublished})
+	}
+}
+
+// StatusListHandler lists the posts with a status, only their own to
+// authors who are not editors
+func StatusListHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := storage.ListOptions{Status: models.Status(c.Param("status"))}
+
+		if !ownPosts(c, &opts) {
+			return
+		}
+
+		listPosts(c, s, opts)
+	}
+}
+
+func SearchHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := pageLimit(c)
+
+		if !ok {
+			return
+		}
+
+		opts := storage.SearchOptions{
+			Cursor: c.Query("cursor"),
+			Status: models.StatusPublished,
+			Limit:  limit,
+		}
+
+		page, err := s.Search(c.Request.Context(), c.Query("q"), opts)
+
+		if err == storage.ErrInvalidQuery || err == storage.ErrInvalidCursor {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to search store: %s\n", err)
+			return
+		}
+
+		pageLinks(c, page.Next, page.Prev)
+
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// TransitionHandler moves posts to status, authors may only move their own
+func TransitionHandler(s storage.Storage, status models.Status) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		version, ok := expectedVersion(c)
+
+		if !ok || !ownsPost(c, s, slug) {
+			return
+		}
+
+		transitionedPost, err := s.Transition(c.Request.Context(), slug, version, status)
+
+		if err == storage.ErrDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err == storage.ErrInvalidTransition {
+			c.String(http.StatusConflict, "%s", err.Error())
+			return
+		}
+
+		if err == storage.ErrVersionConflict {
+			c.String(http.StatusPreconditionFailed, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to transition in store: %s\n", err)
+			return
+		}
+
+		c.Header("ETag", etag(transitionedPost))
+		c.JSON(http.StatusOK, transitionedPost)
+	}
+}
+
+type scheduleRequest struct {
+	PublishAt *time.Time `json:"publishAt"`
+}
+
+// respondScheduled responds with the post scheduled by Schedule or the error
+// it returned
+func respondScheduled(c *gin.Context, scheduledPost models.Post, err error) {
+	if err == storage.ErrDoesNotExist || err == storage.ErrNotScheduled {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return
+	}
+
+	if err == storage.ErrInvalidTransition {
+		c.String(http.StatusConflict, "%s", err.Error())
+		return
+	}
+
+	if err == storage.ErrVersionConflict {
+		c.String(http.StatusPreconditionFailed, "%s", err.Error())
+		return
+	}
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to schedule in store: %s\n", err)
+		return
+	}
+
+	c.Header("ETag", etag(scheduledPost))
+	c.JSON(http.StatusOK, scheduledPost)
+}
+
+func ScheduleHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		var request scheduleRequest
+
+		err := c.BindJSON(&request)
+
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if request.PublishAt == nil {
+			c.String(http.StatusBadRequest, "missing publishAt")
+			return
+		}
+
+		version, ok := expectedVersion(c)
+
+		if !ok {
+			return
+		}
+
+		scheduledPost, err := s.Schedule(c.Request.Context(), slug, version, request.PublishAt)
+
+		respondScheduled(c, scheduledPost, err)
+	}
+}
+
+func UnscheduleHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		version, ok := expectedVersion(c)
+
+		if !ok {
+			return
+		}
+
+		unscheduledPost, err := s.Schedule(c.Request.Context(), slug, version, nil)
+
+		respondScheduled(c, unscheduledPost, err)
+	}
+}
+
+func ScheduledHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheduledPosts, err := s.Scheduled(c.Request.Context())
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list scheduled posts in store: %s\n", err)
+			return
+		}
+
+		if scheduledPosts == nil {
+			scheduledPosts = []models.Post{}
+		}
+
+		c.JSON(http.StatusOK, scheduledPosts)
+	}
+}
+
+func TagsHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tags, err := s.Tags(c.Request.Context())
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list tags in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, tags)
+	}
+}
+
+func CategoriesHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		categories, err := s.Categories(c.Request.Context())
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list categories in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, categories)
+	}
+}
+
+type renameTagsRequest struct {
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+func RenameTagsHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request renameTagsRequest
+
+		err := c.BindJSON(&request)
+
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		renamed, err := s.RenameTags(c.Request.Context(), request.From, request.To)
+
+		if err == storage.ErrInvalidTag {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to rename tags in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"renamed": renamed})
+	}
+}
+
+// revisionNumber parses a revision number path parameter, responding with
+// bad request if it is not valid
+func revisionNumber(c *gin.Context, name string) (int, bool) {
+	number, err := strconv.Atoi(c.Param(name))
+
+	if err != nil || number < 1 {
+		c.String(http.StatusBadRequest, "invalid revision number")
+		return 0, false
+	}
+
+	return number, true
+}
+
+func RevisionsHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		if !ownsPost(c, s, slug) {
+			return
+		}
+
+		revisions, err := s.Revisions(c.Request.Context(), slug)
+
+		if err == storage.ErrDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to find revisions in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, revisions)
+	}
+}
+
+func RevisionHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		number, ok := revisionNumber(c, "number")
+
+		if !ok || !ownsPost(c, s, slug) {
+			return
+		}
+
+		revision, err := s.Revision(c.Request.Context(), slug, number)
+
+		if err == storage.ErrDoesNotExist || err == storage.ErrRevisionDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to find revision in store: %s\n", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, revision)
+	}
+}
+
+// RevisionDiff is the line by line difference between the content of two
+// revisions of a post
+type RevisionDiff struct {
+	From    models.Revision `json:"from"`
+	To      models.Revision `json:"to"`
+	Content []diff.Line     `json:"content"`
+}
+
+func DiffHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		fromNumber, ok := revisionNumber(c, "from")
+
+		if !ok {
+			return
+		}
+
+		toNumber, ok := revisionNumber(c, "to")
+
+		if !ok || !ownsPost(c, s, slug) {
+			return
+		}
+
+		from, err := s.Revision(c.Request.Context(), slug, fromNumber)
+
+		if err == nil {
+			var to models.Revision
+
+			to, err = s.Revision(c.Request.Context(), slug, toNumber)
+
+			if err == nil {
+				c.JSON(http.StatusOK, RevisionDiff{from, to, diff.Text(from.Content, to.Content)})
+				return
+			}
+		}
+
+		if err == storage.ErrDoesNotExist || err == storage.ErrRevisionDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to find revision in store: %s\n", err)
+	}
+}
+
+func RestoreRevisionHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		number, ok := revisionNumber(c, "number")
+
+		if !ok || !ownsPost(c, s, slug) {
+			return
+		}
+
+		restoredPost, err := s.RestoreRevision(c.Request.Context(), slug, number)
+
+		if err == storage.ErrDoesNotExist || err == storage.ErrRevisionDoesNotExist || err == storage.ErrAlreadyExists {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err == storage.ErrVersionConflict {
+			c.String(http.StatusPreconditionFailed, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to restore revision in store: %s\n", err)
+			return
+		}
+
+		c.Header("ETag", etag(restoredPost))
+		c.JSON(http.StatusOK, restoredPost)
+	}
+}
+
+func TrashHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trashedPosts, err := s.Trash(c.Request.Context())
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to list trash in store: %s\n", err)
+			return
+		}
+
+		if trashedPosts == nil {
+			trashedPosts = []models.Post{}
+		}
+
+		c.JSON(http.StatusOK, trashedPosts)
+	}
+}
+
+func RestoreHandler(s storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		restoredPost, err := s.Restore(c.Request.Context(), slug)
+
+		if err == storage.ErrDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to restore from trash in store: %s\n", err)
+			return
+		}
+
+		c.Header("ETag", etag(restoredPost))
+		c.JSON(http.StatusOK, restoredPost)
+	}
+}
+
+// PurgeHandler permanently deletes posts in the trash along with their
+// comments
+func PurgeHandler(s storage.Storage, comments storage.CommentStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := c.Param("slug")
+
+		id, err := s.Purge(c.Request.Context(), slug)
+
+		if err == storage.ErrDoesNotExist {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
+		}
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to purge from store: %s\n", err)
+			return
+		}
+
+		err = comments.RemovePost(c.Request.Context(), id)
+
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			log.Printf("ERROR Unable to delete comments from store: %s\n", err)
+			return
+		}
+
 		c.Status(http.StatusOK)
 	}
 }
 
-func CreateRoutes(s storage.Storage, router *gin.Engine) {
-	v1 := router.Group("/v1")
+// CreateRoutes adds the v1 routes to router, behind middleware such as
+// auth.Middleware if given. Writes and reads of posts that are not
+// published then require the role of the principal to include the one of the
+// route, authors only write and see the history of their own posts and
+// profile
+func CreateRoutes(s storage.Storage, authors storage.AuthorStorage, comments storage.CommentStorage, router *gin.Engine, middleware ...gin.HandlerFunc) {
+	v1 := router.Group("/v1", middleware...)
+	renders := render.NewCache()
+
+	author := requireRole(models.RoleAuthor)
+	editor := requireRole(models.RoleEditor)
+	admin := requireRole(models.RoleAdmin)
 
-	v1.POST("/create", CreateHandler(s))
-	v1.GET("/find/:title", FindHandler(s))
-	v1.DELETE("/remove/:title", RemoveHandler(s))
-	v1.PATCH("/modify/:title", ModifyHandler(s))
+	v1.POST("/create", author, CreateHandler(s, authors))
+	v1.GET("/find/:slug", FindHandler(s, renders))
+	v1.DELETE("/remove/:slug", author, RemoveHandler(s))
+	v1.PATCH("/modify/:slug", author, ModifyHandler(s, authors, renders))
+	v1.GET("/posts", ListHandler(s))
+	v1.GET("/status/:status", author, StatusListHandler(s))
+	v1.POST("/submit/:slug", author, TransitionHandler(s, models.StatusInReview))
+	v1.POST("/publish/:slug", editor, TransitionHandler(s, models.StatusPublished))
+	v1.POST("/archive/:slug", editor, TransitionHandler(s, models.StatusArchived))
+	v1.POST("/draft/:slug", author, TransitionHandler(s, models.StatusDraft))
+	v1.PUT("/schedule/:slug", editor, ScheduleHandler(s))
+	v1.DELETE("/schedule/:slug", editor, UnscheduleHandler(s))
+	v1.GET("/scheduled", editor, ScheduledHandler(s))
+	v1.GET("/search", SearchHandler(s))
+	v1.GET("/tags", TagsHandler(s))
+	v1.GET("/categories", CategoriesHandler(s))
+	v1.POST("/rename-tags", editor, RenameTagsHandler(s))
+	v1.GET("/revisions/:slug", author, RevisionsHandler(s))
+	v1.GET("/revision/:slug/:number", author, RevisionHandler(s))
+	v1.GET("/diff/:slug/:from/:to", author, DiffHandler(s))
+	v1.POST("/restore/:slug/:number", author, RestoreRevisionHandler(s))
+	v1.GET("/trash", editor, TrashHandler(s))
+	v1.POST("/restore/:slug", editor, RestoreHandler(s))
+	v1.DELETE("/purge/:slug", editor, PurgeHandler(s, comments))
+	v1.POST("/authors", admin, CreateAuthorHandler(authors))
+	v1.GET("/authors", AuthorsHandler(authors))
+	v1.GET("/authors/:id", FindAuthorHandler(authors))
+	v1.PATCH("/authors/:id", author, ModifyAuthorHandler(authors))
+	v1.DELETE("/authors/:id", admin, RemoveAuthorHandler(authors, s))
+	v1.GET("/authors/:id/posts", AuthorPostsHandler(authors, s))
+	v1.GET("/posts/:slug/comments", CommentsHandler(s, comments))
+	v1.POST("/posts/:slug/comments", CreateCommentHandler(s, comments))
+	v1.POST("/posts/:slug/comments/:id/approve", editor, ModerateCommentHandler(s, comments, models.CommentApproved))
+	v1.POST("/posts/:slug/comments/:id/reject", editor, ModerateCommentHandler(s, comments, models.CommentRejected))
+	v1.DELETE("/posts/:slug/comments/:id", editor, RemoveCommentHandler(s, comments))
+	v1.GET("/comments/:status", editor, CommentStatusListHandler(comments))
 }
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"examples/bloggy/pkg/auth"
 	"examples/bloggy/pkg/models"
 	"examples/bloggy/pkg/storage"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,25 +18,38 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func getRouterAndStorage() (*gin.Engine, storage.Storage) {
-	// Create Mongo store
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	store, err := storage.CreateMongoStore(ctx, "test", "test")
-	if err != nil {
-		panic(err)
-	}
-
-	store.Clean(ctx)
+func getRouterAndStores() (*gin.Engine, storage.Storage, storage.AuthorStorage, storage.CommentStorage) {
+	// Handlers only depend on the storage interfaces, which every backend
+	// conforms to, so test them against the memory stores
+	store := storage.CreateMemoryStore()
+	authors := storage.CreateMemoryAuthorStore()
+	comments := storage.CreateMemoryCommentStore()
 
 	// Create routes
 	router := gin.Default()
-	CreateRoutes(store, router)
+	CreateRoutes(store, authors, comments, router)
+
+	return router, store, authors, comments
+}
+
+func getRouterAndStorage() (*gin.Engine, storage.Storage) {
+	router, store, _, _ := getRouterAndStores()
 
 	return router, store
 }
 
+// publish moves the post with slug through review to published, only
+// published posts are public
+func publish(t *testing.T, store storage.Storage, slug string) {
+	for _, status := range []models.Status{models.StatusInReview, models.StatusPublished} {
+		_, err := store.Transition(context.Background(), slug, storage.AnyVersion, status)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func assertCount(t *testing.T, store storage.Storage, expectedCount int) {
 	allPosts, err := store.All(context.Background())
 	count := len(allPosts)
@@ -58,7 +74,7 @@ func assertPost(t *testing.T, store storage.Storage, idx int, expectedPost model
 	post := allPosts[0]
 
 	if !post.IsEqual(expectedPost) {
-		t.Errorf("Expected %s but got %s", expectedPost, post)
+		t.Errorf("Expected %v but got %v", expectedPost, post)
 	}
 }
 
@@ -77,10 +93,8 @@ func assertBody(t *testing.T, w *httptest.ResponseRecorder, expectedPost models.
 		t.Errorf("Invalid json body: %s", err.Error())
 	}
 
-	post.ID = expectedPost.ID // Ignore ID field
-
-	if post != expectedPost {
-		t.Errorf("Expected %s but got %s", expectedPost, post)
+	if !post.IsEqual(expectedPost) {
+		t.Errorf("Expected %v but got %v", expectedPost, post)
 	}
 }
 
@@ -114,6 +128,53 @@ func TestCreate(t *testing.T) {
 	assertPost(t, store, 0, testPost)
 }
 
+func TestCreateIgnoresTimestamps(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	clientTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testPost := models.Post{
+		Title:       "Hello",
+		Name:        "Vishnu",
+		Content:     "Hello world",
+		CreatedAt:   clientTime,
+		UpdatedAt:   clientTime,
+		PublishedAt: &clientTime,
+		Status:      models.StatusPublished,
+	}
+
+	testBody, _ := json.Marshal(testPost)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/create", bytes.NewBuffer(testBody))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var post models.Post
+
+	err := json.Unmarshal(w.Body.Bytes(), &post)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if post.CreatedAt.Equal(clientTime) || post.UpdatedAt.Equal(clientTime) || post.PublishedAt != nil {
+		t.Errorf("Expected server managed timestamps but got %v", post)
+	}
+
+	if post.Status != models.StatusDraft {
+		t.Errorf("Expected created post to be a draft but got %s", post.Status)
+	}
+
+	storedPost, _ := store.Find(context.Background(), "hello")
+
+	if !storedPost.CreatedAt.Equal(post.CreatedAt) {
+		t.Errorf("Expected createdAt to be %s but got %s", storedPost.CreatedAt, post.CreatedAt)
+	}
+}
+
 func TestCreateBadJSONBody(t *testing.T) {
 	router, store := getRouterAndStorage()
 	defer store.Disconnect(context.Background())
@@ -135,6 +196,7 @@ func TestCreateAlreadyExists(t *testing.T) {
 
 	testPost := models.Post{
 		ID:      primitive.NilObjectID,
+		Slug:    "hello",
 		Title:   "Hello",
 		Name:    "Vishnu",
 		Content: "Hello world",
@@ -158,6 +220,74 @@ func TestCreateAlreadyExists(t *testing.T) {
 	assertPost(t, store, 0, testPost)
 }
 
+func TestCreateSlug(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{
+		Title:   "Ünïcode / What's new?",
+		Name:    "Vishnu",
+		Content: "Hello world",
+	}
+
+	testBody, _ := json.Marshal(testPost)
+
+	// Posts with the same title get a numbered slug
+	for _, expectedSlug := range []string{"unicode-whats-new", "unicode-whats-new-2"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/create", bytes.NewBuffer(testBody))
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 200)
+
+		var post models.Post
+
+		err := json.Unmarshal(w.Body.Bytes(), &post)
+
+		if err != nil {
+			t.Fatalf("Invalid json body: %s", err.Error())
+		}
+
+		if post.Slug != expectedSlug {
+			t.Errorf("Expected slug to be %s but got %s", expectedSlug, post.Slug)
+		}
+
+		publish(t, store, post.Slug)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/v1/find/"+post.Slug, nil)
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 200)
+
+		assertBody(t, w, testPost)
+	}
+}
+
+func TestCreateInvalidSlug(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{
+		Slug:    "Hello World",
+		Title:   "Hello",
+		Name:    "Vishnu",
+		Content: "Hello world",
+	}
+
+	testBody, _ := json.Marshal(testPost)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/create", bytes.NewBuffer(testBody))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+
+	assertBodyErrorMessage(t, w, "storage: invalid slug")
+
+	assertCount(t, store, 0)
+}
+
 func TestFind(t *testing.T) {
 	router, store := getRouterAndStorage()
 	defer store.Disconnect(context.Background())
@@ -185,8 +315,10 @@ func TestFind(t *testing.T) {
 	req, _ = http.NewRequest("POST", "/v1/create", bytes.NewBuffer(testBodyTwo))
 	router.ServeHTTP(httptest.NewRecorder(), req)
 
+	publish(t, store, "world")
+
 	w := httptest.NewRecorder()
-	req, _ = http.NewRequest("GET", "/v1/find/World", nil)
+	req, _ = http.NewRequest("GET", "/v1/find/world", nil)
 	router.ServeHTTP(w, req)
 
 	assertStatus(t, w, 200)
@@ -194,6 +326,119 @@ func TestFind(t *testing.T) {
 	assertBody(t, w, testPostTwo)
 }
 
+func TestFindRedirect(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello World", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost)
+
+	testPost.Slug = "greeting"
+
+	_, err := store.Modify(context.Background(), "hello-world", storage.AnyVersion, testPost)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, store, "greeting")
+
+	for _, path := range []string{"/v1/find/hello-world", "/v1/find/Hello%20World"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 301)
+
+		if w.Header().Get("Location") != "/v1/find/greeting" {
+			t.Errorf("Expected %s to redirect to /v1/find/greeting but got %s", path, w.Header().Get("Location"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/find/greeting", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	assertBody(t, w, testPost)
+}
+
+func assertRendered(t *testing.T, w *httptest.ResponseRecorder, content string, html string) {
+	t.Helper()
+
+	var rendered RenderedPost
+
+	err := json.Unmarshal(w.Body.Bytes(), &rendered)
+
+	if err != nil {
+		t.Errorf("Invalid json body: %s", err.Error())
+	}
+
+	if rendered.Content != content || rendered.HTML != html {
+		t.Errorf("Expected %q rendered as %q but got %q rendered as %q", content, html, rendered.Content, rendered.HTML)
+	}
+}
+
+func TestFindHTML(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello *world*<script>alert(1)</script>"}
+	testBody, _ := json.Marshal(testPost)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/create", bytes.NewBuffer(testBody))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	publish(t, store, "hello")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/find/hello?format=html", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertRendered(t, w, testPost.Content, "<p>Hello <em>world</em>alert(1)</p>\n")
+
+	// Modified posts are rendered again
+	testPost.Content = "Hello *again*"
+	testPost.Slug = "greeting"
+	testBody, _ = json.Marshal(testPost)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	// Redirects keep the format
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/find/hello?format=html", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 301)
+
+	if w.Header().Get("Location") != "/v1/find/greeting?format=html" {
+		t.Errorf("Expected redirect to keep the format but got %s", w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/find/greeting?format=html", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertRendered(t, w, testPost.Content, "<p>Hello <em>again</em></p>\n")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/find/greeting?format=pdf", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, "invalid format")
+}
+
 func TestFindDoesNotExist(t *testing.T) {
 	router, store := getRouterAndStorage()
 	defer store.Disconnect(context.Background())
@@ -232,7 +477,7 @@ func TestModify(t *testing.T) {
 	router.ServeHTTP(httptest.NewRecorder(), req)
 
 	w := httptest.NewRecorder()
-	req, _ = http.NewRequest("PATCH", "/v1/modify/Hello", bytes.NewBuffer(testModifiedBody))
+	req, _ = http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testModifiedBody))
 	router.ServeHTTP(w, req)
 
 	assertStatus(t, w, 200)
@@ -277,6 +522,51 @@ func TestModifyDoesNotExist(t *testing.T) {
 	assertBodyErrorMessage(t, w, "storage: post does not exists")
 }
 
+func TestModifySlug(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+	insertRevisions(t, store, models.Post{Title: "World", Name: "Vishnu", Content: "This is golang"})
+
+	tests := []struct {
+		slug    string
+		status  int
+		message string
+	}{
+		{"Not a slug", 400, "storage: invalid slug"},
+		{"world", 400, "storage: post already exists"},
+	}
+
+	for _, test := range tests {
+		testBody, _ := json.Marshal(models.Post{Slug: test.slug, Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, test.status)
+
+		assertBodyErrorMessage(t, w, test.message)
+	}
+
+	testBody, _ := json.Marshal(models.Post{Slug: "hello-again", Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	publish(t, store, "hello-again")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/find/hello-again", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+}
+
 func TestRemove(t *testing.T) {
 	router, store := getRouterAndStorage()
 	defer store.Disconnect(context.Background())
@@ -294,7 +584,7 @@ func TestRemove(t *testing.T) {
 	router.ServeHTTP(httptest.NewRecorder(), req)
 
 	w := httptest.NewRecorder()
-	req, _ = http.NewRequest("DELETE", "/v1/remove/Hello", nil)
+	req, _ = http.NewRequest("DELETE", "/v1/remove/hello", nil)
 	router.ServeHTTP(httptest.NewRecorder(), req)
 
 	assertStatus(t, w, 200)
@@ -307,10 +597,871 @@ func TestRemoveDoesNotExist(t *testing.T) {
 	defer store.Disconnect(context.Background())
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("DELETE", "/v1/remove/Hello", nil)
+	req, _ := http.NewRequest("DELETE", "/v1/remove/hello", nil)
 	router.ServeHTTP(w, req)
 
 	assertStatus(t, w, 400)
 
 	assertBodyErrorMessage(t, w, "storage: post does not exists")
 }
+
+func assertPage(t *testing.T, w *httptest.ResponseRecorder, expectedTitles []string) storage.Page {
+	var page storage.Page
+
+	err := json.Unmarshal(w.Body.Bytes(), &page)
+
+	if err != nil {
+		t.Errorf("Invalid json body: %s", err.Error())
+	}
+
+	var titles []string
+
+	for _, post := range page.Posts {
+		titles = append(titles, post.Title)
+	}
+
+	if strings.Join(titles, ",") != strings.Join(expectedTitles, ",") {
+		t.Errorf("Expected titles %v but got %v", expectedTitles, titles)
+	}
+
+	return page
+}
+
+func TestList(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	for _, title := range []string{"Hello", "World", "Golang"} {
+		post, _ := store.Insert(context.Background(), models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+		publish(t, store, post.Slug)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/posts?limit=2", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	page := assertPage(t, w, []string{"Golang", "Hello"})
+
+	nextLink := fmt.Sprintf(`</v1/posts?cursor=%s&limit=2>; rel="next"`, page.Next)
+
+	if w.Header().Get("Link") != nextLink {
+		t.Errorf("Expected Link header to be %s but got %s", nextLink, w.Header().Get("Link"))
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/posts?limit=2&cursor="+page.Next, nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	page = assertPage(t, w, []string{"World"})
+
+	prevLink := fmt.Sprintf(`</v1/posts?cursor=%s&limit=2>; rel="prev"`, page.Prev)
+
+	if w.Header().Get("Link") != prevLink {
+		t.Errorf("Expected Link header to be %s but got %s", prevLink, w.Header().Get("Link"))
+	}
+}
+
+func TestListBadLimit(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/posts?limit=many", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+}
+
+func TestListBadCursor(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/posts?cursor=bad", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+
+	assertBodyErrorMessage(t, w, "storage: invalid cursor")
+}
+
+func TestListByTaxonomy(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	store.Insert(context.Background(), models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world", Tags: []string{"go"}})
+	store.Insert(context.Background(), models.Post{Title: "World", Name: "Vishnu", Content: "Hello world", Category: "news"})
+	store.Insert(context.Background(), models.Post{Title: "Golang", Name: "Vishnu", Content: "Hello world", Tags: []string{"go"}, Category: "news"})
+
+	for _, slug := range []string{"hello", "world", "golang"} {
+		publish(t, store, slug)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/posts?tag=go", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertPage(t, w, []string{"Golang", "Hello"})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/posts?category=news", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertPage(t, w, []string{"Golang", "World"})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/posts?tag=not+a+tag", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, "storage: invalid tag or category")
+}
+
+func TestCreateInvalidTag(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	body, _ := json.Marshal(models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world", Tags: []string{"not a tag"}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/create", bytes.NewReader(body))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, "storage: invalid tag or category")
+	assertCount(t, store, 0)
+}
+
+func assertFacets(t *testing.T, router *gin.Engine, path string, expected string) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	if w.Body.String() != expected {
+		t.Errorf("Expected %s body to be %s but got %s", path, expected, w.Body.String())
+	}
+}
+
+func TestTagsAndCategories(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	assertFacets(t, router, "/v1/tags", "[]")
+
+	store.Insert(context.Background(), models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world", Tags: []string{"go", "web"}})
+	store.Insert(context.Background(), models.Post{Title: "Golang", Name: "Vishnu", Content: "Hello world", Tags: []string{"go"}, Category: "news"})
+
+	assertFacets(t, router, "/v1/tags", `[{"value":"go","count":2},{"value":"web","count":1}]`)
+	assertFacets(t, router, "/v1/categories", `[{"value":"news","count":1}]`)
+}
+
+func TestRenameTags(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	store.Insert(context.Background(), models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world", Tags: []string{"golang", "web"}})
+	store.Insert(context.Background(), models.Post{Title: "Golang", Name: "Vishnu", Content: "Hello world", Tags: []string{"go"}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/rename-tags", strings.NewReader(`{"from":["golang","web"],"to":"go"}`))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	if w.Body.String() != `{"renamed":1}` {
+		t.Errorf("Expected one renamed post but got %s", w.Body.String())
+	}
+
+	assertFacets(t, router, "/v1/tags", `[{"value":"go","count":2}]`)
+
+	for _, body := range []string{`{"from":[],"to":"go"}`, `{"from":["go"],"to":"not a tag"}`, `not json`} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/v1/rename-tags", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 400)
+	}
+}
+
+func TestWorkflow(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		// Drafts are not public
+		{"GET", "/v1/find/hello", 400},
+		{"POST", "/v1/publish/hello", 409},
+		{"POST", "/v1/submit/hello", 200},
+		{"POST", "/v1/draft/hello", 200},
+		{"POST", "/v1/submit/hello", 200},
+		{"GET", "/v1/find/hello", 400},
+		{"POST", "/v1/publish/hello", 200},
+		{"GET", "/v1/find/hello", 200},
+		{"POST", "/v1/submit/hello", 409},
+		{"POST", "/v1/archive/hello", 200},
+		{"GET", "/v1/find/hello", 400},
+		{"POST", "/v1/archive/world", 400},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("Expected %s %s to respond %d but got %d", test.method, test.path, test.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/draft/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertETag(t, w, `"1"`)
+
+	var post models.Post
+
+	err := json.Unmarshal(w.Body.Bytes(), &post)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if post.Status != models.StatusDraft || post.PublishedAt == nil {
+		t.Errorf("Expected draft that was published but got %v", post)
+	}
+}
+
+func TestWorkflowInvalidTransition(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/archive/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 409)
+	assertBodyErrorMessage(t, w, "storage: invalid status transition")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/submit/hello", nil)
+	req.Header.Set("If-Match", `"2"`)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 412)
+}
+
+func TestStatusList(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	for _, title := range []string{"Hello", "World", "Golang"} {
+		store.Insert(context.Background(), models.Post{Title: title, Name: "Vishnu", Content: "Golang is awesome!!"})
+	}
+
+	publish(t, store, "world")
+
+	for path, expectedTitles := range map[string][]string{
+		"/v1/posts":            {"World"},
+		"/v1/status/draft":     {"Golang", "Hello"},
+		"/v1/status/published": {"World"},
+		"/v1/status/archived":  nil,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 200)
+		assertPage(t, w, expectedTitles)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/status/deleted", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, "storage: invalid status")
+
+	// Search only finds published posts
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/search?q=golang", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var page storage.SearchPage
+
+	err := json.Unmarshal(w.Body.Bytes(), &page)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if len(page.Results) != 1 || page.Results[0].Post.Title != "World" {
+		t.Errorf("Expected only the published post but got %+v", page.Results)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		// Only posts in review can be scheduled
+		{"PUT", "/v1/schedule/hello", `{"publishAt":"2030-01-01T12:00:00Z"}`, 409},
+		{"POST", "/v1/submit/hello", "", 200},
+		{"PUT", "/v1/schedule/hello", `{}`, 400},
+		{"PUT", "/v1/schedule/hello", `{"publishAt":"tomorrow"}`, 400},
+		{"PUT", "/v1/schedule/world", `{"publishAt":"2030-01-01T12:00:00Z"}`, 400},
+		{"DELETE", "/v1/schedule/hello", "", 400},
+		{"PUT", "/v1/schedule/hello", `{"publishAt":"2030-01-01T12:00:00Z"}`, 200},
+		{"PUT", "/v1/schedule/hello", `{"publishAt":"2030-01-02T12:00:00+02:00"}`, 200},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		router.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("Expected %s %s %s to respond %d but got %d", test.method, test.path, test.body, test.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/scheduled", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var scheduledPosts []models.Post
+
+	err := json.Unmarshal(w.Body.Bytes(), &scheduledPosts)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	publishAt := time.Date(2030, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	if len(scheduledPosts) != 1 || !scheduledPosts[0].PublishAt.Equal(publishAt) {
+		t.Fatalf("Expected hello to be rescheduled at %s but got %v", publishAt, scheduledPosts)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/v1/schedule/hello", nil)
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/scheduled", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	if w.Body.String() != "[]" {
+		t.Errorf("Expected no scheduled posts but got %s", w.Body.String())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	for _, title := range []string{"Golang", "Hello", "World"} {
+		post, _ := store.Insert(context.Background(), models.Post{Title: title, Name: "Vishnu", Content: "Golang <is> awesome!!"})
+		publish(t, store, post.Slug)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/search?q=golang&limit=2", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var page storage.SearchPage
+
+	err := json.Unmarshal(w.Body.Bytes(), &page)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if len(page.Results) != 2 || page.Results[0].Post.Title != "Golang" {
+		t.Fatalf("Expected golang to be the best of 2 results but got %+v", page.Results)
+	}
+
+	if page.Results[0].Snippet != "<mark>Golang</mark> &lt;is&gt; awesome!!" {
+		t.Errorf("Expected highlighted snippet but got %s", page.Results[0].Snippet)
+	}
+
+	nextLink := fmt.Sprintf(`</v1/search?cursor=%s&limit=2&q=golang>; rel="next"`, page.Next)
+
+	if w.Header().Get("Link") != nextLink {
+		t.Errorf("Expected Link header %s but got %s", nextLink, w.Header().Get("Link"))
+	}
+}
+
+func TestSearchBadQuery(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	for _, path := range []string{"/v1/search", "/v1/search?q=the", "/v1/search?q=golang&cursor=bad", "/v1/search?q=golang&limit=many"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 400)
+	}
+}
+
+// insertRevisions inserts a post and modifies its content once for each of
+// contents, giving it len(contents)+1 revisions
+func insertRevisions(t *testing.T, store storage.Storage, post models.Post, contents ...string) {
+	insertedPost, err := store.Insert(context.Background(), post)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range contents {
+		post.Content = content
+
+		_, err = store.Modify(context.Background(), insertedPost.Slug, storage.AnyVersion, post)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost, "Golang is awesome!!")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/revisions/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var revisions []models.Revision
+
+	err := json.Unmarshal(w.Body.Bytes(), &revisions)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if len(revisions) != 2 || revisions[0].Content != "Hello world" || revisions[1].Number != 2 {
+		t.Errorf("Expected two revisions of %v but got %+v", testPost, revisions)
+	}
+}
+
+func TestRevisionsDoesNotExist(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/revisions/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrDoesNotExist.Error())
+}
+
+func TestRevision(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost, "Golang is awesome!!")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/revision/hello/1", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var revision models.Revision
+
+	err := json.Unmarshal(w.Body.Bytes(), &revision)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if revision.Number != 1 || revision.Content != "Hello world" || revision.Author != "Vishnu" {
+		t.Errorf("Expected first revision of %v but got %+v", testPost, revision)
+	}
+}
+
+func TestRevisionBadNumber(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	for _, number := range []string{"one", "0", "-1"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/revision/hello/"+number, nil)
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 400)
+		assertBodyErrorMessage(t, w, "invalid revision number")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/revision/hello/2", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrRevisionDoesNotExist.Error())
+}
+
+func TestDiff(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world\nThis is golang!!"}
+	insertRevisions(t, store, testPost, "Hello world\nGolang is awesome!!")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/diff/hello/1/2", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var revisionDiff RevisionDiff
+
+	err := json.Unmarshal(w.Body.Bytes(), &revisionDiff)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if revisionDiff.From.Number != 1 || revisionDiff.To.Number != 2 {
+		t.Errorf("Expected diff from revision 1 to 2 but got %d to %d", revisionDiff.From.Number, revisionDiff.To.Number)
+	}
+
+	expected := `[{"op":"equal","text":"Hello world"},{"op":"delete","text":"This is golang!!"},{"op":"insert","text":"Golang is awesome!!"}]`
+	content, _ := json.Marshal(revisionDiff.Content)
+
+	if string(content) != expected {
+		t.Errorf("Expected diff to be %s but got %s", expected, content)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/diff/hello/1/3", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrRevisionDoesNotExist.Error())
+}
+
+func TestRestoreRevision(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost, "Golang is awesome!!")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/restore/hello/1", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertBody(t, w, testPost)
+	assertPost(t, store, 0, testPost)
+
+	revisions, err := store.Revisions(context.Background(), "hello")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 3 {
+		t.Errorf("Expected restore to add a revision but got %d revisions", len(revisions))
+	}
+}
+
+func TestRestoreRevisionDoesNotExist(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/restore/hello/1", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrDoesNotExist.Error())
+}
+
+func assertETag(t *testing.T, w *httptest.ResponseRecorder, expected string) {
+	if w.Header().Get("ETag") != expected {
+		t.Errorf("Expected ETag to be %s but got %s", expected, w.Header().Get("ETag"))
+	}
+}
+
+func TestFindETag(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, "Golang is awesome!!")
+	publish(t, store, "hello")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/find/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertETag(t, w, `"2"`)
+}
+
+func TestModifyIfMatch(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost)
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	testBody, _ := json.Marshal(testPostModify)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertBody(t, w, testPostModify)
+	assertETag(t, w, `"2"`)
+
+	// The version the client read is now stale
+	testPostStale := testPost
+	testPostStale.Content = "This is golang!!"
+
+	testBody, _ = json.Marshal(testPostStale)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 412)
+	assertBodyErrorMessage(t, w, storage.ErrVersionConflict.Error())
+	assertPost(t, store, 0, testPostModify)
+}
+
+func TestModifyIfMatchInvalid(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost)
+
+	testBody, _ := json.Marshal(testPost)
+
+	for _, header := range []string{`W/"1"`, "1", `"one"`, `"0"`, `"1", "2"`} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+		req.Header.Set("If-Match", header)
+		router.ServeHTTP(w, req)
+
+		assertStatus(t, w, 412)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/v1/modify/hello", bytes.NewBuffer(testBody))
+	req.Header.Set("If-Match", "*")
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+}
+
+func TestRemoveIfMatch(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, "Golang is awesome!!")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/v1/remove/hello", nil)
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 412)
+	assertCount(t, store, 1)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/v1/remove/hello", nil)
+	req.Header.Set("If-Match", `"2"`)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertCount(t, store, 0)
+}
+
+func TestTrash(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/trash", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertBodyErrorMessage(t, w, "[]")
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost)
+
+	req, _ = http.NewRequest("DELETE", "/v1/remove/hello", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/trash", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	var trashedPosts []models.Post
+
+	err := json.Unmarshal(w.Body.Bytes(), &trashedPosts)
+
+	if err != nil {
+		t.Fatalf("Invalid json body: %s", err.Error())
+	}
+
+	if len(trashedPosts) != 1 || !trashedPosts[0].IsEqual(testPost) || trashedPosts[0].DeletedAt == nil {
+		t.Errorf("Expected trash to hold %v but got %v", testPost, trashedPosts)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	insertRevisions(t, store, testPost)
+
+	req, _ := http.NewRequest("DELETE", "/v1/remove/hello", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assertCount(t, store, 0)
+
+	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/restore/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertBody(t, w, testPost)
+	assertPost(t, store, 0, testPost)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/restore/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertBodyErrorMessage(t, w, storage.ErrDoesNotExist.Error())
+}
+
+func TestPurge(t *testing.T) {
+	router, store := getRouterAndStorage()
+	defer store.Disconnect(context.Background())
+
+	insertRevisions(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	// Posts have to be removed before they can be purged
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/v1/purge/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 400)
+	assertCount(t, store, 1)
+
+	req, _ = http.NewRequest("DELETE", "/v1/remove/hello", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/v1/purge/hello", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+
+	trashedPosts, err := store.Trash(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trashedPosts) != 0 {
+		t.Errorf("Expected trash to be empty but got %v", trashedPosts)
+	}
+}
+
+func TestCreateRoutesAuthentication(t *testing.T) {
+	store := storage.CreateMemoryStore()
+	defer store.Disconnect(context.Background())
+
+	keys := auth.NewAPIKeys(map[string]auth.Principal{"secret": {Subject: "vishnu", Role: models.RoleEditor}})
+
+	router := gin.Default()
+	CreateRoutes(store, storage.CreateMemoryAuthorStore(), storage.CreateMemoryCommentStore(), router, auth.Middleware(keys, auth.PublicReads...))
+
+	testBody, _ := json.Marshal(models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/create", bytes.NewReader(testBody))
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 401)
+	assertBodyErrorMessage(t, w, "auth: missing credentials")
+	assertCount(t, store, 0)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/v1/create", bytes.NewReader(testBody))
+	req.Header.Set(auth.APIKeyHeader, "secret")
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+	assertCount(t, store, 1)
+
+	// Reads stay public
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/v1/posts", nil)
+	router.ServeHTTP(w, req)
+
+	assertStatus(t, w, 200)
+}
@@ -0,0 +1,89 @@
+// Package scheduler publishes posts at the time they are scheduled for
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"examples/bloggy/pkg/storage"
+)
+
+// Clock tells the time and waits for it to pass, tests replace the system
+// clock with one they advance by hand
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the wall clock
+var SystemClock Clock = systemClock{}
+
+// Scheduler publishes posts once they are due. Schedules are kept by the
+// store, so they survive restarts and are shared by every server using it
+type Scheduler struct {
+	store    storage.Storage
+	clock    Clock
+	interval time.Duration
+}
+
+// New creates a scheduler that checks store for due posts when the next one
+// is due and at least every interval, to pick up schedules made meanwhile
+func New(store storage.Storage, clock Clock, interval time.Duration) *Scheduler {
+	return &Scheduler{store, clock, interval}
+}
+
+// publishDue publishes the posts that are due and returns when the next one
+// is, zero if no post is scheduled
+func (s *Scheduler) publishDue(ctx context.Context) (time.Time, error) {
+	_, err := s.store.PublishDue(ctx, s.clock.Now())
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	scheduledPosts, err := s.store.Scheduled(ctx)
+
+	if err != nil || len(scheduledPosts) == 0 {
+		return time.Time{}, err
+	}
+
+	return *scheduledPosts[0].PublishAt, nil
+}
+
+// Run publishes posts as they become due until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		wait := s.interval
+
+		next, err := s.publishDue(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			log.Printf("ERROR Unable to publish scheduled posts: %s\n", err)
+		}
+
+		if !next.IsZero() {
+			untilNext := next.Sub(s.clock.Now())
+
+			if untilNext < wait {
+				wait = untilNext
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(wait):
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// fakeClock only moves when advanced, every wait started on it is sent to
+// waits so tests can follow the scheduler
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	waits  chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waits: make(chan time.Duration, 16)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	timer := fakeTimer{c.now.Add(d), make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	c.mu.Unlock()
+
+	c.waits <- d
+
+	return timer.c
+}
+
+// Advance moves the clock forward by d, firing the timers that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+
+	c.timers = pending
+}
+
+// expectWait waits for the scheduler to start waiting for expected
+func expectWait(t *testing.T, clock *fakeClock, expected time.Duration) {
+	t.Helper()
+
+	select {
+	case d := <-clock.waits:
+		if d != expected {
+			t.Errorf("Expected scheduler to wait %s but got %s", expected, d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected scheduler to wait")
+	}
+}
+
+// schedule inserts a post in review scheduled to be published at at
+func schedule(t *testing.T, store storage.Storage, title string, at time.Time) models.Post {
+	ctx := context.Background()
+
+	post, err := store.Insert(ctx, models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+
+	if err == nil {
+		_, err = store.Transition(ctx, post.Slug, storage.AnyVersion, models.StatusInReview)
+	}
+
+	if err == nil {
+		post, err = store.Schedule(ctx, post.Slug, storage.AnyVersion, &at)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return post
+}
+
+func assertPublishedAt(t *testing.T, store storage.Storage, slug string, at time.Time) {
+	t.Helper()
+
+	post, err := store.Find(context.Background(), slug)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.Status != models.StatusPublished || post.PublishedAt == nil || !post.PublishedAt.Equal(at) {
+		t.Errorf("Expected %s to be published at %s but got %v", slug, at, post)
+	}
+}
+
+func assertStatus(t *testing.T, store storage.Storage, slug string, status models.Status) {
+	t.Helper()
+
+	post, err := store.Find(context.Background(), slug)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.Status != status {
+		t.Errorf("Expected %s to be %s but got %s", slug, status, post.Status)
+	}
+}
+
+// run starts s, the returned function stops it and waits for it to return
+func run(s *Scheduler) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestSchedulerPublishesWhenDue(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := storage.CreateMemoryStore()
+	clock := newFakeClock(start)
+
+	schedule(t, store, "Hello", start.Add(time.Hour))
+	schedule(t, store, "World", start.Add(3*time.Hour))
+
+	stop := run(New(store, clock, 24*time.Hour))
+	defer stop()
+
+	// The scheduler sleeps until the next post is due
+	expectWait(t, clock, time.Hour)
+	assertStatus(t, store, "hello", models.StatusInReview)
+
+	clock.Advance(time.Hour)
+
+	expectWait(t, clock, 2*time.Hour)
+	assertPublishedAt(t, store, "hello", start.Add(time.Hour))
+	assertStatus(t, store, "world", models.StatusInReview)
+
+	clock.Advance(2 * time.Hour)
+
+	expectWait(t, clock, 24*time.Hour)
+	assertPublishedAt(t, store, "world", start.Add(3*time.Hour))
+}
+
+func TestSchedulerPicksUpNewSchedules(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := storage.CreateMemoryStore()
+	clock := newFakeClock(start)
+
+	stop := run(New(store, clock, 10*time.Minute))
+	defer stop()
+
+	expectWait(t, clock, 10*time.Minute)
+
+	// Scheduled while the scheduler waits, it is published on the next check
+	schedule(t, store, "Hello", start.Add(5*time.Minute))
+	cancelled := schedule(t, store, "World", start.Add(5*time.Minute))
+
+	_, err := store.Schedule(context.Background(), cancelled.Slug, storage.AnyVersion, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(10 * time.Minute)
+
+	expectWait(t, clock, 10*time.Minute)
+	assertPublishedAt(t, store, "hello", start.Add(5*time.Minute))
+	assertStatus(t, store, "world", models.StatusInReview)
+}
+
+func TestSchedulerSurvivesRestart(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	store, err := storage.CreateDurableMemoryStore(dir, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule(t, store, "Hello", start.Add(time.Hour))
+
+	err = store.Disconnect(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The post became due while the server was down
+	store, err = storage.CreateDurableMemoryStore(dir, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Disconnect(context.Background())
+
+	clock := newFakeClock(start.Add(2 * time.Hour))
+
+	stop := run(New(store, clock, time.Hour))
+	defer stop()
+
+	expectWait(t, clock, time.Hour)
+	assertPublishedAt(t, store, "hello", start.Add(time.Hour))
+}
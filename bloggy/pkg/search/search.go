@@ -0,0 +1,334 @@
+// Package search implements full-text search: tokenisation, stemming, an
+// inverted index ranked by BM25 and highlighted snippets
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// stopWords are too common to be worth indexing
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true,
+	"their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// Token is a word of a text along with its byte offsets in the text
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// fold lowercases word and strips its diacritics
+func fold(word string) string {
+	var b strings.Builder
+
+	for _, r := range norm.NFKD.String(word) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return b.String()
+}
+
+// words splits text into runs of letters and digits, folded but not stemmed.
+// Stop words are skipped
+func words(text string) []Token {
+	var tokens []Token
+
+	start := -1
+
+	for i, r := range text + " " {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+
+		if inWord && start < 0 {
+			start = i
+		}
+
+		if inWord || start < 0 {
+			continue
+		}
+
+		word := fold(text[start:i])
+
+		if !stopWords[word] {
+			tokens = append(tokens, Token{word, start, i})
+		}
+
+		start = -1
+	}
+
+	return tokens
+}
+
+// Tokenize splits text into the tokens it is indexed by, with stemmed terms
+func Tokenize(text string) []Token {
+	tokens := words(text)
+
+	for i := range tokens {
+		tokens[i].Term = Stem(tokens[i].Term)
+	}
+
+	return tokens
+}
+
+// Words returns the distinct folded words of a query, for backends that stem
+// words themselves
+func Words(query string) []string {
+	var distinct []string
+
+	seen := map[string]bool{}
+
+	for _, token := range words(query) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			distinct = append(distinct, token.Term)
+		}
+	}
+
+	return distinct
+}
+
+// Terms returns the distinct stemmed terms of a query
+func Terms(query string) []string {
+	var distinct []string
+
+	seen := map[string]bool{}
+
+	for _, token := range Tokenize(query) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			distinct = append(distinct, token.Term)
+		}
+	}
+
+	return distinct
+}
+
+// BM25 parameters, k1 saturates term frequency and b normalises it by
+// document length
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Field is text indexed with a weight, a term in a field of weight 2 counts
+// as if it occurred twice
+type Field struct {
+	Text   string
+	Weight int
+}
+
+// Hit is a document matching a search, with its relevance
+type Hit struct {
+	Key   string
+	Score float64
+}
+
+// Index is an inverted index of documents identified by key. It is not safe
+// for concurrent use
+type Index struct {
+	postings map[string]map[string]int
+	terms    map[string][]string
+	lengths  map[string]int
+	total    int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[string]int{},
+		terms:    map[string][]string{},
+		lengths:  map[string]int{},
+	}
+}
+
+// Add indexes the fields of the document with key, replacing any document
+// already indexed with key
+func (x *Index) Add(key string, fields ...Field) {
+	x.Remove(key)
+
+	frequencies := map[string]int{}
+	length := 0
+
+	for _, field := range fields {
+		for _, token := range Tokenize(field.Text) {
+			frequencies[token.Term] += field.Weight
+			length += field.Weight
+		}
+	}
+
+	for term, frequency := range frequencies {
+		if x.postings[term] == nil {
+			x.postings[term] = map[string]int{}
+		}
+
+		x.postings[term][key] = frequency
+		x.terms[key] = append(x.terms[key], term)
+	}
+
+	x.lengths[key] = length
+	x.total += length
+}
+
+// Remove removes the document with key from the index
+func (x *Index) Remove(key string) {
+	length, ok := x.lengths[key]
+
+	if !ok {
+		return
+	}
+
+	for _, term := range x.terms[key] {
+		delete(x.postings[term], key)
+
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+
+	delete(x.terms, key)
+	delete(x.lengths, key)
+	x.total -= length
+}
+
+// Search returns the documents containing any of terms, most relevant first
+// and ties broken by key
+func (x *Index) Search(terms []string) []Hit {
+	if len(x.lengths) == 0 {
+		return nil
+	}
+
+	n := float64(len(x.lengths))
+	avgLength := float64(x.total) / n
+
+	scores := map[string]float64{}
+
+	for _, term := range terms {
+		postings := x.postings[term]
+		idf := math.Log(1 + (n-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+
+		for key, frequency := range postings {
+			tf := float64(frequency)
+			lengthNorm := 1 - b + b*float64(x.lengths[key])/avgLength
+
+			scores[key] += idf * tf * (k1 + 1) / (tf + k1*lengthNorm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+
+	for key, score := range scores {
+		hits = append(hits, Hit{key, score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+
+		return hits[i].Key < hits[j].Key
+	})
+
+	return hits
+}
+
+// Snippet returns an HTML escaped excerpt of text of about size bytes around
+// the densest cluster of terms, with the matches wrapped in <mark> elements.
+// Cut text is marked with an ellipsis
+func Snippet(text string, terms []string, size int) string {
+	tokens := Tokenize(text)
+
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	wanted := map[string]bool{}
+
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	// Find the window starting at a match which holds the most matches
+	first, best := 0, 0
+
+	for i, token := range tokens {
+		if !wanted[token.Term] {
+			continue
+		}
+
+		count := 0
+
+		for _, other := range tokens[i:] {
+			if other.Start >= token.Start+size {
+				break
+			}
+
+			if wanted[other.Term] {
+				count++
+			}
+		}
+
+		if count > best {
+			first, best = i, count
+		}
+	}
+
+	// Lead into the first match with a few words of context
+	start := first
+
+	for start > 0 && tokens[first].Start-tokens[start-1].Start <= size/4 {
+		start--
+	}
+
+	end := start
+
+	for end+1 < len(tokens) && tokens[end+1].End-tokens[start].Start <= size {
+		end++
+	}
+
+	// Use up what is left of size on more context before the match
+	for start > 0 && tokens[end].End-tokens[start-1].Start <= size {
+		start--
+	}
+
+	var snippet strings.Builder
+
+	offset := 0
+
+	if start > 0 {
+		snippet.WriteString("…")
+		offset = tokens[start].Start
+	}
+
+	for _, token := range tokens[start : end+1] {
+		snippet.WriteString(html.EscapeString(text[offset:token.Start]))
+
+		word := html.EscapeString(text[token.Start:token.End])
+
+		if wanted[token.Term] {
+			word = "<mark>" + word + "</mark>"
+		}
+
+		snippet.WriteString(word)
+		offset = token.End
+	}
+
+	if end < len(tokens)-1 {
+		snippet.WriteString("…")
+	} else {
+		snippet.WriteString(html.EscapeString(text[offset:]))
+	}
+
+	return snippet.String()
+}
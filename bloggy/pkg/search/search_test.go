@@ -0,0 +1,127 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"agreed":         "agre",
+		"running":        "run",
+		"hopping":        "hop",
+		"hoping":         "hope",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"hopeful":        "hope",
+		"goodness":       "good",
+		"adjustment":     "adjust",
+		"controlling":    "control",
+		"concurrency":    "concurr",
+		"concurrent":     "concurr",
+		"go":             "go",
+		"golang":         "golang",
+		"café":           "café",
+	}
+
+	for word, expected := range tests {
+		if Stem(word) != expected {
+			t.Errorf("Expected stem of %s to be %s but got %s", word, expected, Stem(word))
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	text := "The Crème brûlée, it's RUNNING!"
+
+	tokens := Tokenize(text)
+
+	expected := []Token{{"creme", 4, 10}, {"brule", 11, 19}, {"s", 24, 25}, {"run", 26, 33}}
+
+	if fmt.Sprint(tokens) != fmt.Sprint(expected) {
+		t.Errorf("Expected tokens %v but got %v", expected, tokens)
+	}
+
+	for _, token := range tokens {
+		if fold(text[token.Start:token.End]) == "" {
+			t.Errorf("Expected offsets of %v to be a word of the text", token)
+		}
+	}
+}
+
+func TestTermsAndWords(t *testing.T) {
+	if fmt.Sprint(Terms("Running runs, the RUN")) != "[run]" {
+		t.Errorf("Expected distinct stemmed terms but got %v", Terms("Running runs, the RUN"))
+	}
+
+	if fmt.Sprint(Words("Running runs, the RUN")) != "[running runs run]" {
+		t.Errorf("Expected distinct folded words but got %v", Words("Running runs, the RUN"))
+	}
+}
+
+func TestIndex(t *testing.T) {
+	x := NewIndex()
+
+	x.Add("title", Field{"Golang", 10}, Field{"Hello world", 1})
+	x.Add("once", Field{"Hello", 10}, Field{"Golang is awesome, the world is big and golang is fun", 1})
+	x.Add("twice", Field{"World", 10}, Field{"Golang golang", 1})
+	x.Add("none", Field{"Hello", 10}, Field{"Hello world", 1})
+
+	hits := x.Search([]string{"golang"})
+
+	var keys []string
+
+	for _, hit := range hits {
+		keys = append(keys, hit.Key)
+	}
+
+	// Titles weigh more and shorter documents rank higher
+	if fmt.Sprint(keys) != "[title twice once]" {
+		t.Errorf("Expected hits [title twice once] but got %v", keys)
+	}
+
+	// Replacing and removing documents updates the postings
+	x.Add("title", Field{"Rust", 10})
+	x.Remove("twice")
+
+	hits = x.Search([]string{"golang"})
+
+	if len(hits) != 1 || hits[0].Key != "once" {
+		t.Errorf("Expected only once to match but got %v", hits)
+	}
+
+	x.Remove("once")
+	x.Remove("title")
+	x.Remove("none")
+
+	if len(x.postings) != 0 || x.total != 0 {
+		t.Errorf("Expected empty index but got %d postings of total length %d", len(x.postings), x.total)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		text     string
+		query    string
+		size     int
+		expected string
+	}{
+		{"Hello <golang> world", "golang", 80, "Hello &lt;<mark>golang</mark>&gt; world"},
+		{"No match here", "golang", 80, "No match here"},
+		{"One two three four five six seven eight nine ten", "ten", 20, "…seven eight nine <mark>ten</mark>"},
+		{"Running late. Runners run, one two three four five six", "run", 30, "<mark>Running</mark> late. Runners <mark>run</mark>, one…"},
+		{"", "golang", 80, ""},
+	}
+
+	for _, test := range tests {
+		snippet := Snippet(test.text, Terms(test.query), test.size)
+
+		if snippet != test.expected {
+			t.Errorf("Expected snippet of %q to be %q but got %q", test.text, test.expected, snippet)
+		}
+	}
+}
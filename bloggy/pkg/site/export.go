@@ -0,0 +1,449 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// ManifestFile is kept in the directory of an export to record what it
+// wrote, so that the next export only rewrites what changed since
+const ManifestFile = ".bloggy-export.json"
+
+// NotFoundURL is the URL of the page static file servers are expected to
+// serve for missing pages
+const NotFoundURL = "/404.html"
+
+// manifest records the digest of the theme and the options an export used
+// along with the fingerprint of every page it wrote by their URL
+type manifest struct {
+	Theme   string            `json:"theme"`
+	Options Options           `json:"options"`
+	Pages   map[string]string `json:"pages"`
+}
+
+// ExportResult counts the pages an export wrote, left as they were and
+// removed as they are no longer part of the site
+type ExportResult struct {
+	Written   int
+	Unchanged int
+	Removed   int
+}
+
+// staticPage is a page of an export, or the feed in format of the posts of
+// page if format is set, or file as is if set. The HTML of posts is only
+// rendered when the page is written
+type staticPage struct {
+	url      string
+	template string
+	page     Page
+	format   string
+	file     []byte
+}
+
+// fingerprint hashes what page is built from. It changes whenever one of
+// the posts shown on the page, or the page links to, does
+func (page staticPage) fingerprint() (string, error) {
+	raw, err := json.Marshal(page.page)
+
+	if err != nil {
+		return "", err
+	}
+
+	if page.file != nil {
+		raw = page.file
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(page.template + " " + page.format))
+	hash.Write(raw)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// PageURL is the URL of page number n, starting at 1, of the list at base
+func PageURL(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+
+	return base + "page/" + strconv.Itoa(n) + "/"
+}
+
+// pagedList splits posts, newest first, into the pages of the list at base
+// rendered with the template name. Each page is a copy of list with a share
+// of the posts
+func pagedList(base string, name string, list Page, posts []models.Post) []staticPage {
+	var pages []staticPage
+
+	for n := 1; n == 1 || (n-1)*storage.DefaultPageLimit < len(posts); n++ {
+		start := (n - 1) * storage.DefaultPageLimit
+		end := start + storage.DefaultPageLimit
+
+		if end > len(posts) {
+			end = len(posts)
+		}
+
+		page := list
+		page.Posts = newPosts(posts[start:end])
+
+		if n > 1 {
+			page.Prev = PageURL(base, n-1)
+		}
+
+		if end < len(posts) {
+			page.Next = PageURL(base, n+1)
+		}
+
+		pages = append(pages, staticPage{url: PageURL(base, n), template: name, page: page})
+	}
+
+	return pages
+}
+
+// pagedEntries lists the pages of a paginated list beyond the first for
+// the sitemap, they changed when the newest of their posts did
+func pagedEntries(list []staticPage) []sitemapEntry {
+	var entries []sitemapEntry
+
+	for _, page := range list[1:] {
+		posts := make([]models.Post, 0, len(page.page.Posts))
+
+		for _, post := range page.page.Posts {
+			posts = append(posts, post.Post)
+		}
+
+		entries = append(entries, sitemapEntry{page.url, newest(posts)})
+	}
+
+	return entries
+}
+
+// staticPages lays out the pages of a site of the published posts, newest
+// first. Lists are paginated by number as a static site has no cursors
+func (site *Site) staticPages(posts []models.Post) ([]staticPage, error) {
+	pages := pagedList("/", TemplateIndex, Page{Site: site.opts.Title, Title: site.opts.Title}, posts)
+
+	// The sitemap lists the pages of lists beyond the first as well, only
+	// exports have them
+	entries := append(sitemapEntries(posts), pagedEntries(pages)...)
+
+	// Tag pages, in the order tags first show up
+	var tags []string
+	tagged := map[string][]models.Post{}
+
+	for _, post := range posts {
+		page := Page{Site: site.opts.Title, Title: post.Title}
+		p := newPost(post)
+		page.Post = &p
+
+		pages = append(pages, staticPage{url: p.URL, template: TemplatePost, page: page})
+
+		for _, tag := range post.Tags {
+			if _, ok := tagged[tag]; !ok {
+				tags = append(tags, tag)
+			}
+
+			tagged[tag] = append(tagged[tag], post)
+		}
+	}
+
+	for _, tag := range tags {
+		list := Page{Site: site.opts.Title, Title: "Posts tagged " + tag, Tag: tag}
+
+		tagPages := pagedList(TagURL(tag), TemplateTag, list, tagged[tag])
+
+		pages = append(pages, tagPages...)
+		entries = append(entries, pagedEntries(tagPages)...)
+	}
+
+	// Archive pages
+	grouped := months(posts)
+
+	archive := Page{Site: site.opts.Title, Title: "Archive", Months: grouped}
+
+	pages = append(pages, staticPage{url: "/archive/", template: TemplateArchive, page: archive})
+
+	for i := range grouped {
+		month := &grouped[i]
+		title := fmt.Sprintf("%s %d", month.Month, month.Year)
+
+		page := Page{Site: site.opts.Title, Title: title, Month: month, Posts: month.Posts}
+
+		pages = append(pages, staticPage{url: month.URL, template: TemplateMonth, page: page})
+	}
+
+	// Feeds and sitemaps need the URL of the site for their absolute links
+	if site.opts.URL != "" {
+		pages = append(pages, site.feeds("", posts)...)
+
+		for _, tag := range tags {
+			pages = append(pages, site.feeds(tag, tagged[tag])...)
+		}
+
+		sitemaps, err := site.sitemaps(entries)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, sitemaps...)
+		pages = append(pages, staticPage{url: "/robots.txt", file: site.Robots(site.opts.URL)})
+	}
+
+	return append(pages, staticPage{url: NotFoundURL, template: TemplateNotFound, page: site.NotFound()}), nil
+}
+
+// sitemaps lays out the sitemap of entries, along with the sitemaps it is
+// split into if it has too many URLs
+func (site *Site) sitemaps(entries []sitemapEntry) ([]staticPage, error) {
+	var pages []staticPage
+
+	for n := 0; n <= site.sitemapCount(entries); n++ {
+		body, _, err := site.sitemap(site.opts.URL, entries, n)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, staticPage{url: SitemapURL(n), file: body})
+	}
+
+	return pages, nil
+}
+
+// feeds lays out the feeds of the newest of posts with tag
+func (site *Site) feeds(tag string, posts []models.Post) []staticPage {
+	var pages []staticPage
+
+	if len(posts) > site.opts.FeedLimit {
+		posts = posts[:site.opts.FeedLimit]
+	}
+
+	for _, format := range feedFormats {
+		page := Page{Site: site.opts.Title, Tag: tag, Posts: newPosts(posts)}
+
+		pages = append(pages, staticPage{url: FeedURL(tag, format), page: page, format: format})
+	}
+
+	return pages
+}
+
+// exportPath is the path of the file of the page at url in dir, URLs ending
+// in a slash are written as index.html
+func exportPath(dir string, url string) string {
+	if strings.HasSuffix(url, "/") {
+		url += "index.html"
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(url))
+}
+
+// writeFile replaces the file at path with data atomically, so that a file
+// server never serves half a page
+func writeFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+
+	err = os.WriteFile(tmpPath, data, 0o644)
+
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+
+	return err
+}
+
+// writeFeed writes the feed of a static page
+func (site *Site) writeFeed(dir string, page staticPage) error {
+	posts := make([]models.Post, 0, len(page.page.Posts))
+
+	for _, post := range page.page.Posts {
+		posts = append(posts, post.Post)
+	}
+
+	f, err := site.newFeed(site.opts.URL, page.page.Tag, page.format, posts, site.opts.FeedSummary)
+
+	if err != nil {
+		return err
+	}
+
+	body, err := f.encode(page.format)
+
+	if err != nil {
+		return err
+	}
+
+	return writeFile(exportPath(dir, page.url), body)
+}
+
+func (site *Site) writePage(dir string, page staticPage) error {
+	if page.file != nil {
+		return writeFile(exportPath(dir, page.url), page.file)
+	}
+
+	if page.format != "" {
+		return site.writeFeed(dir, page)
+	}
+
+	if page.page.Post != nil {
+		built, err := site.postPage(page.page.Post.Post)
+
+		if err != nil {
+			return err
+		}
+
+		page.page = built
+	}
+
+	// Pages do not link to feeds that are not written
+	page.page.NoFeeds = site.opts.URL == ""
+
+	var buf bytes.Buffer
+
+	err := site.theme.Render(&buf, page.template, page.page)
+
+	if err != nil {
+		return err
+	}
+
+	return writeFile(exportPath(dir, page.url), buf.Bytes())
+}
+
+func readManifest(dir string) (manifest, error) {
+	var m manifest
+
+	raw, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+
+	if err != nil {
+		return m, err
+	}
+
+	err = json.Unmarshal(raw, &m)
+
+	return m, err
+}
+
+// removePage removes the file of the page at url from dir along with the
+// directories it leaves empty
+func removePage(dir string, url string) error {
+	path := exportPath(dir, url)
+
+	err := os.Remove(path)
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for parent := filepath.Dir(path); parent != filepath.Clean(dir); parent = filepath.Dir(parent) {
+		if os.Remove(parent) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Export writes the published posts of the site as static files to dir,
+// with every page a file named after its URL. Feeds, sitemaps and
+// robots.txt are only written if the site has a URL, pages then do not
+// link to feeds. Pages written by the last export to dir are only
+// rewritten if the posts they show, the theme or the options changed
+// since, pages that are no longer part of the site are removed
+func (site *Site) Export(ctx context.Context, dir string) (ExportResult, error) {
+	var result ExportResult
+
+	posts, err := site.Published(ctx)
+
+	if err != nil {
+		return result, err
+	}
+
+	previous, err := readManifest(dir)
+
+	if err != nil {
+		return result, err
+	}
+
+	// Every page is rewritten when the theme or options change
+	rebuild := previous.Theme != site.theme.digest || previous.Options != site.opts
+
+	current := manifest{Theme: site.theme.digest, Options: site.opts, Pages: map[string]string{}}
+
+	pages, err := site.staticPages(posts)
+
+	if err != nil {
+		return result, err
+	}
+
+	for _, page := range pages {
+		fingerprint, err := page.fingerprint()
+
+		if err != nil {
+			return result, err
+		}
+
+		current.Pages[page.url] = fingerprint
+
+		if !rebuild && previous.Pages[page.url] == fingerprint {
+			_, err = os.Stat(exportPath(dir, page.url))
+
+			if err == nil {
+				result.Unchanged++
+				continue
+			}
+		}
+
+		err = site.writePage(dir, page)
+
+		if err != nil {
+			return result, err
+		}
+
+		result.Written++
+	}
+
+	for url := range previous.Pages {
+		if _, ok := current.Pages[url]; ok {
+			continue
+		}
+
+		err = removePage(dir, url)
+
+		if err != nil {
+			return result, err
+		}
+
+		result.Removed++
+	}
+
+	raw, err := json.MarshalIndent(current, "", "  ")
+
+	if err != nil {
+		return result, err
+	}
+
+	return result, writeFile(filepath.Join(dir, ManifestFile), raw)
+}
@@ -0,0 +1,226 @@
+package site
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+func getSite(t *testing.T, themeDir string, store storage.Storage) *Site {
+	theme, err := LoadTheme(themeDir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(store, theme, Options{Title: "Bloggy"})
+}
+
+func export(t *testing.T, site *Site, dir string, expected ExportResult) {
+	t.Helper()
+
+	result, err := site.Export(context.Background(), dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != expected {
+		t.Errorf("Expected export to result in %+v but got %+v", expected, result)
+	}
+}
+
+// assertFile checks that the file at path in dir exists and holds contains,
+// or does not exist if contains is empty
+func assertFile(t *testing.T, dir string, path string, contains string) {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+
+	if contains == "" {
+		if !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist", path)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Errorf("Expected %s to exist: %s", path, err)
+		return
+	}
+
+	if !strings.Contains(string(raw), contains) {
+		t.Errorf("Expected %s to contain %q", path, contains)
+	}
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := storage.CreateMemoryStore()
+	defer store.Disconnect(ctx)
+
+	site := getSite(t, "", store)
+
+	// Index, archive and not found pages
+	export(t, site, dir, ExportResult{Written: 3})
+
+	assertFile(t, dir, "index.html", "Nothing has been published yet")
+	assertFile(t, dir, "archive/index.html", "Nothing has been published yet")
+	assertFile(t, dir, "404.html", "Page not found")
+	assertFile(t, dir, ManifestFile, site.theme.digest)
+
+	hello := insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello *world*", Tags: []string{"go"}}, true)
+	insert(t, store, models.Post{Title: "World", Name: "Vishnu", Content: "Hello world", Tags: []string{"rust"}}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet", Tags: []string{"draft"}}, false)
+
+	year, month, _ := hello.PublishedAt.Date()
+	monthPath := strings.TrimPrefix(MonthURL(year, month), "/") + "index.html"
+
+	// Both post and tag pages and the month page are new, the not found page
+	// did not change
+	export(t, site, dir, ExportResult{Written: 7, Unchanged: 1})
+
+	assertFile(t, dir, "index.html", `<a href="/posts/hello/">Hello</a>`)
+	assertFile(t, dir, "index.html", `<a href="/posts/world/">World</a>`)
+	assertFile(t, dir, "posts/hello/index.html", "<p>Hello <em>world</em></p>")
+	assertFile(t, dir, "tags/go/index.html", `<a href="/posts/hello/">Hello</a>`)
+	assertFile(t, dir, "archive/index.html", `<a href="/posts/world/">World</a>`)
+	assertFile(t, dir, monthPath, `<a href="/posts/hello/">Hello</a>`)
+	assertFile(t, dir, "posts/draft/index.html", "")
+	assertFile(t, dir, "tags/draft/index.html", "")
+
+	export(t, site, dir, ExportResult{Unchanged: 8})
+
+	// Pages of unchanged posts are left as they are
+	err := os.WriteFile(filepath.Join(dir, "posts", "world", "index.html"), []byte("untouched"), 0o644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello.Content = "Hello again"
+
+	_, err = store.Modify(ctx, "hello", storage.AnyVersion, hello)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The index, post, tag, archive and month pages show the modified post
+	export(t, site, dir, ExportResult{Written: 5, Unchanged: 3})
+
+	assertFile(t, dir, "posts/hello/index.html", "<p>Hello again</p>")
+	assertFile(t, dir, "posts/world/index.html", "untouched")
+
+	// Removed pages are rewritten
+	err = os.Remove(filepath.Join(dir, "posts", "world", "index.html"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export(t, site, dir, ExportResult{Written: 1, Unchanged: 7})
+
+	assertFile(t, dir, "posts/world/index.html", "<p>Hello world</p>")
+
+	// Pages of posts no longer published are removed
+	_, err = store.Transition(ctx, "hello", storage.AnyVersion, models.StatusArchived)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export(t, site, dir, ExportResult{Written: 3, Unchanged: 3, Removed: 2})
+
+	assertFile(t, dir, "posts/hello/index.html", "")
+	assertFile(t, dir, "tags/go/index.html", "")
+
+	_, err = os.Stat(filepath.Join(dir, "tags", "go"))
+
+	if !os.IsNotExist(err) {
+		t.Error("Expected directories left empty to be removed")
+	}
+
+	// Every page is rewritten with a new theme
+	themeDir := t.TempDir()
+
+	err = os.WriteFile(filepath.Join(themeDir, TemplateNotFound), []byte(`{{define "content"}}<p>Gone</p>{{end}}`), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export(t, getSite(t, themeDir, store), dir, ExportResult{Written: 6})
+
+	assertFile(t, dir, "404.html", "<p>Gone</p>")
+}
+
+func TestExportPagination(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := storage.CreateMemoryStore()
+	defer store.Disconnect(ctx)
+
+	for i := 1; i <= storage.DefaultPageLimit*2+1; i++ {
+		insert(t, store, models.Post{Title: fmt.Sprintf("Post %d", i), Name: "Vishnu", Content: "Hello", Tags: []string{"go"}}, true)
+	}
+
+	_, err := getSite(t, "", store).Export(ctx, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, base := range []string{"", "tags/go/"} {
+		assertFile(t, dir, base+"index.html", `<a href="/`+base+`page/2/">Older posts</a>`)
+		assertFile(t, dir, base+"page/2/index.html", `<a href="/`+base+`">Newer posts</a>`)
+		assertFile(t, dir, base+"page/2/index.html", `<a href="/`+base+`page/3/">Older posts</a>`)
+		assertFile(t, dir, base+"page/3/index.html", `<a href="/posts/post-1/">Post 1</a>`)
+		assertFile(t, dir, base+"page/4/index.html", "")
+	}
+
+	// Without a URL no feeds are written and pages do not link to them
+	raw, err := os.ReadFile(filepath.Join(dir, "index.html"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(raw), FeedURL("", "atom")) {
+		t.Error("Expected pages not to link to feeds that are not written")
+	}
+
+	assertFile(t, dir, strings.TrimPrefix(FeedURL("", "atom"), "/"), "")
+
+	// The sitemap lists the pages of lists beyond the first
+	dir = t.TempDir()
+
+	theme, err := LoadTheme("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(store, theme, Options{Title: "Bloggy", URL: testSiteURL}).Export(ctx, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFile(t, dir, "index.html", FeedURL("", "atom"))
+
+	for _, base := range []string{"/", "/tags/go/"} {
+		assertFile(t, dir, "sitemap.xml", "<loc>"+testSiteURL+base+"page/2/</loc>")
+		assertFile(t, dir, "sitemap.xml", "<loc>"+testSiteURL+base+"page/3/</loc>")
+	}
+}
@@ -0,0 +1,397 @@
+package site
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"time"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/render"
+	"examples/bloggy/pkg/storage"
+)
+
+// Feed formats, named after the extension of their URLs
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+)
+
+var feedFormats = []string{FeedRSS, FeedAtom, FeedJSON}
+
+var feedContentTypes = map[string]string{
+	FeedRSS:  "application/rss+xml; charset=utf-8",
+	FeedAtom: "application/atom+xml; charset=utf-8",
+	FeedJSON: "application/feed+json; charset=utf-8",
+}
+
+// summaryLength is the number of characters summaries of posts are cut at
+const summaryLength = 300
+
+// FeedURL is the URL of the feed in format of the posts with tag, or of
+// every post if tag is empty
+func FeedURL(tag string, format string) string {
+	base := "/"
+
+	if tag != "" {
+		base = TagURL(tag)
+	}
+
+	return base + "feed." + format
+}
+
+// feed holds the posts of a feed whatever its format. URLs are absolute,
+// items have either their full HTML content or a plain text summary
+type feed struct {
+	Title   string
+	URL     string
+	FeedURL string
+	Updated time.Time
+	Items   []feedItem
+}
+
+type feedItem struct {
+	ID        string
+	URL       string
+	Title     string
+	Author    string
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+	HTML      string
+	Summary   string
+}
+
+// updatedAt returns when post last changed, including being published
+func updatedAt(post models.Post) time.Time {
+	if post.PublishedAt != nil && post.PublishedAt.After(post.UpdatedAt) {
+		return *post.PublishedAt
+	}
+
+	return post.UpdatedAt
+}
+
+// tagURI is the ID of post in feeds, a tag URI as of RFC 4151 that stays
+// the same when the slug of the post changes
+func tagURI(host string, post models.Post) string {
+	return "tag:" + host + "," + post.CreatedAt.UTC().Format("2006-01-02") + ":post:" + post.ID.Hex()
+}
+
+// feedPosts returns the newest limit published posts with tag, or of every
+// post if tag is empty. Tags without published posts have no feed
+func (site *Site) feedPosts(ctx context.Context, tag string, limit int) ([]models.Post, error) {
+	list, err := site.store.List(ctx, storage.ListOptions{Status: models.StatusPublished, Tag: tag, Sort: listSort, Limit: limit})
+
+	if err == storage.ErrInvalidTag || (err == nil && tag != "" && len(list.Posts) == 0) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Posts, nil
+}
+
+// newFeed builds the feed in format of posts with tag, newest first, for
+// the site served at base. It was last modified when the newest of the
+// posts was
+func (site *Site) newFeed(base string, tag string, format string, posts []models.Post, summary bool) (feed, error) {
+	f := feed{Title: site.opts.Title, URL: base + "/", FeedURL: base + FeedURL(tag, format)}
+
+	if tag != "" {
+		f.Title += " - Posts tagged " + tag
+		f.URL = base + TagURL(tag)
+	}
+
+	parsed, err := url.Parse(base)
+
+	if err != nil {
+		return feed{}, err
+	}
+
+	for _, post := range posts {
+		html, err := site.renders.HTML(post)
+
+		if err != nil {
+			return feed{}, err
+		}
+
+		item := feedItem{
+			ID:        tagURI(parsed.Hostname(), post),
+			URL:       base + PostURL(post.Slug),
+			Title:     post.Title,
+			Author:    post.Name,
+			Tags:      post.Tags,
+			Published: publishedAt(post),
+			Updated:   updatedAt(post),
+		}
+
+		// Every entry needs an author in Atom
+		if item.Author == "" {
+			item.Author = site.opts.Title
+		}
+
+		if summary {
+			item.Summary = render.Summary(html, summaryLength)
+		} else {
+			item.HTML = html
+		}
+
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+
+		f.Items = append(f.Items, item)
+	}
+
+	return f, nil
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func rfc3339(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (f feed) rss() ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.URL,
+		Description: f.Title,
+		Self:        atomLink{Href: f.FeedURL, Rel: "self", Type: feedContentTypes[FeedRSS]},
+	}
+
+	if !f.Updated.IsZero() {
+		channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, item := range f.Items {
+		description := item.HTML
+
+		if description == "" {
+			description = item.Summary
+		}
+
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{ID: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Creator:     item.Author,
+			Categories:  item.Tags,
+			Description: description,
+		})
+	}
+
+	return marshalXML(rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+func (f feed) atom() ([]byte, error) {
+	atom := atomFeed{
+		ID:      f.FeedURL,
+		Title:   f.Title,
+		Updated: rfc3339(f.Updated),
+		Links: []atomLink{
+			{Href: f.URL, Rel: "alternate", Type: "text/html"},
+			{Href: f.FeedURL, Rel: "self", Type: feedContentTypes[FeedAtom]},
+		},
+	}
+
+	for _, item := range f.Items {
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Updated:   rfc3339(item.Updated),
+			Published: rfc3339(item.Published),
+			Link:      atomLink{Href: item.URL, Rel: "alternate", Type: "text/html"},
+			Author:    atomPerson{item.Author},
+		}
+
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{tag})
+		}
+
+		if item.HTML != "" {
+			entry.Content = &atomText{"html", item.HTML}
+		} else {
+			entry.Summary = &atomText{"text", item.Summary}
+		}
+
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return marshalXML(atom)
+}
+
+func (f feed) json() ([]byte, error) {
+	j := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.URL,
+		FeedURL:     f.FeedURL,
+		Items:       []jsonItem{},
+	}
+
+	for _, item := range f.Items {
+		j.Items = append(j.Items, jsonItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentHTML:   item.HTML,
+			ContentText:   item.Summary,
+			Summary:       item.Summary,
+			DatePublished: rfc3339(item.Published),
+			DateModified:  rfc3339(item.Updated),
+			Authors:       []jsonAuthor{{item.Author}},
+			Tags:          item.Tags,
+		})
+	}
+
+	return json.Marshal(j)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	raw, err := xml.MarshalIndent(v, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), raw...), nil
+}
+
+// encode writes the feed in format
+func (f feed) encode(format string) ([]byte, error) {
+	switch format {
+	case FeedRSS:
+		return f.rss()
+	case FeedAtom:
+		return f.atom()
+	}
+
+	return f.json()
+}
+
+// Feed writes the feed in format of the newest limit published posts with
+// tag, or of every post if tag is empty, for the site served at base. It
+// returns when the feed was last modified along with it
+func (site *Site) Feed(ctx context.Context, base string, tag string, format string, limit int, summary bool) ([]byte, time.Time, error) {
+	posts, err := site.feedPosts(ctx, tag, limit)
+
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	f, err := site.newFeed(base, tag, format, posts, summary)
+
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	body, err := f.encode(format)
+
+	return body, f.Updated, err
+}
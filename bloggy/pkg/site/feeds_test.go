@@ -0,0 +1,665 @@
+package site
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/render"
+	"examples/bloggy/pkg/storage"
+)
+
+const testSiteURL = "https://blog.example.com"
+
+// feedEntry is an entry of a feed whatever its format, Content is the HTML
+// content of the entry and Summary its plain text summary
+type feedEntry struct {
+	ID      string
+	URL     string
+	Title   string
+	Author  string
+	Tags    []string
+	Content string
+	Summary string
+}
+
+// tagURIPattern matches tag URIs as of RFC 4151
+var tagURIPattern = regexp.MustCompile(`^tag:[a-z0-9.-]+,\d{4}-\d{2}-\d{2}:\S+$`)
+
+func checkAbsoluteURL(t *testing.T, field string, raw string) {
+	t.Helper()
+
+	parsed, err := url.Parse(raw)
+
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		t.Errorf("Expected %s to be an absolute URL but got %q", field, raw)
+	}
+}
+
+func checkDate(t *testing.T, field string, layout string, raw string) {
+	t.Helper()
+
+	_, err := time.Parse(layout, raw)
+
+	if err != nil {
+		t.Errorf("Expected %s to be a date as of %s but got %q", field, layout, raw)
+	}
+}
+
+func checkRequired(t *testing.T, field string, value string) {
+	t.Helper()
+
+	if value == "" {
+		t.Errorf("Expected %s to be set", field)
+	}
+}
+
+// validateRSS checks raw against the required elements and formats of RSS
+// 2.0, see https://www.rssboard.org/rss-specification
+func validateRSS(t *testing.T, raw []byte) []feedEntry {
+	t.Helper()
+
+	var rss struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Channel struct {
+			Title         string `xml:"title"`
+			Description   string `xml:"description"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Links         []struct {
+				XMLName xml.Name
+				Href    string `xml:"href,attr"`
+				Rel     string `xml:"rel,attr"`
+				URL     string `xml:",chardata"`
+			} `xml:"link"`
+			Items []struct {
+				Title string `xml:"title"`
+				Link  string `xml:"link"`
+				GUID  struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					ID          string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate     string   `xml:"pubDate"`
+				Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Categories  []string `xml:"category"`
+				Description string   `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+
+	err := xml.Unmarshal(raw, &rss)
+
+	if err != nil {
+		t.Fatalf("Expected valid XML: %s", err)
+	}
+
+	if rss.Version != "2.0" {
+		t.Errorf("Expected RSS version 2.0 but got %q", rss.Version)
+	}
+
+	checkRequired(t, "channel title", rss.Channel.Title)
+	checkRequired(t, "channel description", rss.Channel.Description)
+
+	// The channel links to its page, and to itself by an Atom link
+	if len(rss.Channel.Links) != 2 {
+		t.Fatalf("Expected the channel to have a link and an atom:link")
+	}
+
+	for _, link := range rss.Channel.Links {
+		if link.XMLName.Space == "http://www.w3.org/2005/Atom" {
+			checkAbsoluteURL(t, "atom:link", link.Href)
+
+			if link.Rel != "self" {
+				t.Errorf("Expected atom:link to link to the feed itself")
+			}
+		} else {
+			checkAbsoluteURL(t, "channel link", link.URL)
+		}
+	}
+
+	if rss.Channel.LastBuildDate != "" {
+		checkDate(t, "lastBuildDate", time.RFC1123Z, rss.Channel.LastBuildDate)
+	}
+
+	var entries []feedEntry
+
+	for _, item := range rss.Channel.Items {
+		checkRequired(t, "item title", item.Title)
+		checkAbsoluteURL(t, "item link", item.Link)
+		checkDate(t, "pubDate", time.RFC1123Z, item.PubDate)
+
+		if item.GUID.IsPermaLink != "false" || !tagURIPattern.MatchString(item.GUID.ID) {
+			t.Errorf("Expected guid to be a tag URI but got %q", item.GUID.ID)
+		}
+
+		entries = append(entries, feedEntry{item.GUID.ID, item.Link, item.Title, item.Creator, item.Categories, item.Description, ""})
+	}
+
+	return entries
+}
+
+// validateAtom checks raw against the required elements and formats of
+// Atom, see RFC 4287
+func validateAtom(t *testing.T, raw []byte) []feedEntry {
+	t.Helper()
+
+	type text struct {
+		Type string `xml:"type,attr"`
+		Text string `xml:",chardata"`
+	}
+
+	type link struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	}
+
+	var atom struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"http://www.w3.org/2005/Atom id"`
+		Title   string   `xml:"http://www.w3.org/2005/Atom title"`
+		Updated string   `xml:"http://www.w3.org/2005/Atom updated"`
+		Links   []link   `xml:"http://www.w3.org/2005/Atom link"`
+		Entries []struct {
+			ID        string `xml:"http://www.w3.org/2005/Atom id"`
+			Title     string `xml:"http://www.w3.org/2005/Atom title"`
+			Updated   string `xml:"http://www.w3.org/2005/Atom updated"`
+			Published string `xml:"http://www.w3.org/2005/Atom published"`
+			Link      link   `xml:"http://www.w3.org/2005/Atom link"`
+			Author    struct {
+				Name string `xml:"http://www.w3.org/2005/Atom name"`
+			} `xml:"http://www.w3.org/2005/Atom author"`
+			Categories []struct {
+				Term string `xml:"term,attr"`
+			} `xml:"http://www.w3.org/2005/Atom category"`
+			Content *text `xml:"http://www.w3.org/2005/Atom content"`
+			Summary *text `xml:"http://www.w3.org/2005/Atom summary"`
+		} `xml:"http://www.w3.org/2005/Atom entry"`
+	}
+
+	err := xml.Unmarshal(raw, &atom)
+
+	if err != nil {
+		t.Fatalf("Expected valid Atom: %s", err)
+	}
+
+	checkAbsoluteURL(t, "feed id", atom.ID)
+	checkRequired(t, "feed title", atom.Title)
+	checkDate(t, "feed updated", time.RFC3339, atom.Updated)
+
+	rels := map[string]bool{}
+
+	for _, l := range atom.Links {
+		checkAbsoluteURL(t, "feed link", l.Href)
+		rels[l.Rel] = true
+	}
+
+	if !rels["self"] || !rels["alternate"] {
+		t.Errorf("Expected the feed to link to itself and to its page")
+	}
+
+	var entries []feedEntry
+	ids := map[string]bool{}
+
+	for _, entry := range atom.Entries {
+		if !tagURIPattern.MatchString(entry.ID) || ids[entry.ID] {
+			t.Errorf("Expected entry id to be a unique tag URI but got %q", entry.ID)
+		}
+
+		ids[entry.ID] = true
+
+		checkRequired(t, "entry title", entry.Title)
+		checkRequired(t, "entry author", entry.Author.Name)
+		checkDate(t, "entry updated", time.RFC3339, entry.Updated)
+		checkDate(t, "entry published", time.RFC3339, entry.Published)
+		checkAbsoluteURL(t, "entry link", entry.Link.Href)
+
+		e := feedEntry{ID: entry.ID, URL: entry.Link.Href, Title: entry.Title, Author: entry.Author.Name}
+
+		for _, category := range entry.Categories {
+			e.Tags = append(e.Tags, category.Term)
+		}
+
+		switch {
+		case entry.Content != nil && entry.Content.Type == "html":
+			e.Content = entry.Content.Text
+		case entry.Summary != nil && entry.Summary.Type == "text":
+			e.Summary = entry.Summary.Text
+		default:
+			t.Errorf("Expected entry to have HTML content or a text summary")
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// validateJSON checks raw against the required fields and formats of JSON
+// Feed 1.1, see https://www.jsonfeed.org/version/1.1/
+func validateJSON(t *testing.T, raw []byte) []feedEntry {
+	t.Helper()
+
+	var feed struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Items       []struct {
+			ID            string `json:"id"`
+			URL           string `json:"url"`
+			Title         string `json:"title"`
+			ContentHTML   string `json:"content_html"`
+			ContentText   string `json:"content_text"`
+			Summary       string `json:"summary"`
+			DatePublished string `json:"date_published"`
+			DateModified  string `json:"date_modified"`
+			Authors       []struct {
+				Name string `json:"name"`
+			} `json:"authors"`
+			Tags []string `json:"tags"`
+		} `json:"items"`
+	}
+
+	err := json.Unmarshal(raw, &feed)
+
+	if err != nil {
+		t.Fatalf("Expected valid JSON: %s", err)
+	}
+
+	if feed.Version != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("Expected JSON Feed version 1.1 but got %q", feed.Version)
+	}
+
+	checkRequired(t, "title", feed.Title)
+	checkAbsoluteURL(t, "home_page_url", feed.HomePageURL)
+	checkAbsoluteURL(t, "feed_url", feed.FeedURL)
+
+	if !strings.Contains(string(raw), `"items":[`) {
+		t.Errorf("Expected items to be an array")
+	}
+
+	var entries []feedEntry
+
+	for _, item := range feed.Items {
+		checkRequired(t, "item id", item.ID)
+		checkAbsoluteURL(t, "item url", item.URL)
+		checkDate(t, "date_published", time.RFC3339, item.DatePublished)
+		checkDate(t, "date_modified", time.RFC3339, item.DateModified)
+
+		if item.ContentHTML == "" && item.ContentText == "" {
+			t.Errorf("Expected item to have content_html or content_text")
+		}
+
+		if len(item.Authors) != 1 {
+			t.Fatalf("Expected item to have an author")
+		}
+
+		entries = append(entries, feedEntry{item.ID, item.URL, item.Title, item.Authors[0].Name, item.Tags, item.ContentHTML, item.Summary})
+	}
+
+	return entries
+}
+
+var feedValidators = map[string]func(*testing.T, []byte) []feedEntry{
+	FeedRSS:  validateRSS,
+	FeedAtom: validateAtom,
+	FeedJSON: validateJSON,
+}
+
+// summaries moves the content of RSS entries to their summary, as RSS has
+// summaries in place of the content of posts
+func summaries(format string, entries []feedEntry) []feedEntry {
+	if format == FeedRSS {
+		for i := range entries {
+			entries[i].Summary, entries[i].Content = entries[i].Content, ""
+		}
+	}
+
+	return entries
+}
+
+func getFeedRouter(t *testing.T, opts Options) (*gin.Engine, storage.Storage) {
+	theme, err := LoadTheme("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.CreateMemoryStore()
+
+	router := gin.New()
+	New(store, theme, opts).CreateRoutes(router)
+
+	return router, store
+}
+
+// getFeed fetches the feed at path, validates it against the spec of its
+// format and returns its entries
+func getFeed(t *testing.T, router *gin.Engine, format string, path string) []feedEntry {
+	t.Helper()
+
+	w := get(router, path)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected %s to respond with 200 but got %d", path, w.Code)
+	}
+
+	if w.Header().Get("Content-Type") != feedContentTypes[format] {
+		t.Errorf("Expected %s to be served as %s but got %s", path, feedContentTypes[format], w.Header().Get("Content-Type"))
+	}
+
+	return feedValidators[format](t, w.Body.Bytes())
+}
+
+func entryTitles(entries []feedEntry) string {
+	var titles []string
+
+	for _, entry := range entries {
+		titles = append(titles, entry.Title)
+	}
+
+	return strings.Join(titles, ", ")
+}
+
+func TestFeeds(t *testing.T) {
+	router, store := getFeedRouter(t, Options{Title: "Bloggy", URL: testSiteURL + "/"})
+	defer store.Disconnect(context.Background())
+
+	content := "Tom & Jerry <b>bold</b> ]]> </description> \x01\n\n```html\n<p>code</p>\n```\n"
+
+	insert(t, store, models.Post{Title: "Early", Name: "Vishnu", Content: "Written first"}, false)
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world", Tags: []string{"go"}}, true)
+	escaped := insert(t, store, models.Post{Title: "Tom & Jerry <3 ]]>", Content: content, Tags: []string{"go", "web"}}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet"}, false)
+
+	html, err := render.HTML(escaped.Content)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// encoding/xml replaces characters XML cannot hold
+	xmlHTML := strings.ReplaceAll(html, "\x01", "�")
+
+	for _, format := range feedFormats {
+		entries := getFeed(t, router, format, "/feed."+format)
+
+		if entryTitles(entries) != "Tom & Jerry <3 ]]>, Hello" {
+			t.Errorf("Expected %s feed to hold the published posts newest first but got %s", format, entryTitles(entries))
+			continue
+		}
+
+		entry := entries[0]
+
+		expectedHTML := html
+
+		if format != FeedJSON {
+			expectedHTML = xmlHTML
+		}
+
+		if entry.Content != expectedHTML {
+			t.Errorf("Expected %s feed to hold the rendered content %q but got %q", format, expectedHTML, entry.Content)
+		}
+
+		if entry.URL != testSiteURL+"/posts/tom-jerry-3/" || entry.Author != "Bloggy" || strings.Join(entry.Tags, ",") != "go,web" {
+			t.Errorf("Expected %s feed to link to the post, its tags and the site as author but got %+v", format, entry)
+		}
+
+		if entry.ID == entries[1].ID || !strings.HasPrefix(entry.ID, "tag:blog.example.com,") {
+			t.Errorf("Expected %s feed to identify posts by tag URIs but got %s", format, entry.ID)
+		}
+	}
+
+	// IDs stay the same when the slug of a post changes
+	before := getFeed(t, router, FeedAtom, "/feed.atom")
+
+	escaped.Slug = "tom-and-jerry"
+
+	_, err = store.Modify(context.Background(), "tom-jerry-3", storage.AnyVersion, escaped)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := getFeed(t, router, FeedAtom, "/feed.atom")
+
+	if after[0].ID != before[0].ID || after[0].URL != testSiteURL+"/posts/tom-and-jerry/" {
+		t.Errorf("Expected the ID of the renamed post to stay %s but got %s", before[0].ID, after[0].ID)
+	}
+
+	// Posts are ordered by when they were published, not written
+	for _, status := range []models.Status{models.StatusInReview, models.StatusPublished} {
+		_, err = store.Transition(context.Background(), "early", storage.AnyVersion, status)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if titles := entryTitles(getFeed(t, router, FeedAtom, "/feed.atom")); titles != "Early, Tom & Jerry <3 ]]>, Hello" {
+		t.Errorf("Expected the feed to hold the posts most recently published first but got %s", titles)
+	}
+}
+
+func TestFeedOptions(t *testing.T) {
+	router, store := getFeedRouter(t, Options{Title: "Bloggy", URL: testSiteURL, FeedLimit: 2})
+	defer store.Disconnect(context.Background())
+
+	for _, format := range feedFormats {
+		entries := getFeed(t, router, format, "/feed."+format)
+
+		if len(entries) != 0 {
+			t.Errorf("Expected empty %s feed but got %s", format, entryTitles(entries))
+		}
+	}
+
+	insert(t, store, models.Post{Title: "One", Name: "Vishnu", Content: "Hello *world*, how are you", Tags: []string{"go"}}, true)
+	insert(t, store, models.Post{Title: "Two", Name: "Vishnu", Content: "Hello", Tags: []string{"rust"}}, true)
+	insert(t, store, models.Post{Title: "Three", Name: "Vishnu", Content: "Hello", Tags: []string{"go"}}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Hello", Tags: []string{"draft"}}, false)
+
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"/feed.%s", "Three, Two"},
+		{"/feed.%s?limit=1", "Three"},
+		{"/feed.%s?limit=500", "Three, Two, One"},
+		{"/tags/go/feed.%s", "Three, One"},
+		{"/tags/rust/feed.%s?limit=5", "Two"},
+	}
+
+	for _, format := range feedFormats {
+		for _, c := range cases {
+			path := strings.Replace(c.path, "%s", format, 1)
+			entries := getFeed(t, router, format, path)
+
+			if entryTitles(entries) != c.expected {
+				t.Errorf("Expected %s to hold %s but got %s", path, c.expected, entryTitles(entries))
+			}
+		}
+
+		// Summaries are plain text
+		entries := summaries(format, getFeed(t, router, format, "/tags/go/feed."+format+"?content=summary&limit=5"))
+
+		if len(entries) != 2 || entries[1].Summary != "Hello world, how are you" || entries[1].Content != "" {
+			t.Errorf("Expected %s feed to hold summaries but got %+v", format, entries)
+		}
+
+		for _, path := range []string{"/feed." + format + "?limit=0", "/feed." + format + "?limit=x", "/feed." + format + "?content=all"} {
+			assertPage(t, router, path, http.StatusBadRequest, nil, nil)
+		}
+
+		for _, path := range []string{"/tags/draft/feed." + format, "/tags/missing/feed." + format, "/tags/Not%20a%20Tag/feed." + format} {
+			assertPage(t, router, path, http.StatusNotFound, nil, nil)
+		}
+	}
+
+}
+
+func TestFeedRequestURL(t *testing.T) {
+	router, store := getFeedRouter(t, Options{Title: "Bloggy"})
+	defer store.Disconnect(context.Background())
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello"}, true)
+
+	// Links are to the host of the request without a configured URL
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:8080/feed.json", nil)
+	router.ServeHTTP(w, req)
+
+	entries := validateJSON(t, w.Body.Bytes())
+
+	if len(entries) != 1 || entries[0].URL != "http://localhost:8080/posts/hello/" || !strings.HasPrefix(entries[0].ID, "tag:localhost,") {
+		t.Errorf("Expected links to the host of the request but got %+v", entries)
+	}
+}
+
+func getConditional(router *gin.Engine, path string, header string, value string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set(header, value)
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestFeedConditional(t *testing.T) {
+	router, store := getFeedRouter(t, Options{Title: "Bloggy", URL: testSiteURL})
+	defer store.Disconnect(context.Background())
+
+	post := insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, true)
+
+	for _, format := range feedFormats {
+		path := "/feed." + format
+		w := get(router, path)
+
+		etag := w.Header().Get("ETag")
+		modified := w.Header().Get("Last-Modified")
+
+		if etag == "" || modified != updatedAt(post).Format(http.TimeFormat) {
+			t.Fatalf("Expected %s to have an ETag and to be last modified when the post was but got %q, %q", path, etag, modified)
+		}
+
+		cases := []struct {
+			header   string
+			value    string
+			expected int
+		}{
+			{"If-None-Match", etag, http.StatusNotModified},
+			{"If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+			{"If-None-Match", "*", http.StatusNotModified},
+			{"If-None-Match", `"other"`, http.StatusOK},
+			{"If-Modified-Since", modified, http.StatusNotModified},
+			{"If-Modified-Since", updatedAt(post).Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+			{"If-Modified-Since", updatedAt(post).Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+			{"If-Modified-Since", "yesterday", http.StatusOK},
+		}
+
+		for _, c := range cases {
+			w := getConditional(router, path, c.header, c.value)
+
+			if w.Code != c.expected {
+				t.Errorf("Expected %s with %s: %s to respond with %d but got %d", path, c.header, c.value, c.expected, w.Code)
+			}
+
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected %s not to send the feed when not modified", path)
+			}
+		}
+
+		// If-None-Match takes precedence over If-Modified-Since
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("If-None-Match", `"other"`)
+		req.Header.Set("If-Modified-Since", modified)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s to be sent when the ETag does not match but got %d", path, w.Code)
+		}
+	}
+
+	// Feeds change along with their posts
+	before := get(router, "/feed.atom").Header().Get("ETag")
+
+	post.Title = "Hello again"
+
+	_, err := store.Modify(context.Background(), "hello", storage.AnyVersion, post)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := getConditional(router, "/feed.atom", "If-None-Match", before); w.Code != http.StatusOK || w.Header().Get("ETag") == before {
+		t.Errorf("Expected the modified feed to be sent with a new ETag but got %d", w.Code)
+	}
+
+	// And when posts leave them, which does not modify any post left so
+	// only the ETag tells
+	insert(t, store, models.Post{Title: "World", Name: "Vishnu", Content: "Hello world"}, true)
+
+	before = get(router, "/feed.atom").Header().Get("ETag")
+
+	_, err = store.Transition(context.Background(), "world", storage.AnyVersion, models.StatusArchived)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := getConditional(router, "/feed.atom", "If-None-Match", before); w.Code != http.StatusOK || w.Header().Get("ETag") == before {
+		t.Errorf("Expected the feed a post left to be sent with a new ETag but got %d", w.Code)
+	}
+}
+
+func TestExportFeeds(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := storage.CreateMemoryStore()
+	defer store.Disconnect(ctx)
+
+	theme, err := LoadTheme("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := New(store, theme, Options{Title: "Bloggy", URL: testSiteURL, FeedSummary: true})
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello *world*", Tags: []string{"go"}}, true)
+
+	_, err = site.Export(ctx, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range feedFormats {
+		for _, path := range []string{FeedURL("", format), FeedURL("go", format)} {
+			raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries := summaries(format, feedValidators[format](t, raw))
+
+			if len(entries) != 1 || entries[0].URL != testSiteURL+"/posts/hello/" || entries[0].Summary != "Hello world" {
+				t.Errorf("Expected %s to hold the summary of the post but got %+v", path, entries)
+			}
+		}
+	}
+}
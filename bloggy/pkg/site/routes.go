@@ -0,0 +1,258 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"examples/bloggy/pkg/storage"
+)
+
+// respond renders page with the template name, or the not found page if
+// err is ErrNotFound. Pages are rendered in full before anything is sent so
+// that template errors do not leave half a page
+func (site *Site) respond(c *gin.Context, name string, page Page, err error) {
+	status := http.StatusOK
+
+	if err == ErrNotFound {
+		status = http.StatusNotFound
+		name = TemplateNotFound
+		page = site.NotFound()
+	} else if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to build page: %s\n", err)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	err = site.theme.Render(&buf, name, page)
+
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		log.Printf("ERROR Unable to render page: %s\n", err)
+		return
+	}
+
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func (site *Site) indexHandler(c *gin.Context) {
+	page, err := site.Index(c.Request.Context(), c.Query("cursor"))
+
+	site.respond(c, TemplateIndex, page, err)
+}
+
+func (site *Site) postHandler(c *gin.Context) {
+	slug := c.Param("slug")
+
+	page, err := site.PostPage(c.Request.Context(), slug)
+
+	// Old slugs redirect to the current slug
+	if err == nil && page.Post.Slug != slug {
+		c.Redirect(http.StatusMovedPermanently, page.Post.URL)
+		return
+	}
+
+	site.respond(c, TemplatePost, page, err)
+}
+
+func (site *Site) tagHandler(c *gin.Context) {
+	page, err := site.Tag(c.Request.Context(), c.Param("tag"), c.Query("cursor"))
+
+	site.respond(c, TemplateTag, page, err)
+}
+
+func (site *Site) archiveHandler(c *gin.Context) {
+	page, err := site.Archive(c.Request.Context())
+
+	site.respond(c, TemplateArchive, page, err)
+}
+
+func (site *Site) monthHandler(c *gin.Context) {
+	year, err := strconv.Atoi(c.Param("year"))
+	month, monthErr := strconv.Atoi(c.Param("month"))
+
+	if err != nil || monthErr != nil || month < 1 || month > 12 {
+		site.respond(c, TemplateMonth, Page{}, ErrNotFound)
+		return
+	}
+
+	page, err := site.Month(c.Request.Context(), year, time.Month(month))
+
+	site.respond(c, TemplateMonth, page, err)
+}
+
+// baseURL is the absolute URL the site is served at, the configured one or
+// else the one of the request
+func (site *Site) baseURL(c *gin.Context) string {
+	if site.opts.URL != "" {
+		return site.opts.URL
+	}
+
+	scheme := "http"
+
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + c.Request.Host
+}
+
+// notModified reports whether the validators of req match those of the
+// response. If-None-Match takes precedence over If-Modified-Since
+func notModified(req *http.Request, etag string, modified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+
+	return err == nil && !modified.IsZero() && !modified.Truncate(time.Second).After(since)
+}
+
+// serveConditional responds with body unless the client has it already,
+// as told by the ETag hashing body or by modified, the last modification
+// time of body if known. Only the ETag changes when posts leave body, as
+// that does not modify any post left in it
+func serveConditional(c *gin.Context, contentType string, body []byte, modified time.Time) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// feedQuery parses the limit and content query parameters of feeds,
+// responding with bad request if they are not valid
+func (site *Site) feedQuery(c *gin.Context) (int, bool, bool) {
+	limit := site.opts.FeedLimit
+	summary := site.opts.FeedSummary
+
+	if c.Query("limit") != "" {
+		var err error
+
+		limit, err = strconv.Atoi(c.Query("limit"))
+
+		if err != nil || limit <= 0 {
+			c.String(http.StatusBadRequest, "invalid limit")
+			return 0, false, false
+		}
+	}
+
+	if limit > storage.MaxPageLimit {
+		limit = storage.MaxPageLimit
+	}
+
+	switch c.Query("content") {
+	case "":
+	case "full":
+		summary = false
+	case "summary":
+		summary = true
+	default:
+		c.String(http.StatusBadRequest, "invalid content")
+		return 0, false, false
+	}
+
+	return limit, summary, true
+}
+
+// feedHandler serves the feed in format of every published post, or of
+// those with the tag of the URL. Readers may ask for the newest limit posts
+// and for summaries or the full content of posts
+func (site *Site) feedHandler(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, summary, ok := site.feedQuery(c)
+
+		if !ok {
+			return
+		}
+
+		body, modified, err := site.Feed(c.Request.Context(), site.baseURL(c), c.Param("tag"), format, limit, summary)
+
+		if err != nil {
+			site.respond(c, TemplateNotFound, Page{}, err)
+			return
+		}
+
+		serveConditional(c, feedContentTypes[format], body, modified)
+	}
+}
+
+// sitemapHandler serves the sitemap, or sitemap index, of the site or one
+// of the sitemaps it is split into
+func (site *Site) sitemapHandler(c *gin.Context) {
+	n := 0
+
+	if c.Param("file") != "" {
+		var err error
+
+		n, err = strconv.Atoi(strings.TrimSuffix(c.Param("file"), ".xml"))
+
+		if err != nil || n == 0 || !strings.HasSuffix(c.Param("file"), ".xml") {
+			site.respond(c, TemplateNotFound, Page{}, ErrNotFound)
+			return
+		}
+	}
+
+	body, modified, err := site.Sitemap(c.Request.Context(), site.baseURL(c), n)
+
+	if err != nil {
+		site.respond(c, TemplateNotFound, Page{}, err)
+		return
+	}
+
+	serveConditional(c, "application/xml; charset=utf-8", body, modified)
+}
+
+func (site *Site) robotsHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", site.Robots(site.baseURL(c)))
+}
+
+// CreateRoutes adds the pages, feeds, sitemaps and robots.txt of the site
+// to router, behind middleware if any. Page URLs end in a slash, gin
+// redirects those without
+func (site *Site) CreateRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	routes := router.Group("/", middleware...)
+
+	routes.GET("/", site.indexHandler)
+	routes.GET("/posts/:slug/", site.postHandler)
+	routes.GET("/tags/:tag/", site.tagHandler)
+	routes.GET("/archive/", site.archiveHandler)
+	routes.GET("/archive/:year/:month/", site.monthHandler)
+
+	for _, format := range feedFormats {
+		routes.GET(FeedURL("", format), site.feedHandler(format))
+		routes.GET(FeedURL(":tag", format), site.feedHandler(format))
+	}
+
+	routes.GET(SitemapURL(0), site.sitemapHandler)
+	routes.GET("/sitemaps/:file", site.sitemapHandler)
+	routes.GET("/robots.txt", site.robotsHandler)
+}
@@ -0,0 +1,324 @@
+// Package site renders the published posts of a store as a public HTML
+// site, with an index, a page per post, tag pages and archive pages
+package site
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/render"
+	"examples/bloggy/pkg/storage"
+)
+
+var ErrNotFound = errors.New("site: page not found")
+
+// listSort orders the posts of the index, tag pages and feeds most recently
+// published first
+const listSort = "-" + storage.SortPublishedAt
+
+// Post is a published post as pages show it. HTML is the rendered content,
+// only set on post pages
+type Post struct {
+	models.Post
+	URL  string
+	Date time.Time
+	HTML template.HTML
+}
+
+// Month is a month of the archive and the posts published in it, newest
+// first
+type Month struct {
+	Year  int
+	Month time.Month
+	URL   string
+	Posts []Post
+}
+
+// Page is what the templates of a theme are executed with. Title is the
+// title of the page, Site the one of the whole site. Which other fields are
+// set depends on the page, Next and Prev are the URLs of adjacent pages of
+// lists if there are any. NoFeeds is set on pages of sites without feeds
+type Page struct {
+	Site    string
+	Title   string
+	Posts   []Post
+	Post    *Post
+	Tag     string
+	Months  []Month
+	Month   *Month
+	Next    string
+	Prev    string
+	NoFeeds bool
+}
+
+// DefaultFeedLimit is the number of posts feeds hold unless configured
+const DefaultFeedLimit = 20
+
+// Options configure a site. Title is the title of the whole site, URL the
+// absolute URL it is served at which feeds link to, the URL of each request
+// is used without it. FeedLimit is the number of newest posts feeds hold, up
+// to storage.MaxPageLimit. FeedSummary has feeds hold summaries of posts
+// rather than their full content. Robots are the rules of robots.txt,
+// DefaultRobots if empty
+type Options struct {
+	Title       string
+	URL         string
+	FeedLimit   int
+	FeedSummary bool
+	Robots      string
+}
+
+// Site builds the pages of the published posts in a store. sitemapLimit is
+// the number of URLs a sitemap holds, MaxSitemapURLs but for tests
+type Site struct {
+	store        storage.Storage
+	theme        *Theme
+	opts         Options
+	renders      *render.Cache
+	sitemapLimit int
+}
+
+func New(store storage.Storage, theme *Theme, opts Options) *Site {
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+
+	if opts.FeedLimit <= 0 {
+		opts.FeedLimit = DefaultFeedLimit
+	}
+
+	if opts.FeedLimit > storage.MaxPageLimit {
+		opts.FeedLimit = storage.MaxPageLimit
+	}
+
+	return &Site{store, theme, opts, render.NewCache(), MaxSitemapURLs}
+}
+
+// Theme returns the theme pages are rendered with
+func (site *Site) Theme() *Theme {
+	return site.theme
+}
+
+// PostURL is the URL of the page of the post with slug
+func PostURL(slug string) string {
+	return "/posts/" + slug + "/"
+}
+
+// TagURL is the URL of the page of tag
+func TagURL(tag string) string {
+	return "/tags/" + tag + "/"
+}
+
+// MonthURL is the URL of the archive page of month
+func MonthURL(year int, month time.Month) string {
+	return fmt.Sprintf("/archive/%04d/%02d/", year, month)
+}
+
+// listURL is the URL of the page of the list at base starting at cursor
+func listURL(base string, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	return base + "?cursor=" + url.QueryEscape(cursor)
+}
+
+// publishedAt returns when post was published, posts published before the
+// time was kept fall back to when they were created
+func publishedAt(post models.Post) time.Time {
+	if post.PublishedAt == nil {
+		return post.CreatedAt
+	}
+
+	return *post.PublishedAt
+}
+
+func newPost(post models.Post) Post {
+	return Post{Post: post, URL: PostURL(post.Slug), Date: publishedAt(post)}
+}
+
+func newPosts(posts []models.Post) []Post {
+	converted := make([]Post, 0, len(posts))
+
+	for _, post := range posts {
+		converted = append(converted, newPost(post))
+	}
+
+	return converted
+}
+
+// list builds the page of published posts matching opts at base
+func (site *Site) list(ctx context.Context, base string, opts storage.ListOptions) (Page, error) {
+	opts.Status = models.StatusPublished
+	opts.Sort = listSort
+
+	list, err := site.store.List(ctx, opts)
+
+	if err == storage.ErrInvalidCursor {
+		return Page{}, ErrNotFound
+	}
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	return Page{
+		Site:  site.opts.Title,
+		Posts: newPosts(list.Posts),
+		Next:  listURL(base, list.Next),
+		Prev:  listURL(base, list.Prev),
+	}, nil
+}
+
+// Index builds the page of the newest published posts starting at cursor
+func (site *Site) Index(ctx context.Context, cursor string) (Page, error) {
+	page, err := site.list(ctx, "/", storage.ListOptions{Cursor: cursor})
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	page.Title = site.opts.Title
+
+	return page, nil
+}
+
+// Tag builds the page of the newest published posts with tag starting at
+// cursor. Tags without published posts have no page
+func (site *Site) Tag(ctx context.Context, tag string, cursor string) (Page, error) {
+	page, err := site.list(ctx, TagURL(tag), storage.ListOptions{Tag: tag, Cursor: cursor})
+
+	if err == storage.ErrInvalidTag || (err == nil && len(page.Posts) == 0) {
+		return Page{}, ErrNotFound
+	}
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	page.Title = "Posts tagged " + tag
+	page.Tag = tag
+
+	return page, nil
+}
+
+// PostPage builds the page of the published post with slug, which may be
+// an old slug of the post. The page then has the current slug of the post
+func (site *Site) PostPage(ctx context.Context, slug string) (Page, error) {
+	foundPost, err := site.store.Find(ctx, slug)
+
+	if err == storage.ErrDoesNotExist || (err == nil && foundPost.Status != models.StatusPublished) {
+		return Page{}, ErrNotFound
+	}
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	return site.postPage(foundPost)
+}
+
+// postPage builds the page of the published post
+func (site *Site) postPage(published models.Post) (Page, error) {
+	html, err := site.renders.HTML(published)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	post := newPost(published)
+
+	// Content is sanitized by the renderer
+	post.HTML = template.HTML(html)
+
+	return Page{Site: site.opts.Title, Title: published.Title, Post: &post}, nil
+}
+
+// Published returns every published post, newest first
+func (site *Site) Published(ctx context.Context) ([]models.Post, error) {
+	var posts []models.Post
+
+	opts := storage.ListOptions{Status: models.StatusPublished, Sort: listSort, Limit: storage.MaxPageLimit}
+
+	for {
+		list, err := site.store.List(ctx, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, list.Posts...)
+
+		if list.Next == "" {
+			return posts, nil
+		}
+
+		opts.Cursor = list.Next
+	}
+}
+
+// months groups published posts by the month they were published in,
+// newest first
+func months(posts []models.Post) []Month {
+	var grouped []Month
+
+	posts = append([]models.Post(nil), posts...)
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return publishedAt(posts[i]).After(publishedAt(posts[j]))
+	})
+
+	for _, post := range posts {
+		year, month, _ := publishedAt(post).Date()
+
+		if len(grouped) == 0 || grouped[len(grouped)-1].Year != year || grouped[len(grouped)-1].Month != month {
+			grouped = append(grouped, Month{Year: year, Month: month, URL: MonthURL(year, month)})
+		}
+
+		last := &grouped[len(grouped)-1]
+		last.Posts = append(last.Posts, newPost(post))
+	}
+
+	return grouped
+}
+
+// Archive builds the page listing every month posts were published in
+func (site *Site) Archive(ctx context.Context) (Page, error) {
+	posts, err := site.Published(ctx)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	return Page{Site: site.opts.Title, Title: "Archive", Months: months(posts)}, nil
+}
+
+// Month builds the archive page of the posts published in month. Months
+// without published posts have no page
+func (site *Site) Month(ctx context.Context, year int, month time.Month) (Page, error) {
+	posts, err := site.Published(ctx)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	for _, m := range months(posts) {
+		if m.Year == year && m.Month == month {
+			title := fmt.Sprintf("%s %d", month, year)
+
+			return Page{Site: site.opts.Title, Title: title, Month: &m, Posts: m.Posts}, nil
+		}
+	}
+
+	return Page{}, ErrNotFound
+}
+
+// NotFound builds the page shown for missing pages
+func (site *Site) NotFound() Page {
+	return Page{Site: site.opts.Title, Title: "Page not found"}
+}
@@ -0,0 +1,310 @@
+package site
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"examples/bloggy/pkg/auth"
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+func getRouter(t *testing.T, themeDir string) (*gin.Engine, storage.Storage) {
+	theme, err := LoadTheme(themeDir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.CreateMemoryStore()
+
+	router := gin.New()
+	New(store, theme, Options{Title: "Bloggy"}).CreateRoutes(router)
+
+	return router, store
+}
+
+// insert inserts post, publishing it if publish is set
+func insert(t *testing.T, store storage.Storage, post models.Post, publish bool) models.Post {
+	ctx := context.Background()
+
+	// Posts are listed newest first, keep them from being created within
+	// the same millisecond
+	time.Sleep(2 * time.Millisecond)
+
+	insertedPost, err := store.Insert(ctx, post)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !publish {
+		return insertedPost
+	}
+
+	for _, status := range []models.Status{models.StatusInReview, models.StatusPublished} {
+		insertedPost, err = store.Transition(ctx, insertedPost.Slug, storage.AnyVersion, status)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return insertedPost
+}
+
+func get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+// assertPage checks that the page at path responds with status and holds
+// every fragment in contains and none in lacks
+func assertPage(t *testing.T, router *gin.Engine, path string, status int, contains []string, lacks []string) {
+	t.Helper()
+
+	w := get(router, path)
+
+	if w.Code != status {
+		t.Errorf("Expected %s to respond with %d but got %d", path, status, w.Code)
+	}
+
+	if status == http.StatusOK && w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("Expected %s to be HTML but got %s", path, w.Header().Get("Content-Type"))
+	}
+
+	for _, fragment := range contains {
+		if !strings.Contains(w.Body.String(), fragment) {
+			t.Errorf("Expected %s to contain %q", path, fragment)
+		}
+	}
+
+	for _, fragment := range lacks {
+		if strings.Contains(w.Body.String(), fragment) {
+			t.Errorf("Expected %s not to contain %q", path, fragment)
+		}
+	}
+}
+
+func assertRedirect(t *testing.T, router *gin.Engine, path string, location string) {
+	t.Helper()
+
+	w := get(router, path)
+
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+		t.Errorf("Expected %s to redirect to %s but got %d %s", path, location, w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestIndex(t *testing.T) {
+	router, store := getRouter(t, "")
+	defer store.Disconnect(context.Background())
+
+	assertPage(t, router, "/", 200, []string{"<title>Bloggy</title>", "Nothing has been published yet"}, nil)
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, true)
+	insert(t, store, models.Post{Title: "<World>", Name: "Shankar", Content: "This is golang"}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet"}, false)
+
+	assertPage(t, router, "/", 200,
+		[]string{`<a href="/posts/hello/">Hello</a>`, `<a href="/posts/world/">&lt;World&gt;</a>`, "By Vishnu"},
+		[]string{"Draft", "<World>"})
+
+	assertPage(t, router, "/?cursor=invalid", 404, []string{"Page not found"}, nil)
+
+	// Posts are ordered by when they were published, not written
+	for _, status := range []models.Status{models.StatusInReview, models.StatusPublished} {
+		_, err := store.Transition(context.Background(), "draft", storage.AnyVersion, status)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := get(router, "/").Body.String()
+	draft := strings.Index(body, "/posts/draft/")
+
+	if draft < 0 || draft > strings.Index(body, "/posts/world/") {
+		t.Errorf("Expected the index to list the most recently published post first but got %s", body)
+	}
+}
+
+func TestPostPage(t *testing.T) {
+	router, store := getRouter(t, "")
+	defer store.Disconnect(context.Background())
+
+	post := insert(t, store, models.Post{
+		Title:   "Hello",
+		Name:    "Vishnu",
+		Content: "Hello *world*<script>alert(1)</script>",
+		Tags:    []string{"go", "web"},
+	}, true)
+
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet"}, false)
+
+	assertPage(t, router, "/posts/hello/", 200,
+		[]string{"<title>Hello - Bloggy</title>", "<p>Hello <em>world</em>", `<a href="/tags/go/">go</a>`, `<a href="/tags/web/">web</a>`},
+		[]string{"<script>"})
+
+	assertRedirect(t, router, "/posts/hello", "/posts/hello/")
+
+	// Old slugs redirect to the current slug
+	post.Slug = "greeting"
+
+	_, err := store.Modify(context.Background(), "hello", storage.AnyVersion, post)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRedirect(t, router, "/posts/hello/", "/posts/greeting/")
+	assertPage(t, router, "/posts/greeting/", 200, []string{"<p>Hello <em>world</em>"}, nil)
+
+	assertPage(t, router, "/posts/draft/", 404, []string{"Page not found"}, []string{"Not yet"})
+	assertPage(t, router, "/posts/missing/", 404, []string{"Page not found"}, nil)
+}
+
+func TestTagPage(t *testing.T) {
+	router, store := getRouter(t, "")
+	defer store.Disconnect(context.Background())
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world", Tags: []string{"go"}}, true)
+	insert(t, store, models.Post{Title: "World", Name: "Vishnu", Content: "Hello world", Tags: []string{"rust"}}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet", Tags: []string{"go", "draft"}}, false)
+
+	assertPage(t, router, "/tags/go/", 200, []string{"Posts tagged go", `<a href="/posts/hello/">Hello</a>`}, []string{"World", "Draft"})
+
+	// Tags without published posts have no page
+	assertPage(t, router, "/tags/draft/", 404, nil, nil)
+	assertPage(t, router, "/tags/Not%20a%20Tag!/", 404, nil, nil)
+}
+
+func TestArchive(t *testing.T) {
+	router, store := getRouter(t, "")
+	defer store.Disconnect(context.Background())
+
+	assertPage(t, router, "/archive/", 200, []string{"Nothing has been published yet"}, nil)
+
+	post := insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet"}, false)
+
+	year, month, _ := post.PublishedAt.Date()
+	monthURL := MonthURL(year, month)
+
+	assertPage(t, router, "/archive/", 200, []string{`<a href="` + monthURL + `">`, `<a href="/posts/hello/">Hello</a>`}, []string{"Draft"})
+	assertPage(t, router, monthURL, 200, []string{`<a href="/posts/hello/">Hello</a>`}, []string{"Draft"})
+
+	assertPage(t, router, MonthURL(year-1, month), 404, nil, nil)
+	assertPage(t, router, "/archive/2024/13/", 404, nil, nil)
+	assertPage(t, router, "/archive/year/01/", 404, nil, nil)
+}
+
+func TestMonths(t *testing.T) {
+	date := func(year int, month time.Month, day int) *time.Time {
+		t := time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	posts := []models.Post{
+		{Slug: "one", PublishedAt: date(2024, time.March, 3)},
+		{Slug: "two", PublishedAt: date(2024, time.May, 1)},
+		{Slug: "three", PublishedAt: date(2024, time.March, 20)},
+		{Slug: "four", PublishedAt: date(2023, time.May, 9)},
+		// Posts without a publication time are archived by creation time
+		{Slug: "five", CreatedAt: *date(2023, time.May, 1)},
+	}
+
+	var formatted []string
+
+	for _, month := range months(posts) {
+		var slugs []string
+
+		for _, post := range month.Posts {
+			slugs = append(slugs, post.Slug)
+		}
+
+		formatted = append(formatted, month.URL+" "+strings.Join(slugs, ","))
+	}
+
+	expected := "/archive/2024/05/ two; /archive/2024/03/ three,one; /archive/2023/05/ four,five"
+
+	if strings.Join(formatted, "; ") != expected {
+		t.Errorf("Expected months %s but got %s", expected, strings.Join(formatted, "; "))
+	}
+}
+
+func TestPrivateSite(t *testing.T) {
+	theme, err := LoadTheme("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.CreateMemoryStore()
+	defer store.Disconnect(context.Background())
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, true)
+
+	keys := auth.NewAPIKeys(map[string]auth.Principal{"reader": {Subject: "reader", Role: models.RoleReader}})
+
+	router := gin.New()
+	New(store, theme, Options{Title: "Bloggy"}).CreateRoutes(router, auth.Middleware(keys))
+
+	// Every page, feed and sitemap needs credentials
+	for _, path := range []string{"/", "/posts/hello/", "/feed.atom", "/sitemap.xml", "/robots.txt"} {
+		if w := get(router, path); w.Code != http.StatusUnauthorized || strings.Contains(w.Body.String(), "Hello world") {
+			t.Errorf("Expected %s to be unauthorized but got %d", path, w.Code)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set(auth.APIKeyHeader, "reader")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s to be served to readers but got %d", path, w.Code)
+		}
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, TemplateIndex), []byte(`{{define "content"}}<p>Custom index of {{len .Posts}}</p>{{end}}`), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, store := getRouter(t, dir)
+	defer store.Disconnect(context.Background())
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}, true)
+
+	// Templates missing from the theme are taken from the default one
+	assertPage(t, router, "/", 200, []string{"<p>Custom index of 1</p>", `<a href="/archive/">Archive</a>`}, nil)
+	assertPage(t, router, "/posts/hello/", 200, []string{"<p>Hello world</p>"}, nil)
+
+	err = os.WriteFile(filepath.Join(dir, TemplateLayout), []byte(`{{define "layout"}}`), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadTheme(dir)
+
+	if err == nil {
+		t.Error("Expected an invalid template to be reported")
+	}
+}
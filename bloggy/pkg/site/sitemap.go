@@ -0,0 +1,232 @@
+package site
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"examples/bloggy/pkg/models"
+)
+
+// MaxSitemapURLs is the number of URLs a sitemap may hold. Sites with more
+// pages have a sitemap index linking to sitemaps of that many URLs each
+const MaxSitemapURLs = 50000
+
+// DefaultRobots are the rules of robots.txt unless configured, crawlers are
+// kept out of the API as the site has the same posts
+const DefaultRobots = "User-agent: *\nDisallow: /v1/\n"
+
+// SitemapURL is the URL of the sitemap numbered n of a split sitemap, or
+// of the sitemap or sitemap index of the site if n is zero
+func SitemapURL(n int) string {
+	if n == 0 {
+		return "/sitemap.xml"
+	}
+
+	return "/sitemaps/" + strconv.Itoa(n) + ".xml"
+}
+
+// sitemapEntry is a page of the site and when it last changed, zero if it
+// never did
+type sitemapEntry struct {
+	url      string
+	modified time.Time
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// newest returns when the most recently changed of posts changed
+func newest(posts []models.Post) time.Time {
+	var modified time.Time
+
+	for _, post := range posts {
+		if updatedAt(post).After(modified) {
+			modified = updatedAt(post)
+		}
+	}
+
+	return modified
+}
+
+// sitemapEntries lists the pages of the site of the published posts, newest
+// first. List pages changed when the newest of their posts did
+func sitemapEntries(posts []models.Post) []sitemapEntry {
+	entries := []sitemapEntry{{"/", newest(posts)}, {"/archive/", newest(posts)}}
+
+	var tags []string
+	tagged := map[string][]models.Post{}
+
+	for _, post := range posts {
+		entries = append(entries, sitemapEntry{PostURL(post.Slug), updatedAt(post)})
+
+		for _, tag := range post.Tags {
+			if _, ok := tagged[tag]; !ok {
+				tags = append(tags, tag)
+			}
+
+			tagged[tag] = append(tagged[tag], post)
+		}
+	}
+
+	for _, tag := range tags {
+		entries = append(entries, sitemapEntry{TagURL(tag), newest(tagged[tag])})
+	}
+
+	for _, month := range months(posts) {
+		var monthPosts []models.Post
+
+		for _, post := range month.Posts {
+			monthPosts = append(monthPosts, post.Post)
+		}
+
+		entries = append(entries, sitemapEntry{month.URL, newest(monthPosts)})
+	}
+
+	return entries
+}
+
+// sitemapCount is the number of sitemaps entries are split into, zero if
+// they fit a single one
+func (site *Site) sitemapCount(entries []sitemapEntry) int {
+	if len(entries) <= site.sitemapLimit {
+		return 0
+	}
+
+	return (len(entries) + site.sitemapLimit - 1) / site.sitemapLimit
+}
+
+// sitemapPart returns the entries of the sitemap numbered n, starting at 1,
+// of a split sitemap
+func (site *Site) sitemapPart(entries []sitemapEntry, n int) []sitemapEntry {
+	end := n * site.sitemapLimit
+
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	return entries[(n-1)*site.sitemapLimit : end]
+}
+
+func lastMod(modified time.Time) string {
+	if modified.IsZero() {
+		return ""
+	}
+
+	return rfc3339(modified)
+}
+
+// sitemap writes the sitemap numbered n of entries for the site served at
+// base, see SitemapURL. It returns when the sitemap was last modified along
+// with it
+func (site *Site) sitemap(base string, entries []sitemapEntry, n int) ([]byte, time.Time, error) {
+	count := site.sitemapCount(entries)
+
+	if n < 0 || n > count {
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	// Sitemap index, linking to each sitemap as modified as its newest page
+	if n == 0 && count > 0 {
+		var index sitemapIndex
+		var modified time.Time
+
+		for i := 1; i <= count; i++ {
+			var sitemapModified time.Time
+
+			for _, entry := range site.sitemapPart(entries, i) {
+				if entry.modified.After(sitemapModified) {
+					sitemapModified = entry.modified
+				}
+			}
+
+			if sitemapModified.After(modified) {
+				modified = sitemapModified
+			}
+
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{base + SitemapURL(i), lastMod(sitemapModified)})
+		}
+
+		body, err := marshalXML(index)
+
+		return body, modified, err
+	}
+
+	if n > 0 {
+		entries = site.sitemapPart(entries, n)
+	}
+
+	var set urlSet
+	var modified time.Time
+
+	for _, entry := range entries {
+		if entry.modified.After(modified) {
+			modified = entry.modified
+		}
+
+		set.URLs = append(set.URLs, sitemapURL{base + entry.url, lastMod(entry.modified)})
+	}
+
+	body, err := marshalXML(set)
+
+	return body, modified, err
+}
+
+// Sitemap writes the sitemap numbered n of the published posts for the
+// site served at base, see SitemapURL. It returns when the sitemap was last
+// modified along with it
+func (site *Site) Sitemap(ctx context.Context, base string, n int) ([]byte, time.Time, error) {
+	posts, err := site.Published(ctx)
+
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return site.sitemap(base, sitemapEntries(posts), n)
+}
+
+// Robots writes robots.txt for the site served at base, the configured
+// rules followed by the URL of the sitemap unless the rules have one
+func (site *Site) Robots(base string) []byte {
+	robots := site.opts.Robots
+
+	if robots == "" {
+		robots = DefaultRobots
+	}
+
+	if !strings.HasSuffix(robots, "\n") {
+		robots += "\n"
+	}
+
+	if !strings.Contains(strings.ToLower(robots), "sitemap:") {
+		robots += "\nSitemap: " + base + SitemapURL(0) + "\n"
+	}
+
+	return []byte(robots)
+}
+
+// LoadRobots reads the rules of robots.txt from the file at path, if any
+func LoadRobots(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	raw, err := os.ReadFile(path)
+
+	return string(raw), err
+}
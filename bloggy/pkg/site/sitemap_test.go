@@ -0,0 +1,346 @@
+package site
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+type testSitemapURL struct {
+	Loc     string `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 loc"`
+	LastMod string `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 lastmod"`
+}
+
+// parseURLSet checks raw to be a sitemap as of the sitemaps.org protocol
+// and returns the lastmod of its URLs by their path
+func parseURLSet(t *testing.T, raw []byte) map[string]string {
+	t.Helper()
+
+	var set struct {
+		XMLName xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []testSitemapURL `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 url"`
+	}
+
+	err := xml.Unmarshal(raw, &set)
+
+	if err != nil {
+		t.Fatalf("Expected a sitemap: %s", err)
+	}
+
+	urls := map[string]string{}
+
+	for _, u := range set.URLs {
+		if !strings.HasPrefix(u.Loc, testSiteURL+"/") {
+			t.Errorf("Expected URLs of the site but got %s", u.Loc)
+		}
+
+		if u.LastMod != "" {
+			checkDate(t, "lastmod", time.RFC3339, u.LastMod)
+		}
+
+		urls[strings.TrimPrefix(u.Loc, testSiteURL)] = u.LastMod
+	}
+
+	if len(urls) != len(set.URLs) {
+		t.Errorf("Expected URLs to be listed once")
+	}
+
+	return urls
+}
+
+func urlPaths(urls map[string]string) string {
+	var paths []string
+
+	for path := range urls {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return strings.Join(paths, " ")
+}
+
+func getSitemapRouter(t *testing.T, opts Options, sitemapLimit int) (*gin.Engine, storage.Storage) {
+	theme, err := LoadTheme("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.CreateMemoryStore()
+
+	site := New(store, theme, opts)
+	site.sitemapLimit = sitemapLimit
+
+	router := gin.New()
+	site.CreateRoutes(router)
+
+	return router, store
+}
+
+func TestSitemap(t *testing.T) {
+	ctx := context.Background()
+
+	router, store := getSitemapRouter(t, Options{Title: "Bloggy", URL: testSiteURL}, MaxSitemapURLs)
+	defer store.Disconnect(ctx)
+
+	urls := parseURLSet(t, get(router, "/sitemap.xml").Body.Bytes())
+
+	if urlPaths(urls) != "/ /archive/" || urls["/"] != "" {
+		t.Errorf("Expected the sitemap of an empty site to list its index and archive without lastmod but got %v", urls)
+	}
+
+	hello := insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello", Tags: []string{"go"}}, true)
+	world := insert(t, store, models.Post{Title: "World", Name: "Vishnu", Content: "Hello", Tags: []string{"go", "web"}}, true)
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet", Tags: []string{"draft"}}, false)
+
+	w := get(router, "/sitemap.xml")
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/xml; charset=utf-8" {
+		t.Fatalf("Expected the sitemap to be served as XML but got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	urls = parseURLSet(t, w.Body.Bytes())
+
+	year, month, _ := hello.PublishedAt.Date()
+	monthURL := MonthURL(year, month)
+
+	expected := []string{"/", "/archive/", monthURL, "/posts/hello/", "/posts/world/", "/tags/go/", "/tags/web/"}
+	sort.Strings(expected)
+
+	if urlPaths(urls) != strings.Join(expected, " ") {
+		t.Errorf("Expected the sitemap to list %v but got %s", expected, urlPaths(urls))
+	}
+
+	if urls["/posts/hello/"] != rfc3339(updatedAt(hello)) || urls["/"] != rfc3339(updatedAt(world)) || urls["/tags/web/"] != rfc3339(updatedAt(world)) {
+		t.Errorf("Expected lastmod of posts to be when they were updated, of lists when their newest post was, but got %v", urls)
+	}
+
+	etag := w.Header().Get("ETag")
+
+	if getConditional(router, "/sitemap.xml", "If-None-Match", etag).Code != http.StatusNotModified {
+		t.Errorf("Expected the unchanged sitemap not to be sent again")
+	}
+
+	modified := w.Header().Get("Last-Modified")
+
+	if modified != updatedAt(world).Format(http.TimeFormat) || getConditional(router, "/sitemap.xml", "If-Modified-Since", modified).Code != http.StatusNotModified {
+		t.Errorf("Expected the sitemap to be last modified when its newest post was but got %q", modified)
+	}
+
+	// The sitemap follows slugs and publication of posts
+	hello.Slug = "greeting"
+
+	hello, err := store.Modify(ctx, "hello", storage.AnyVersion, hello)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Transition(ctx, "world", storage.AnyVersion, models.StatusArchived)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = getConditional(router, "/sitemap.xml", "If-None-Match", etag)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the changed sitemap to be sent but got %d", w.Code)
+	}
+
+	urls = parseURLSet(t, w.Body.Bytes())
+
+	expected = []string{"/", "/archive/", monthURL, "/posts/greeting/", "/tags/go/"}
+	sort.Strings(expected)
+
+	if urlPaths(urls) != strings.Join(expected, " ") || urls["/posts/greeting/"] != rfc3339(updatedAt(hello)) {
+		t.Errorf("Expected the sitemap to list %v but got %v", expected, urls)
+	}
+
+	for _, path := range []string{"/sitemaps/1.xml", "/sitemaps/0.xml"} {
+		assertPage(t, router, path, http.StatusNotFound, nil, nil)
+	}
+}
+
+func TestSitemapIndex(t *testing.T) {
+	router, store := getSitemapRouter(t, Options{Title: "Bloggy", URL: testSiteURL}, 3)
+	defer store.Disconnect(context.Background())
+
+	for _, title := range []string{"One", "Two", "Three", "Four"} {
+		insert(t, store, models.Post{Title: title, Name: "Vishnu", Content: "Hello", Tags: []string{strings.ToLower(title)}}, true)
+	}
+
+	insert(t, store, models.Post{Title: "Draft", Name: "Vishnu", Content: "Not yet"}, false)
+
+	// Index, archive, month, four post and four tag pages
+	var index struct {
+		XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []testSitemapURL `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemap"`
+	}
+
+	err := xml.Unmarshal(get(router, "/sitemap.xml").Body.Bytes(), &index)
+
+	if err != nil {
+		t.Fatalf("Expected a sitemap index: %s", err)
+	}
+
+	if len(index.Sitemaps) != 4 {
+		t.Fatalf("Expected 11 URLs to be split into 4 sitemaps but got %d", len(index.Sitemaps))
+	}
+
+	all := map[string]string{}
+	newest := ""
+
+	for i, sitemap := range index.Sitemaps {
+		if sitemap.Loc != testSiteURL+SitemapURL(i+1) {
+			t.Errorf("Expected the index to link to sitemap %d but got %s", i+1, sitemap.Loc)
+		}
+
+		urls := parseURLSet(t, get(router, strings.TrimPrefix(sitemap.Loc, testSiteURL)).Body.Bytes())
+
+		if len(urls) == 0 || len(urls) > 3 {
+			t.Errorf("Expected sitemap %d to hold up to 3 URLs but got %d", i+1, len(urls))
+		}
+
+		lastMod := ""
+
+		for path, modified := range urls {
+			all[path] = modified
+
+			if modified > lastMod {
+				lastMod = modified
+			}
+		}
+
+		if sitemap.LastMod != lastMod {
+			t.Errorf("Expected sitemap %d to be modified when its newest page was at %s but got %s", i+1, lastMod, sitemap.LastMod)
+		}
+
+		if lastMod > newest {
+			newest = lastMod
+		}
+	}
+
+	if len(all) != 11 || all["/posts/draft/"] != "" {
+		t.Errorf("Expected the sitemaps to list every page once but got %v", all)
+	}
+
+	if all["/"] != newest {
+		t.Errorf("Expected the index page to be modified when the newest post was")
+	}
+
+	for _, path := range []string{"/sitemaps/0.xml", "/sitemaps/5.xml", "/sitemaps/one.xml", "/sitemaps/1.json", "/sitemaps/-1.xml"} {
+		assertPage(t, router, path, http.StatusNotFound, nil, nil)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "robots.txt")
+
+	err := os.WriteFile(path, []byte("User-agent: *\nDisallow: /drafts/"), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configured, err := LoadRobots(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none, err := LoadRobots("")
+
+	if err != nil || none != "" {
+		t.Fatalf("Expected no rules without a file but got %q, %v", none, err)
+	}
+
+	_, err = LoadRobots(filepath.Join(dir, "missing.txt"))
+
+	if err == nil {
+		t.Error("Expected a missing file to be reported")
+	}
+
+	cases := []struct {
+		robots   string
+		expected string
+	}{
+		{"", DefaultRobots + "\nSitemap: " + testSiteURL + "/sitemap.xml\n"},
+		{configured, "User-agent: *\nDisallow: /drafts/\n\nSitemap: " + testSiteURL + "/sitemap.xml\n"},
+		{"User-agent: *\nAllow: /\nSitemap: https://cdn.example.com/sitemap.xml\n", "User-agent: *\nAllow: /\nSitemap: https://cdn.example.com/sitemap.xml\n"},
+	}
+
+	for _, c := range cases {
+		router, store := getSitemapRouter(t, Options{Title: "Bloggy", URL: testSiteURL, Robots: c.robots}, MaxSitemapURLs)
+
+		w := get(router, "/robots.txt")
+
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" || w.Body.String() != c.expected {
+			t.Errorf("Expected robots.txt %q but got %d %q", c.expected, w.Code, w.Body.String())
+		}
+
+		store.Disconnect(context.Background())
+	}
+}
+
+func TestExportSitemaps(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := storage.CreateMemoryStore()
+	defer store.Disconnect(ctx)
+
+	theme, err := LoadTheme("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := New(store, theme, Options{Title: "Bloggy", URL: testSiteURL})
+	site.sitemapLimit = 4
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello"}, true)
+	insert(t, store, models.Post{Title: "World", Name: "Vishnu", Content: "Hello"}, true)
+
+	_, err = site.Export(ctx, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Index, archive, month and two post pages
+	assertFile(t, dir, "sitemap.xml", "<sitemapindex")
+	assertFile(t, dir, "sitemaps/1.xml", testSiteURL+"/posts/")
+	assertFile(t, dir, "sitemaps/2.xml", testSiteURL+"/archive/2")
+	assertFile(t, dir, "sitemaps/3.xml", "")
+	assertFile(t, dir, "robots.txt", "Sitemap: "+testSiteURL+"/sitemap.xml")
+
+	// Sitemaps no longer split are removed
+	_, err = store.Transition(ctx, "world", storage.AnyVersion, models.StatusArchived)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = site.Export(ctx, dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFile(t, dir, "sitemap.xml", "<urlset")
+	assertFile(t, dir, "sitemaps/1.xml", "")
+}
@@ -0,0 +1,114 @@
+package site
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Templates of the pages of a theme. Each is executed along with
+// TemplateLayout, which defines the "layout" template the page template is
+// executed through and calls the "content" template the page defines
+const (
+	TemplateLayout   = "layout.html"
+	TemplateIndex    = "index.html"
+	TemplatePost     = "post.html"
+	TemplateTag      = "tag.html"
+	TemplateArchive  = "archive.html"
+	TemplateMonth    = "month.html"
+	TemplateNotFound = "notfound.html"
+)
+
+var pageTemplates = []string{TemplateIndex, TemplatePost, TemplateTag, TemplateArchive, TemplateMonth, TemplateNotFound}
+
+//go:embed theme
+var defaultTheme embed.FS
+
+// funcs are available to every template
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format("January 2, 2006")
+	},
+	"isoDate": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+	"tagURL":  TagURL,
+	"feedURL": FeedURL,
+}
+
+// Theme is the set of templates pages are rendered with. digest hashes
+// their sources, so that exports know when the theme changed
+type Theme struct {
+	pages  map[string]*template.Template
+	digest string
+}
+
+// readTemplate reads the template name from dir, or from the default theme
+// if dir is empty or has no such template
+func readTemplate(dir string, name string) (string, error) {
+	if dir != "" {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+
+		if err == nil {
+			return string(raw), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	raw, err := defaultTheme.ReadFile("theme/" + name)
+
+	return string(raw), err
+}
+
+// LoadTheme parses the templates of the theme in dir. Templates missing from
+// dir are taken from the default theme, which is loaded if dir is empty
+func LoadTheme(dir string) (*Theme, error) {
+	layout, err := readTemplate(dir, TemplateLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	theme := &Theme{pages: map[string]*template.Template{}}
+
+	hash := sha256.New()
+	hash.Write([]byte(layout))
+
+	for _, name := range pageTemplates {
+		page, err := readTemplate(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Write([]byte(name))
+		hash.Write([]byte(page))
+
+		tmpl, err := template.New(TemplateLayout).Funcs(funcs).Parse(layout)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tmpl.New(name).Parse(page)
+		if err != nil {
+			return nil, err
+		}
+
+		theme.pages[name] = tmpl
+	}
+
+	theme.digest = hex.EncodeToString(hash.Sum(nil))
+
+	return theme, nil
+}
+
+// Render writes page rendered with the template name to w
+func (theme *Theme) Render(w io.Writer, name string, page Page) error {
+	return theme.pages[name].ExecuteTemplate(w, "layout", page)
+}
@@ -0,0 +1,135 @@
+// Package slug turns free-form titles into URL-safe identifiers made of
+// lowercase ASCII letters, digits and single hyphens
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fallback is the slug of titles that have no transliterable characters
+const Fallback = "post"
+
+// MaxLength is the maximum length of a generated slug, excluding any
+// collision suffix
+const MaxLength = 80
+
+// transliterations spell letters that do not decompose into an ASCII letter
+// and combining marks
+var transliterations = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'Æ': "ae", 'œ': "oe", 'Œ': "oe", 'ø': "o", 'Ø': "o",
+	'đ': "d", 'Đ': "d", 'ð': "d", 'Ð': "d", 'þ': "th", 'Þ': "th", 'ł': "l",
+	'Ł': "l", 'ı': "i", 'ħ': "h", 'Ħ': "h", 'ŋ': "ng", 'Ŋ': "ng",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i",
+	'ї': "yi", 'є': "ye", 'ґ': "g",
+}
+
+// Make returns the slug of title. Letters are transliterated to ASCII where
+// possible, every other run of characters becomes a single hyphen
+func Make(title string) string {
+	var b strings.Builder
+
+	hyphen := false
+
+	write := func(s string) {
+		if s == "" {
+			return
+		}
+
+		if hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+
+		hyphen = false
+		b.WriteString(s)
+	}
+
+	// Decompose so accented letters become a base letter and marks
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(unicode.ToLower(r)))
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining marks, the base letter was already written
+		case unicode.IsLetter(r):
+			s, ok := transliterations[unicode.ToLower(r)]
+
+			if !ok {
+				hyphen = true
+				continue
+			}
+
+			write(s)
+		case r == '\'' || r == '’':
+			// Keep contractions such as "don't" in one word
+		default:
+			hyphen = true
+		}
+	}
+
+	s := truncate(b.String())
+
+	if s == "" {
+		return Fallback
+	}
+
+	return s
+}
+
+// truncate cuts s to MaxLength, at the last hyphen if there is one
+func truncate(s string) string {
+	if len(s) <= MaxLength {
+		return s
+	}
+
+	s = s[:MaxLength]
+
+	i := strings.LastIndexByte(s, '-')
+
+	if i > 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSuffix(s, "-")
+}
+
+// Valid reports whether s is already in slug form
+func Valid(s string) bool {
+	if s == "" || s[0] == '-' || s[len(s)-1] == '-' || strings.Contains(s, "--") {
+		return false
+	}
+
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithSuffix returns the n-th candidate for a slug that collides with
+// another one, n starts at 1 which is base itself
+func WithSuffix(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+
+	return base + "-" + strconv.Itoa(n)
+}
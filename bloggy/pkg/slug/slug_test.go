@@ -0,0 +1,67 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		slug  string
+	}{
+		{"Hello", "hello"},
+		{"Hello World", "hello-world"},
+		{"  Hello,   World!!  ", "hello-world"},
+		{"What/is?this#title", "what-is-this-title"},
+		{"Don't panic", "dont-panic"},
+		{"Crème brûlée", "creme-brulee"},
+		{"Straße in Łódź", "strasse-in-lodz"},
+		{"Привет мир", "privet-mir"},
+		{"Καλημέρα", "kalimera"},
+		{"Ｇｏｌａｎｇ １２３", "golang-123"},
+		{"日本語 Go", "go"},
+		{"日本語", Fallback},
+		{"", Fallback},
+	}
+
+	for _, tc := range tests {
+		s := Make(tc.title)
+
+		if s != tc.slug {
+			t.Errorf("Expected slug of %q to be %q but got %q", tc.title, tc.slug, s)
+		}
+
+		if !Valid(s) {
+			t.Errorf("Expected slug %q of %q to be valid", s, tc.title)
+		}
+	}
+}
+
+func TestMakeTruncates(t *testing.T) {
+	s := Make(strings.Repeat("golang ", 20))
+
+	if len(s) > MaxLength || strings.HasSuffix(s, "-") || !strings.HasSuffix(s, "golang") {
+		t.Errorf("Expected slug to be cut at a word within %d characters but got %q", MaxLength, s)
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, s := range []string{"hello", "hello-world", "go-1-21"} {
+		if !Valid(s) {
+			t.Errorf("Expected %q to be valid", s)
+		}
+	}
+
+	for _, s := range []string{"", "Hello", "hello world", "-hello", "hello-", "hello--world", "héllo", "a/b"} {
+		if Valid(s) {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	if WithSuffix("hello", 1) != "hello" || WithSuffix("hello", 2) != "hello-2" {
+		t.Errorf("Expected hello, hello-2 but got %s, %s", WithSuffix("hello", 1), WithSuffix("hello", 2))
+	}
+}
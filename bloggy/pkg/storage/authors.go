@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+var ErrAuthorDoesNotExist = errors.New("storage: author does not exist")
+var ErrInvalidAuthor = errors.New("storage: invalid author")
+
+// AuthorStorage stores the profiles of the authors posts link to by their
+// AuthorID. Authors are looked up by ID, which Insert generates along with
+// the timestamps. Modify replaces the client editable fields and returns the
+// author as stored. Authors must have a name, Insert and Modify return
+// ErrInvalidAuthor otherwise. All returns authors sorted by name
+type AuthorStorage interface {
+	Insert(ctx context.Context, author models.Author) (models.Author, error)
+	Find(ctx context.Context, id primitive.ObjectID) (models.Author, error)
+	Modify(ctx context.Context, id primitive.ObjectID, author models.Author) (models.Author, error)
+	Remove(ctx context.Context, id primitive.ObjectID) error
+	All(ctx context.Context) ([]models.Author, error)
+	Disconnect(ctx context.Context) error
+	Clean(ctx context.Context) error
+}
+
+// normalizeAuthor trims the name of author, returning ErrInvalidAuthor if
+// it is left empty
+func normalizeAuthor(author models.Author) (models.Author, error) {
+	author.Name = strings.TrimSpace(author.Name)
+
+	if author.Name == "" {
+		return models.Author{}, ErrInvalidAuthor
+	}
+
+	return author, nil
+}
+
+// newAuthor fills in the server managed fields of an author about to be
+// inserted
+func newAuthor(author models.Author) models.Author {
+	t := now()
+
+	author.ID = primitive.NewObjectID()
+	author.CreatedAt = t
+	author.UpdatedAt = t
+
+	return author
+}
+
+// modifiedAuthor applies the client editable fields of author to existing
+func modifiedAuthor(existing models.Author, author models.Author) models.Author {
+	existing.Name = author.Name
+	existing.Email = author.Email
+	existing.Bio = author.Bio
+	existing.UpdatedAt = now()
+
+	return existing
+}
+
+// sortAuthors orders authors by name, ties are broken by ID
+func sortAuthors(authors []models.Author) {
+	sort.Slice(authors, func(i, j int) bool {
+		a, b := authors[i], authors[j]
+
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+
+		return a.ID.Hex() < b.ID.Hex()
+	})
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+var ErrCommentDoesNotExist = errors.New("storage: comment does not exist")
+var ErrInvalidComment = errors.New("storage: invalid comment")
+var ErrInvalidParent = errors.New("storage: invalid parent comment")
+
+// CommentStorage stores the comments left on posts by their PostID. Insert
+// generates the ID and timestamps and queues comments for moderation as
+// pending. Comments must have a post, a name and content, Insert returns
+// ErrInvalidComment otherwise. Replies must answer an approved comment on
+// the same post, Insert returns ErrInvalidParent otherwise.
+// List returns the comments of the post with postID, or of every post if it
+// is zero, that have status, or any status if it is empty, oldest first.
+// Moderate moves a comment to status. Remove removes a comment along with
+// the replies to it, RemovePost every comment of a post
+type CommentStorage interface {
+	Insert(ctx context.Context, comment models.Comment) (models.Comment, error)
+	Find(ctx context.Context, id primitive.ObjectID) (models.Comment, error)
+	List(ctx context.Context, postID primitive.ObjectID, status models.CommentStatus) ([]models.Comment, error)
+	Moderate(ctx context.Context, id primitive.ObjectID, status models.CommentStatus) (models.Comment, error)
+	Remove(ctx context.Context, id primitive.ObjectID) error
+	RemovePost(ctx context.Context, postID primitive.ObjectID) error
+	Disconnect(ctx context.Context) error
+	Clean(ctx context.Context) error
+}
+
+// normalizeComment trims the name and content of comment, returning
+// ErrInvalidComment if either is left empty or there is no post
+func normalizeComment(comment models.Comment) (models.Comment, error) {
+	comment.Name = strings.TrimSpace(comment.Name)
+	comment.Content = strings.TrimSpace(comment.Content)
+
+	if comment.PostID.IsZero() || comment.Name == "" || comment.Content == "" {
+		return models.Comment{}, ErrInvalidComment
+	}
+
+	return comment, nil
+}
+
+// checkParent returns ErrInvalidParent if comment can not be a reply to
+// parent
+func checkParent(comment models.Comment, parent models.Comment) error {
+	if parent.PostID != comment.PostID || parent.Status != models.CommentApproved {
+		return ErrInvalidParent
+	}
+
+	return nil
+}
+
+// checkCommentStatus returns ErrInvalidStatus if status is not a moderation
+// status, the empty status is allowed if orEmpty is set
+func checkCommentStatus(status models.CommentStatus, orEmpty bool) error {
+	if !status.Valid() && !(orEmpty && status == "") {
+		return ErrInvalidStatus
+	}
+
+	return nil
+}
+
+// newComment fills in the server managed fields of a comment about to be
+// inserted
+func newComment(comment models.Comment) models.Comment {
+	t := now()
+
+	comment.ID = primitive.NewObjectID()
+	comment.Status = models.CommentPending
+	comment.CreatedAt = t
+	comment.UpdatedAt = t
+
+	return comment
+}
+
+// sortComments orders comments oldest first, ties are broken by ID
+func sortComments(comments []models.Comment) {
+	sort.Slice(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+
+		return a.ID.Hex() < b.ID.Hex()
+	})
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// lockFile does nothing where advisory locks are not supported, processes
+// sharing a write-ahead log are not detected there
+func lockFile(_ *os.File, _ bool) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package storage
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an advisory lock on file, exclusive or shared, which is
+// released when file is closed. It does not wait for a lock held by another
+// process but returns ErrWALLocked
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+
+	if err == syscall.EWOULDBLOCK {
+		return ErrWALLocked
+	}
+
+	return err
+}
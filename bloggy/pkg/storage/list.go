@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+var ErrInvalidCursor = errors.New("storage: invalid cursor")
+var ErrInvalidSort = errors.New("storage: invalid sort key")
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Sort keys accepted by ListOptions, prefix with "-" for descending order.
+// Sorting by SortPublishedAt only lists posts that have been published
+const (
+	SortTitle       = "title"
+	SortName        = "name"
+	SortCreatedAt   = "createdAt"
+	SortUpdatedAt   = "updatedAt"
+	SortPublishedAt = "publishedAt"
+)
+
+// ListOptions selects a page of posts, only those with Tag, in Category,
+// with Status and by the author with AuthorID if set. Cursor is taken from a
+// previous Page and must be used with the same Sort and filters
+type ListOptions struct {
+	Limit    int
+	Cursor   string
+	Sort     string
+	Tag      string
+	Category string
+	Status   models.Status
+	AuthorID primitive.ObjectID
+}
+
+// Page is a slice of posts in sort order. Next and Prev are cursors to the
+// adjacent pages, empty if there is no such page
+type Page struct {
+	Posts []models.Post `json:"posts"`
+	Next  string        `json:"next,omitempty"`
+	Prev  string        `json:"prev,omitempty"`
+}
+
+// sortField is the post field posts are ordered by, ties are broken by
+// slug which is unique. value encodes the field as a string that sorts
+// the same way and parse decodes it back to the type stored in Mongo.
+// Posts without an optional field are not listed in its order
+type sortField struct {
+	name     string
+	value    func(post models.Post) string
+	parse    func(value string) (interface{}, error)
+	optional bool
+}
+
+func parseString(value string) (interface{}, error) {
+	return value, nil
+}
+
+func parseTime(value string) (interface{}, error) {
+	return time.Parse(timeLayout, value)
+}
+
+var sortFields = map[string]sortField{
+	SortTitle: {"title", func(post models.Post) string { return post.Title }, parseString, false},
+	SortName:  {"name", func(post models.Post) string { return post.Name }, parseString, false},
+	SortCreatedAt: {"createdAt", func(post models.Post) string {
+		return post.CreatedAt.UTC().Format(timeLayout)
+	}, parseTime, false},
+	SortUpdatedAt: {"updatedAt", func(post models.Post) string {
+		return post.UpdatedAt.UTC().Format(timeLayout)
+	}, parseTime, false},
+	SortPublishedAt: {"publishedAt", func(post models.Post) string {
+		if post.PublishedAt == nil {
+			return ""
+		}
+
+		return post.PublishedAt.UTC().Format(timeLayout)
+	}, parseTime, true},
+}
+
+// cursor is a position between two posts in a sort order
+type cursor struct {
+	Sort     string        `json:"s"`
+	Tag      string        `json:"t,omitempty"`
+	Category string        `json:"c,omitempty"`
+	Status   models.Status `json:"st,omitempty"`
+	Author   string        `json:"a,omitempty"`
+	Value    string        `json:"v"`
+	Slug     string        `json:"k"`
+	Before   bool          `json:"b,omitempty"`
+}
+
+// listQuery is a validated ListOptions. Backends fetch up to limit+1 posts
+// strictly after the cursor, or strictly before it if cursor.Before is set,
+// walking in the direction given by ascending
+type listQuery struct {
+	limit       int
+	sort        string
+	tag         string
+	category    string
+	status      models.Status
+	authorID    primitive.ObjectID
+	field       sortField
+	desc        bool
+	cursor      *cursor
+	cursorValue interface{}
+}
+
+func (q listQuery) ascending() bool {
+	return q.desc == (q.cursor != nil && q.cursor.Before)
+}
+
+func parseListOptions(opts ListOptions) (listQuery, error) {
+	var err error
+
+	q := listQuery{limit: opts.Limit}
+
+	if q.limit <= 0 {
+		q.limit = DefaultPageLimit
+	}
+
+	if q.limit > MaxPageLimit {
+		q.limit = MaxPageLimit
+	}
+
+	sort := opts.Sort
+
+	if sort == "" {
+		sort = SortTitle
+	}
+
+	field, ok := sortFields[strings.TrimPrefix(sort, "-")]
+
+	if !ok {
+		return q, ErrInvalidSort
+	}
+
+	q.sort = sort
+	q.field = field
+	q.desc = strings.HasPrefix(sort, "-")
+
+	if opts.Tag != "" {
+		q.tag, err = normalizeTag(opts.Tag)
+
+		if err != nil {
+			return q, err
+		}
+	}
+
+	if opts.Category != "" {
+		q.category, err = normalizeTag(opts.Category)
+
+		if err != nil {
+			return q, err
+		}
+	}
+
+	if opts.Status != "" {
+		q.status = opts.Status
+
+		err = checkStatus(q.status)
+
+		if err != nil {
+			return q, err
+		}
+	}
+
+	q.authorID = opts.AuthorID
+
+	if opts.Cursor == "" {
+		return q, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+
+	if err != nil {
+		return q, ErrInvalidCursor
+	}
+
+	var c cursor
+
+	err = json.Unmarshal(raw, &c)
+
+	// A cursor is only meaningful in the order and filter it was created for
+	if err != nil || c.Sort != sort || c.Tag != q.tag || c.Category != q.category || c.Status != q.status || c.Author != q.author() {
+		return q, ErrInvalidCursor
+	}
+
+	q.cursorValue, err = field.parse(c.Value)
+
+	if err != nil {
+		return q, ErrInvalidCursor
+	}
+
+	q.cursor = &c
+
+	return q, nil
+}
+
+// author returns the hex form of the author filter, empty if there is none
+func (q listQuery) author() string {
+	if q.authorID.IsZero() {
+		return ""
+	}
+
+	return q.authorID.Hex()
+}
+
+func (q listQuery) encodeCursor(post models.Post, before bool) string {
+	raw, _ := json.Marshal(cursor{q.sort, q.tag, q.category, q.status, q.author(), q.field.value(post), post.Slug, before})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// page builds a Page from up to limit+1 posts fetched by a backend
+func (q listQuery) page(posts []models.Post) Page {
+	before := q.cursor != nil && q.cursor.Before
+	hasMore := len(posts) > q.limit
+
+	if hasMore {
+		posts = posts[:q.limit]
+	}
+
+	// Posts before the cursor were fetched walking backwards
+	if before {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+
+	page := Page{Posts: posts}
+
+	if posts == nil {
+		page.Posts = []models.Post{}
+	}
+
+	if len(posts) == 0 {
+		return page
+	}
+
+	if (!before && hasMore) || (before && q.cursor != nil) {
+		page.Next = q.encodeCursor(posts[len(posts)-1], false)
+	}
+
+	if (before && hasMore) || (!before && q.cursor != nil) {
+		page.Prev = q.encodeCursor(posts[0], true)
+	}
+
+	return page
+}
+
+// ordered reports whether the post with sort value av and slug ak comes
+// before the one with bv and bk when walking in the query direction
+func (q listQuery) ordered(av string, ak string, bv string, bk string) bool {
+	if av == bv {
+		av, bv = ak, bk
+	}
+
+	if q.ascending() {
+		return av < bv
+	}
+
+	return av > bv
+}
+
+func (q listQuery) less(a models.Post, b models.Post) bool {
+	return q.ordered(q.field.value(a), a.Slug, q.field.value(b), b.Slug)
+}
+
+// matches reports whether post passes the tag, category, status and author
+// filters and has the field it is sorted by
+func (q listQuery) matches(post models.Post) bool {
+	if q.tag != "" && !hasTag(post.Tags, q.tag) {
+		return false
+	}
+
+	if q.field.optional && q.field.value(post) == "" {
+		return false
+	}
+
+	if q.status != "" && post.Status != q.status {
+		return false
+	}
+
+	if !q.authorID.IsZero() && post.AuthorID != q.authorID {
+		return false
+	}
+
+	return q.category == "" || post.Category == q.category
+}
+
+// afterCursor reports whether post comes after the cursor when walking in the
+// query direction
+func (q listQuery) afterCursor(post models.Post) bool {
+	if q.cursor == nil {
+		return true
+	}
+
+	return q.ordered(q.cursor.Value, q.cursor.Slug, q.field.value(post), post.Slug)
+}
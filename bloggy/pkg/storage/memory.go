@@ -3,80 +3,948 @@ package storage
 import (
 	"context"
 	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/search"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// memoryEntry is a stored post along with its insertion sequence number,
+// posts are returned in insertion order like MongoStore does
+type memoryEntry struct {
+	seq  uint64
+	key  string
+	post models.Post
+}
+
+// MemoryStore is safe for concurrent use, readers share the lock and
+// writers hold it exclusively
 type MemoryStore struct {
-	mp map[string]models.Post
+	mu        sync.RWMutex
+	mp        map[string]memoryEntry
+	seq       uint64
+	revisions map[primitive.ObjectID][]models.Revision
+	aliases   map[string]string
+	index     *search.Index
+	wal       *writeAheadLog
+
+	// legacySlugs maps the titles posts were logged under before there
+	// were slugs to the slugs they are given on replay
+	legacySlugs map[string]string
 }
 
 func CreateMemoryStore() Storage {
-	return &MemoryStore{mp: map[string]models.Post{}}
+	return &MemoryStore{
+		mp:          map[string]memoryEntry{},
+		revisions:   map[primitive.ObjectID][]models.Revision{},
+		aliases:     map[string]string{},
+		index:       search.NewIndex(),
+		legacySlugs: map[string]string{},
+	}
 }
 
-func (m *MemoryStore) Insert(ctx context.Context, post models.Post) error {
-	_, ok := m.mp[post.Title]
+// CreateDurableMemoryStore creates a memory store that appends every change
+// to a write-ahead log in dir and compacts the log into a snapshot after
+// compactEvery records. Existing snapshot and log in dir are replayed.
+// Compaction is disabled if compactEvery is zero
+func CreateDurableMemoryStore(dir string, compactEvery int) (Storage, error) {
+	m := CreateMemoryStore().(*MemoryStore)
 
-	if ok {
-		return ErrAlreadyExists
+	wal, err := openWriteAheadLog(dir, compactEvery, m.replay)
+	if err != nil {
+		return nil, err
 	}
 
-	m.mp[post.Title] = post
+	m.wal = wal
 
-	return nil
+	return m, nil
+}
+
+// CreateReadOnlyMemoryStore creates a memory store of the snapshot and log
+// a durable memory store left in dir, without changing them. Every change
+// to the store fails with ErrReadOnly. It fails with ErrWALLocked while a
+// durable memory store has dir open, and if dir has no log
+func CreateReadOnlyMemoryStore(dir string) (Storage, error) {
+	m := CreateMemoryStore().(*MemoryStore)
+
+	wal, err := openReadOnlyWriteAheadLog(dir, m.replay)
+	if err != nil {
+		return nil, err
+	}
+
+	m.wal = wal
+
+	return m, nil
 }
 
-func (m *MemoryStore) Find(ctx context.Context, title string) (models.Post, error) {
-	foundPost, ok := m.mp[title]
+func (m *MemoryStore) replay(rec walRecord) {
+	switch rec.Op {
+	case walOpPut:
+		key, post := m.legacySlug(rec.Key, rec.Post)
+		m.put(key, post)
+
+		if rec.Revision != nil {
+			m.addRevision(*rec.Revision)
+		}
+	case walOpRevision:
+		m.addRevision(*rec.Revision)
+	case walOpAlias:
+		m.aliases[rec.Key] = rec.Target
+	case walOpDelete:
+		key, ok := m.legacySlugs[rec.Key]
+
+		if !ok {
+			key = rec.Key
+		}
+
+		delete(m.legacySlugs, rec.Key)
+		m.delete(key)
+	case walOpClean:
+		m.clean()
+	}
+}
+
+// legacySlug gives a post logged before there were slugs, when posts were
+// keyed by title, a unique slug made from its title. Later records find the
+// post by its title. Logs only hold such posts before the first post with a
+// slug, which every later record then keys by slug
+func (m *MemoryStore) legacySlug(key string, post models.Post) (string, models.Post) {
+	if post.Slug != "" {
+		m.legacySlugs = map[string]string{}
+		return key, post
+	}
+
+	s, ok := m.legacySlugs[key]
 
 	if !ok {
-		return foundPost, ErrDoesNotExist
+		s, _ = uniqueSlug(post.Title, func(s string) (bool, error) {
+			_, taken := m.mp[s]
+			_, aliased := m.aliases[s]
+
+			return taken || aliased, nil
+		})
 	}
 
-	return foundPost, nil
+	delete(m.legacySlugs, key)
+	m.legacySlugs[post.Title] = s
+
+	post.Slug = s
+
+	return s, post
 }
 
-func (m *MemoryStore) Remove(ctx context.Context, title string) error {
-	_, ok := m.mp[title]
+func (m *MemoryStore) addRevision(revision models.Revision) {
+	m.revisions[revision.PostID] = append(m.revisions[revision.PostID], revision)
+}
+
+// delete removes the post stored under key along with its revisions and
+// aliases
+func (m *MemoryStore) delete(key string) {
+	m.retarget(key, "")
+
+	delete(m.revisions, m.mp[key].post.ID)
+	delete(m.mp, key)
+	m.index.Remove(key)
+}
+
+func (m *MemoryStore) clean() {
+	m.mp = map[string]memoryEntry{}
+	m.revisions = map[primitive.ObjectID][]models.Revision{}
+	m.aliases = map[string]string{}
+	m.index = search.NewIndex()
+	m.legacySlugs = map[string]string{}
+}
+
+// retarget points the aliases of the post with slug to target instead, or
+// removes them if target is empty
+func (m *MemoryStore) retarget(slug string, target string) {
+	for alias, s := range m.aliases {
+		if s != slug {
+			continue
+		}
+
+		if target == "" {
+			delete(m.aliases, alias)
+		} else {
+			m.aliases[alias] = target
+		}
+	}
+}
+
+// put replaces the post stored under key, or adds it if there is none. Posts
+// are keyed by slug so a post moves when its slug changes, its old slug then
+// becomes an alias of the new one. A replaced post keeps its position
+func (m *MemoryStore) put(key string, post models.Post) {
+	entry, ok := m.mp[key]
 
 	if !ok {
-		return ErrDoesNotExist
+		m.seq++
+		entry = memoryEntry{seq: m.seq}
 	}
 
-	delete(m.mp, title)
+	// Posts logged before there were tags have none
+	if post.Tags == nil {
+		post.Tags = []string{}
+	}
 
-	return nil
+	// Posts logged before there was a workflow were public
+	if post.Status == "" {
+		post.Status = models.StatusPublished
+	}
+
+	// Posts logged before publishedAt was set were published when created
+	if post.Status == models.StatusPublished && post.PublishedAt == nil {
+		publishedAt := post.CreatedAt
+		post.PublishedAt = &publishedAt
+	}
+
+	if ok && key != post.Slug {
+		m.retarget(key, post.Slug)
+		m.aliases[key] = post.Slug
+	}
+
+	// A post taking back one of its old slugs no longer needs the alias
+	delete(m.aliases, post.Slug)
+	delete(m.mp, key)
+
+	entry.key = post.Slug
+	entry.post = post
+	m.mp[post.Slug] = entry
+
+	m.index.Remove(key)
+	m.index.Add(post.Slug, searchFields(post)...)
+}
+
+// live returns the entry stored under slug unless it is in the trash
+func (m *MemoryStore) live(slug string) (memoryEntry, bool) {
+	entry, ok := m.mp[slug]
+
+	return entry, ok && entry.post.DeletedAt == nil
+}
+
+// trashed returns the entry stored under slug if it is in the trash
+func (m *MemoryStore) trashed(slug string) (memoryEntry, bool) {
+	entry, ok := m.mp[slug]
+
+	return entry, ok && entry.post.DeletedAt != nil
+}
+
+// entries returns every stored entry in insertion order
+func (m *MemoryStore) entries() []memoryEntry {
+	entries := make([]memoryEntry, 0, len(m.mp))
+
+	for _, v := range m.mp {
+		entries = append(entries, v)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+
+	return entries
+}
+
+// record and compactIfDue must be called with the write lock held
+func (m *MemoryStore) record(rec walRecord) error {
+	if m.wal == nil {
+		return nil
+	}
+
+	return m.wal.append(rec)
+}
+
+func (m *MemoryStore) compactIfDue() {
+	if m.wal == nil || !m.wal.compactionDue() {
+		return
+	}
+
+	var recs []walRecord
+
+	for _, entry := range m.entries() {
+		recs = append(recs, walRecord{Op: walOpPut, Key: entry.key, Post: entry.post})
+
+		for i := range m.revisions[entry.post.ID] {
+			recs = append(recs, walRecord{Op: walOpRevision, Revision: &m.revisions[entry.post.ID][i]})
+		}
+	}
+
+	for alias, target := range m.aliases {
+		recs = append(recs, walRecord{Op: walOpAlias, Key: alias, Target: target})
+	}
+
+	// The log still holds every change, compaction is retried on next write
+	err := m.wal.compact(recs)
+
+	if err != nil {
+		log.Printf("ERROR Unable to compact write-ahead log: %s\n", err)
+	}
+}
+
+func (m *MemoryStore) Insert(ctx context.Context, post models.Post) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = normalizeTaxonomy(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = insertWithUniqueSlug(newPost(post), func(post models.Post) error {
+		_, taken := m.mp[post.Slug]
+		_, aliased := m.aliases[post.Slug]
+
+		if taken || aliased {
+			return ErrAlreadyExists
+		}
+
+		revision := revisionOf(post)
+
+		err := m.record(walRecord{Op: walOpPut, Key: post.Slug, Post: post, Revision: &revision})
+
+		if err != nil {
+			return err
+		}
+
+		m.put(post.Slug, post)
+		m.addRevision(revision)
+
+		return nil
+	})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	m.compactIfDue()
+
+	return post, nil
 }
 
-func (m *MemoryStore) Modify(ctx context.Context, title string, post models.Post) error {
-	_, ok := m.mp[title]
+func (m *MemoryStore) Find(ctx context.Context, slug string) (models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	entry, ok := m.live(slug)
+
+	if !ok {
+		entry, ok = m.live(m.aliases[slug])
+	}
+
+	if !ok {
+		return models.Post{}, ErrDoesNotExist
+	}
+
+	return entry.post, nil
+}
+
+func (m *MemoryStore) Remove(ctx context.Context, slug string, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return err
+	}
+
+	entry, ok := m.live(slug)
 
 	if !ok {
 		return ErrDoesNotExist
 	}
 
-	m.mp[title] = post
+	err = checkVersion(entry.post, version)
+
+	if err != nil {
+		return err
+	}
+
+	post := trashedPost(entry.post)
+
+	err = m.record(walRecord{Op: walOpPut, Key: slug, Post: post})
+
+	if err != nil {
+		return err
+	}
+
+	m.put(slug, post)
+	m.compactIfDue()
 
 	return nil
 }
 
-func (m *MemoryStore) All(_ context.Context) ([]models.Post, error) {
+func (m *MemoryStore) Modify(ctx context.Context, slug string, version int64, post models.Post) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = checkSlug(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = normalizeTaxonomy(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	entry, ok := m.live(slug)
+
+	if !ok {
+		return models.Post{}, ErrDoesNotExist
+	}
+
+	err = checkVersion(entry.post, version)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post = modifiedPost(entry.post, post)
+
+	_, taken := m.mp[post.Slug]
+	target, aliased := m.aliases[post.Slug]
+
+	if taken && post.Slug != slug || aliased && target != slug {
+		return models.Post{}, ErrAlreadyExists
+	}
+
+	revision := revisionOf(post)
+
+	err = m.record(walRecord{Op: walOpPut, Key: slug, Post: post, Revision: &revision})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	m.put(slug, post)
+	m.addRevision(revision)
+	m.compactIfDue()
+
+	return post, nil
+}
+
+func (m *MemoryStore) All(ctx context.Context) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
 	var allPosts []models.Post
 
-	for _, v := range m.mp {
-		allPosts = append(allPosts, v)
+	for _, entry := range m.entries() {
+		if entry.post.DeletedAt == nil {
+			allPosts = append(allPosts, entry.post)
+		}
 	}
 
 	return allPosts, nil
 }
 
+func (m *MemoryStore) List(ctx context.Context, opts ListOptions) (Page, error) {
+	q, err := parseListOptions(opts)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err = ctx.Err()
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	var posts []models.Post
+
+	for _, entry := range m.mp {
+		if entry.post.DeletedAt == nil && q.matches(entry.post) && q.afterCursor(entry.post) {
+			posts = append(posts, entry.post)
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return q.less(posts[i], posts[j])
+	})
+
+	if len(posts) > q.limit+1 {
+		posts = posts[:q.limit+1]
+	}
+
+	return q.page(posts), nil
+}
+
+func (m *MemoryStore) Search(ctx context.Context, query string, opts SearchOptions) (SearchPage, error) {
+	q, err := parseSearchOptions(query, opts)
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err = ctx.Err()
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	var results []SearchResult
+
+	skipped := 0
+
+	// Trashed posts stay indexed until they are purged
+	for _, hit := range m.index.Search(q.terms) {
+		entry, ok := m.live(hit.Key)
+
+		if !ok || q.status != "" && entry.post.Status != q.status {
+			continue
+		}
+
+		if skipped < q.offset {
+			skipped++
+			continue
+		}
+
+		results = append(results, SearchResult{Post: entry.post, Score: hit.Score})
+
+		if len(results) > q.limit {
+			break
+		}
+	}
+
+	return q.page(results), nil
+}
+
+func (m *MemoryStore) Tags(ctx context.Context) ([]Facet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+
+	for _, entry := range m.mp {
+		if entry.post.DeletedAt != nil {
+			continue
+		}
+
+		for _, tag := range entry.post.Tags {
+			counts[tag]++
+		}
+	}
+
+	return sortFacets(counts), nil
+}
+
+func (m *MemoryStore) Categories(ctx context.Context) ([]Facet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+
+	for _, entry := range m.mp {
+		if entry.post.DeletedAt == nil && entry.post.Category != "" {
+			counts[entry.post.Category]++
+		}
+	}
+
+	return sortFacets(counts), nil
+}
+
+func (m *MemoryStore) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	from, to, err := parseRename(from, to)
+
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = ctx.Err()
+
+	if err != nil {
+		return 0, err
+	}
+
+	renamed := 0
+
+	// Trashed posts are renamed too so they are up to date when restored
+	for _, entry := range m.entries() {
+		if !hasAnyTag(entry.post.Tags, from) {
+			continue
+		}
+
+		post := entry.post
+		post.Tags = renamedTags(post.Tags, from, to)
+
+		err = m.record(walRecord{Op: walOpPut, Key: entry.key, Post: post})
+
+		if err != nil {
+			return renamed, err
+		}
+
+		m.put(entry.key, post)
+		renamed++
+	}
+
+	m.compactIfDue()
+
+	return renamed, nil
+}
+
+func (m *MemoryStore) Transition(ctx context.Context, slug string, version int64, status models.Status) (models.Post, error) {
+	err := checkStatus(status)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = ctx.Err()
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	entry, ok := m.live(slug)
+
+	if !ok {
+		return models.Post{}, ErrDoesNotExist
+	}
+
+	post, err := transitionedPost(entry.post, version, status)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = m.record(walRecord{Op: walOpPut, Key: slug, Post: post})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	m.put(slug, post)
+	m.compactIfDue()
+
+	return post, nil
+}
+
+func (m *MemoryStore) Schedule(ctx context.Context, slug string, version int64, at *time.Time) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	entry, ok := m.live(slug)
+
+	if !ok {
+		return models.Post{}, ErrDoesNotExist
+	}
+
+	post, err := scheduledPost(entry.post, version, at)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = m.record(walRecord{Op: walOpPut, Key: slug, Post: post})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	m.put(slug, post)
+	m.compactIfDue()
+
+	return post, nil
+}
+
+// scheduled returns the posts outside the trash that are scheduled, soonest
+// first
+func (m *MemoryStore) scheduled() []models.Post {
+	var scheduledPosts []models.Post
+
+	for _, entry := range m.mp {
+		if entry.post.DeletedAt == nil && entry.post.PublishAt != nil {
+			scheduledPosts = append(scheduledPosts, entry.post)
+		}
+	}
+
+	sortScheduled(scheduledPosts)
+
+	return scheduledPosts
+}
+
+func (m *MemoryStore) Scheduled(ctx context.Context) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.scheduled(), nil
+}
+
+func (m *MemoryStore) PublishDue(ctx context.Context, t time.Time) ([]models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var publishedPosts []models.Post
+
+	for _, post := range m.scheduled() {
+		if !isDue(post, t) {
+			break
+		}
+
+		post = publishedOnSchedule(post)
+
+		err = m.record(walRecord{Op: walOpPut, Key: post.Slug, Post: post})
+
+		if err != nil {
+			return publishedPosts, err
+		}
+
+		m.put(post.Slug, post)
+		publishedPosts = append(publishedPosts, post)
+	}
+
+	m.compactIfDue()
+
+	return publishedPosts, nil
+}
+
+func (m *MemoryStore) Revisions(ctx context.Context, slug string) ([]models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := m.live(slug)
+
+	if !ok {
+		return nil, ErrDoesNotExist
+	}
+
+	return append([]models.Revision(nil), m.revisions[entry.post.ID]...), nil
+}
+
+func (m *MemoryStore) Revision(ctx context.Context, slug string, number int) (models.Revision, error) {
+	revisions, err := m.Revisions(ctx, slug)
+
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	if number < 1 || number > len(revisions) {
+		return models.Revision{}, ErrRevisionDoesNotExist
+	}
+
+	return revisions[number-1], nil
+}
+
+func (m *MemoryStore) RestoreRevision(ctx context.Context, slug string, number int) (models.Post, error) {
+	return restoreRevision(ctx, m, slug, number)
+}
+
+func (m *MemoryStore) Trash(ctx context.Context) ([]models.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var trashedPosts []models.Post
+
+	for _, entry := range m.mp {
+		if entry.post.DeletedAt != nil {
+			trashedPosts = append(trashedPosts, entry.post)
+		}
+	}
+
+	sortTrash(trashedPosts)
+
+	return trashedPosts, nil
+}
+
+func (m *MemoryStore) Restore(ctx context.Context, slug string) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	entry, ok := m.trashed(slug)
+
+	if !ok {
+		return models.Post{}, ErrDoesNotExist
+	}
+
+	post := entry.post
+	post.DeletedAt = nil
+
+	err = m.record(walRecord{Op: walOpPut, Key: slug, Post: post})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	m.put(slug, post)
+	m.compactIfDue()
+
+	return post, nil
+}
+
+func (m *MemoryStore) Purge(ctx context.Context, slug string) (primitive.ObjectID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	entry, ok := m.trashed(slug)
+
+	if !ok {
+		return primitive.NilObjectID, ErrDoesNotExist
+	}
+
+	err = m.record(walRecord{Op: walOpDelete, Key: slug})
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	m.delete(slug)
+	m.compactIfDue()
+
+	return entry.post.ID, nil
+}
+
+func (m *MemoryStore) PurgeDeletedBefore(ctx context.Context, t time.Time) ([]primitive.ObjectID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []primitive.ObjectID
+
+	for _, entry := range m.entries() {
+		if entry.post.DeletedAt == nil || !entry.post.DeletedAt.Before(t) {
+			continue
+		}
+
+		err = m.record(walRecord{Op: walOpDelete, Key: entry.key})
+
+		if err != nil {
+			return purged, err
+		}
+
+		m.delete(entry.key)
+		purged = append(purged, entry.post.ID)
+	}
+
+	m.compactIfDue()
+
+	return purged, nil
+}
+
 func (m *MemoryStore) Disconnect(_ context.Context) error {
-	return nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.wal == nil {
+		return nil
+	}
+
+	return m.wal.close()
 }
 
 func (m *MemoryStore) Clean(_ context.Context) error {
-	for k := range m.mp {
-		delete(m.mp, k)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.record(walRecord{Op: walOpClean})
+
+	if err != nil {
+		return err
 	}
 
+	m.clean()
+	m.compactIfDue()
+
 	return nil
 }
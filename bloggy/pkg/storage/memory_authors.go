@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+// MemoryAuthorStore is safe for concurrent use like MemoryStore, authors
+// are keyed by the hex form of their ID
+type MemoryAuthorStore struct {
+	mu  sync.RWMutex
+	mp  map[string]models.Author
+	wal *writeAheadLog
+}
+
+func CreateMemoryAuthorStore() AuthorStorage {
+	return &MemoryAuthorStore{mp: map[string]models.Author{}}
+}
+
+// CreateDurableMemoryAuthorStore creates a memory author store that logs
+// every change in dir, like CreateDurableMemoryStore does for posts. The
+// directory must not be shared with a post store
+func CreateDurableMemoryAuthorStore(dir string, compactEvery int) (AuthorStorage, error) {
+	m := CreateMemoryAuthorStore().(*MemoryAuthorStore)
+
+	wal, err := openWriteAheadLog(dir, compactEvery, m.replay)
+	if err != nil {
+		return nil, err
+	}
+
+	m.wal = wal
+
+	return m, nil
+}
+
+func (m *MemoryAuthorStore) replay(rec walRecord) {
+	switch rec.Op {
+	case walOpPut:
+		m.mp[rec.Key] = *rec.Author
+	case walOpDelete:
+		delete(m.mp, rec.Key)
+	case walOpClean:
+		m.mp = map[string]models.Author{}
+	}
+}
+
+// record and compactIfDue must be called with the write lock held
+func (m *MemoryAuthorStore) record(rec walRecord) error {
+	if m.wal == nil {
+		return nil
+	}
+
+	return m.wal.append(rec)
+}
+
+func (m *MemoryAuthorStore) compactIfDue() {
+	if m.wal == nil || !m.wal.compactionDue() {
+		return
+	}
+
+	var recs []walRecord
+
+	for key := range m.mp {
+		author := m.mp[key]
+		recs = append(recs, walRecord{Op: walOpPut, Key: key, Author: &author})
+	}
+
+	// The log still holds every change, compaction is retried on next write
+	err := m.wal.compact(recs)
+
+	if err != nil {
+		log.Printf("ERROR Unable to compact write-ahead log: %s\n", err)
+	}
+}
+
+// put logs and stores author
+func (m *MemoryAuthorStore) put(author models.Author) error {
+	key := author.ID.Hex()
+
+	err := m.record(walRecord{Op: walOpPut, Key: key, Author: &author})
+
+	if err != nil {
+		return err
+	}
+
+	m.mp[key] = author
+	m.compactIfDue()
+
+	return nil
+}
+
+func (m *MemoryAuthorStore) Insert(ctx context.Context, author models.Author) (models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	author, err = normalizeAuthor(author)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	author = newAuthor(author)
+
+	err = m.put(author)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+func (m *MemoryAuthorStore) Find(ctx context.Context, id primitive.ObjectID) (models.Author, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	author, ok := m.mp[id.Hex()]
+
+	if !ok {
+		return models.Author{}, ErrAuthorDoesNotExist
+	}
+
+	return author, nil
+}
+
+func (m *MemoryAuthorStore) Modify(ctx context.Context, id primitive.ObjectID, author models.Author) (models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	author, err = normalizeAuthor(author)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	existing, ok := m.mp[id.Hex()]
+
+	if !ok {
+		return models.Author{}, ErrAuthorDoesNotExist
+	}
+
+	author = modifiedAuthor(existing, author)
+
+	err = m.put(author)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+func (m *MemoryAuthorStore) Remove(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return err
+	}
+
+	key := id.Hex()
+
+	_, ok := m.mp[key]
+
+	if !ok {
+		return ErrAuthorDoesNotExist
+	}
+
+	err = m.record(walRecord{Op: walOpDelete, Key: key})
+
+	if err != nil {
+		return err
+	}
+
+	delete(m.mp, key)
+	m.compactIfDue()
+
+	return nil
+}
+
+func (m *MemoryAuthorStore) All(ctx context.Context) ([]models.Author, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make([]models.Author, 0, len(m.mp))
+
+	for _, author := range m.mp {
+		authors = append(authors, author)
+	}
+
+	sortAuthors(authors)
+
+	return authors, nil
+}
+
+func (m *MemoryAuthorStore) Disconnect(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.wal == nil {
+		return nil
+	}
+
+	return m.wal.close()
+}
+
+func (m *MemoryAuthorStore) Clean(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.record(walRecord{Op: walOpClean})
+
+	if err != nil {
+		return err
+	}
+
+	m.mp = map[string]models.Author{}
+	m.compactIfDue()
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+// MemoryCommentStore is safe for concurrent use like MemoryStore, comments
+// are keyed by the hex form of their ID
+type MemoryCommentStore struct {
+	mu  sync.RWMutex
+	mp  map[string]models.Comment
+	wal *writeAheadLog
+}
+
+func CreateMemoryCommentStore() CommentStorage {
+	return &MemoryCommentStore{mp: map[string]models.Comment{}}
+}
+
+// CreateDurableMemoryCommentStore creates a memory comment store that logs
+// every change in dir, like CreateDurableMemoryStore does for posts. The
+// directory must not be shared with another store
+func CreateDurableMemoryCommentStore(dir string, compactEvery int) (CommentStorage, error) {
+	m := CreateMemoryCommentStore().(*MemoryCommentStore)
+
+	wal, err := openWriteAheadLog(dir, compactEvery, m.replay)
+	if err != nil {
+		return nil, err
+	}
+
+	m.wal = wal
+
+	return m, nil
+}
+
+func (m *MemoryCommentStore) replay(rec walRecord) {
+	switch rec.Op {
+	case walOpPut:
+		m.mp[rec.Key] = *rec.Comment
+	case walOpDelete:
+		delete(m.mp, rec.Key)
+	case walOpClean:
+		m.mp = map[string]models.Comment{}
+	}
+}
+
+// record and compactIfDue must be called with the write lock held
+func (m *MemoryCommentStore) record(rec walRecord) error {
+	if m.wal == nil {
+		return nil
+	}
+
+	return m.wal.append(rec)
+}
+
+func (m *MemoryCommentStore) compactIfDue() {
+	if m.wal == nil || !m.wal.compactionDue() {
+		return
+	}
+
+	var recs []walRecord
+
+	for key := range m.mp {
+		comment := m.mp[key]
+		recs = append(recs, walRecord{Op: walOpPut, Key: key, Comment: &comment})
+	}
+
+	// The log still holds every change, compaction is retried on next write
+	err := m.wal.compact(recs)
+
+	if err != nil {
+		log.Printf("ERROR Unable to compact write-ahead log: %s\n", err)
+	}
+}
+
+// put logs and stores comment
+func (m *MemoryCommentStore) put(comment models.Comment) error {
+	key := comment.ID.Hex()
+
+	err := m.record(walRecord{Op: walOpPut, Key: key, Comment: &comment})
+
+	if err != nil {
+		return err
+	}
+
+	m.mp[key] = comment
+	m.compactIfDue()
+
+	return nil
+}
+
+// remove logs and deletes the comments with keys
+func (m *MemoryCommentStore) remove(keys []string) error {
+	for _, key := range keys {
+		err := m.record(walRecord{Op: walOpDelete, Key: key})
+
+		if err != nil {
+			return err
+		}
+
+		delete(m.mp, key)
+	}
+
+	m.compactIfDue()
+
+	return nil
+}
+
+func (m *MemoryCommentStore) Insert(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err = normalizeComment(comment)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if !comment.ParentID.IsZero() {
+		parent, ok := m.mp[comment.ParentID.Hex()]
+
+		if !ok {
+			return models.Comment{}, ErrInvalidParent
+		}
+
+		err = checkParent(comment, parent)
+
+		if err != nil {
+			return models.Comment{}, err
+		}
+	}
+
+	comment = newComment(comment)
+
+	err = m.put(comment)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return comment, nil
+}
+
+func (m *MemoryCommentStore) Find(ctx context.Context, id primitive.ObjectID) (models.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, ok := m.mp[id.Hex()]
+
+	if !ok {
+		return models.Comment{}, ErrCommentDoesNotExist
+	}
+
+	return comment, nil
+}
+
+func (m *MemoryCommentStore) List(ctx context.Context, postID primitive.ObjectID, status models.CommentStatus) ([]models.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCommentStatus(status, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	comments := []models.Comment{}
+
+	for _, comment := range m.mp {
+		if (postID.IsZero() || comment.PostID == postID) && (status == "" || comment.Status == status) {
+			comments = append(comments, comment)
+		}
+	}
+
+	sortComments(comments)
+
+	return comments, nil
+}
+
+func (m *MemoryCommentStore) Moderate(ctx context.Context, id primitive.ObjectID, status models.CommentStatus) (models.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	err = checkCommentStatus(status, false)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, ok := m.mp[id.Hex()]
+
+	if !ok {
+		return models.Comment{}, ErrCommentDoesNotExist
+	}
+
+	comment.Status = status
+	comment.UpdatedAt = now()
+
+	err = m.put(comment)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return comment, nil
+}
+
+func (m *MemoryCommentStore) Remove(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return err
+	}
+
+	_, ok := m.mp[id.Hex()]
+
+	if !ok {
+		return ErrCommentDoesNotExist
+	}
+
+	// Collect the replies level by level, down to the last reply
+	keys := []string{id.Hex()}
+	thread := map[primitive.ObjectID]bool{id: true}
+
+	for found := true; found; {
+		found = false
+
+		for key, comment := range m.mp {
+			if thread[comment.ParentID] && !thread[comment.ID] {
+				thread[comment.ID] = true
+				keys = append(keys, key)
+				found = true
+			}
+		}
+	}
+
+	return m.remove(keys)
+}
+
+func (m *MemoryCommentStore) RemovePost(ctx context.Context, postID primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := ctx.Err()
+
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+
+	for key, comment := range m.mp {
+		if comment.PostID == postID {
+			keys = append(keys, key)
+		}
+	}
+
+	return m.remove(keys)
+}
+
+func (m *MemoryCommentStore) Disconnect(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.wal == nil {
+		return nil
+	}
+
+	return m.wal.close()
+}
+
+func (m *MemoryCommentStore) Clean(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.record(walRecord{Op: walOpClean})
+
+	if err != nil {
+		return err
+	}
+
+	m.mp = map[string]models.Comment{}
+	m.compactIfDue()
+
+	return nil
+}
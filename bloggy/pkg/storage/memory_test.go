@@ -0,0 +1,671 @@
+package storage
+
+import (
+	"context"
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/slug"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getDurableMemoryStore(t *testing.T, dir string, compactEvery int) Storage {
+	store, err := CreateDurableMemoryStore(dir, compactEvery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func assertFound(t *testing.T, store Storage, expectedPost models.Post) {
+	post, err := store.Find(context.Background(), slug.Make(expectedPost.Title))
+
+	if err != nil {
+		t.Errorf("Unable to find %s: %s", expectedPost.Title, err)
+		return
+	}
+
+	if !post.IsEqual(expectedPost) {
+		t.Errorf("Expected %v but got %v", expectedPost, post)
+	}
+}
+
+func assertCount(t *testing.T, store Storage, expectedCount int) {
+	posts, err := store.All(context.Background())
+
+	assertNil(t, err)
+
+	if len(posts) != expectedCount {
+		t.Errorf("Expected item count to be %d but got %d", expectedCount, len(posts))
+	}
+}
+
+func assertNil(t *testing.T, err error) {
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func assertError(t *testing.T, err error, expected error) {
+	if err != expected {
+		t.Errorf("Expected error to be %v but got %v", expected, err)
+	}
+}
+
+func insert(t *testing.T, store Storage, post models.Post) {
+	_, err := store.Insert(context.Background(), post)
+	assertNil(t, err)
+}
+
+func modify(t *testing.T, store Storage, key string, post models.Post) {
+	_, err := store.Modify(context.Background(), key, AnyVersion, post)
+	assertNil(t, err)
+}
+
+func TestDurableMemoryStoreReplay(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	testPostOne := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	testPostTwo := models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"}
+	testPostModify := models.Post{Title: "Hello", Name: "Vishnu", Content: "Golang is awesome!!"}
+
+	store := getDurableMemoryStore(t, dir, 0)
+
+	insert(t, store, testPostOne)
+	insert(t, store, testPostTwo)
+	modify(t, store, "hello", testPostModify)
+	assertNil(t, store.Remove(ctx, "world", AnyVersion))
+	assertNil(t, store.Disconnect(ctx))
+
+	store = getDurableMemoryStore(t, dir, 0)
+	defer store.Disconnect(ctx)
+
+	assertCount(t, store, 1)
+	assertFound(t, store, testPostModify)
+
+	_, err := store.Find(ctx, "world")
+	assertError(t, err, ErrDoesNotExist)
+
+	trashedPosts, err := store.Trash(ctx)
+	assertNil(t, err)
+
+	if len(trashedPosts) != 1 || !trashedPosts[0].IsEqual(testPostTwo) {
+		t.Errorf("Expected %v to be replayed into trash but got %v", testPostTwo, trashedPosts)
+	}
+
+	revisions, err := store.Revisions(ctx, "hello")
+	assertNil(t, err)
+
+	if len(revisions) != 2 || revisions[0].Content != testPostOne.Content || revisions[1].Content != testPostModify.Content {
+		t.Errorf("Expected revisions of %v to be replayed but got %+v", testPostModify, revisions)
+	}
+}
+
+func TestDurableMemoryStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := getDurableMemoryStore(t, dir, 2)
+
+	testPostOne := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	testPostTwo := models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"}
+	testPostThree := models.Post{Title: "Golang", Name: "Bob", Content: "Golang is awesome!!"}
+
+	insert(t, store, testPostOne)
+	insert(t, store, testPostTwo)
+
+	info, err := os.Stat(filepath.Join(dir, walLogFile))
+	assertNil(t, err)
+
+	// The emptied log only holds its generation
+	generation, err := encodeWALRecord(walRecord{Op: walOpGeneration, Generation: 1})
+	assertNil(t, err)
+
+	if info.Size() != int64(len(generation)) {
+		t.Errorf("Expected log to be empty after compaction but got %d bytes", info.Size())
+	}
+
+	insert(t, store, testPostThree)
+	assertNil(t, store.Disconnect(ctx))
+
+	store = getDurableMemoryStore(t, dir, 2)
+	defer store.Disconnect(ctx)
+
+	// Snapshot and log replay keeps insertion order
+	posts, err := store.All(ctx)
+	assertNil(t, err)
+
+	expectedPosts := []models.Post{testPostOne, testPostTwo, testPostThree}
+
+	if len(posts) != len(expectedPosts) {
+		t.Fatalf("Expected item count to be %d but got %d", len(expectedPosts), len(posts))
+	}
+
+	for i := range expectedPosts {
+		if !expectedPosts[i].IsEqual(posts[i]) {
+			t.Errorf("Expected index %d post to be %v but got %v", i, expectedPosts[i], posts[i])
+		}
+	}
+}
+
+func TestDurableMemoryStoreCompactionCrash(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	logPath := filepath.Join(dir, walLogFile)
+
+	testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+
+	store := getDurableMemoryStore(t, dir, 0)
+	insert(t, store, testPost)
+
+	testPost.Content = "Hello again"
+	modify(t, store, "hello", testPost)
+	assertNil(t, store.Disconnect(ctx))
+
+	raw, err := os.ReadFile(logPath)
+	assertNil(t, err)
+
+	store = getDurableMemoryStore(t, dir, 1)
+	insert(t, store, models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"})
+	assertNil(t, store.Disconnect(ctx))
+
+	// Simulate a crash after the snapshot is written but before the log
+	// it holds is emptied
+	assertNil(t, os.WriteFile(logPath, raw, 0o644))
+
+	store = getDurableMemoryStore(t, dir, 0)
+
+	assertCount(t, store, 2)
+
+	revisions, err := store.Revisions(ctx, "hello")
+	assertNil(t, err)
+
+	if len(revisions) != 2 || revisions[0].Number != 1 || revisions[1].Number != 2 {
+		t.Errorf("Expected revisions 1 and 2 but got %+v", revisions)
+	}
+
+	// Records appended after the stale log is dropped are replayed
+	insert(t, store, models.Post{Title: "Golang", Name: "Bob", Content: "Golang is awesome!!"})
+	assertNil(t, store.Disconnect(ctx))
+
+	store = getDurableMemoryStore(t, dir, 0)
+	defer store.Disconnect(ctx)
+
+	assertCount(t, store, 3)
+
+	revisions, err = store.Revisions(ctx, "hello")
+	assertNil(t, err)
+
+	if len(revisions) != 2 {
+		t.Errorf("Expected 2 revisions but got %+v", revisions)
+	}
+}
+
+func TestDurableMemoryStoreAliases(t *testing.T) {
+	ctx := context.Background()
+
+	// Aliases are implied by the logged puts and written out by compaction
+	for _, compactEvery := range []int{0, 3} {
+		dir := t.TempDir()
+
+		testPost := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+
+		store := getDurableMemoryStore(t, dir, compactEvery)
+
+		insert(t, store, testPost)
+
+		testPost.Slug = "hello-again"
+		modify(t, store, "hello", testPost)
+
+		testPost.Slug = "greeting"
+		modify(t, store, "hello-again", testPost)
+
+		assertNil(t, store.Disconnect(ctx))
+
+		store = getDurableMemoryStore(t, dir, compactEvery)
+
+		for _, slug := range []string{"hello", "hello-again", "greeting"} {
+			foundPost, err := store.Find(ctx, slug)
+			assertNil(t, err)
+
+			if foundPost.Slug != "greeting" {
+				t.Errorf("Expected %s to resolve to greeting but got %s", slug, foundPost.Slug)
+			}
+		}
+
+		assertNil(t, store.Disconnect(ctx))
+	}
+}
+
+// writeWAL writes recs to the log in dir as an older build would have
+func writeWAL(t *testing.T, dir string, recs ...walRecord) {
+	var raw []byte
+
+	for _, rec := range recs {
+		frame, err := encodeWALRecord(rec)
+		assertNil(t, err)
+
+		raw = append(raw, frame...)
+	}
+
+	assertNil(t, os.WriteFile(filepath.Join(dir, walLogFile), raw, 0o644))
+}
+
+func TestDurableMemoryStoreLegacySlugs(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	legacyPost := func(title string) models.Post {
+		return models.Post{ID: primitive.NewObjectID(), Title: title, Name: "Vishnu", Content: "Hello world", Version: 1}
+	}
+
+	hello, world, golang, renamed := legacyPost("Hello"), legacyPost("World"), legacyPost("Golang"), legacyPost("Hello!")
+	renamed.ID = hello.ID
+
+	// Posts were keyed by title before there were slugs
+	writeWAL(t, dir,
+		walRecord{Op: walOpPut, Key: "Hello", Post: hello},
+		walRecord{Op: walOpPut, Key: "World", Post: world},
+		walRecord{Op: walOpPut, Key: "Golang", Post: golang},
+		walRecord{Op: walOpPut, Key: "Hello", Post: renamed},
+		walRecord{Op: walOpPut, Key: "Hello world", Post: legacyPost("Hello world")},
+		walRecord{Op: walOpPut, Key: "Hello, World!", Post: legacyPost("Hello, World!")},
+		walRecord{Op: walOpDelete, Key: "World"},
+	)
+
+	// Compaction on the first write makes the slugs permanent
+	store := getDurableMemoryStore(t, dir, 1)
+	insert(t, store, models.Post{Title: "Golang", Name: "Shankar", Content: "This is golang!!"})
+	assertNil(t, store.Disconnect(ctx))
+
+	store = getDurableMemoryStore(t, dir, 1)
+	defer store.Disconnect(ctx)
+
+	posts, err := store.All(ctx)
+	assertNil(t, err)
+
+	var slugs []string
+
+	for _, post := range posts {
+		slugs = append(slugs, post.Slug+" "+post.Title)
+	}
+
+	expected := "[hello Hello! golang Golang hello-world Hello world hello-world-2 Hello, World! golang-2 Golang]"
+
+	if fmt.Sprint(slugs) != expected {
+		t.Errorf("Expected posts %s but got %v", expected, slugs)
+	}
+
+	_, err = store.Find(ctx, "world")
+	assertError(t, err, ErrDoesNotExist)
+
+	_, err = store.Find(ctx, "missing")
+	assertError(t, err, ErrDoesNotExist)
+}
+
+func TestDurableMemoryStoreLegacyPublishedAt(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	createdAt := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	published := models.Post{ID: primitive.NewObjectID(), Slug: "hello", Title: "Hello", Name: "Vishnu", Content: "Hello world", Status: models.StatusPublished, CreatedAt: createdAt, Version: 1}
+	draft := models.Post{ID: primitive.NewObjectID(), Slug: "world", Title: "World", Name: "Vishnu", Content: "Hello world", Status: models.StatusDraft, CreatedAt: createdAt, Version: 1}
+
+	// Posts were published without a publishedAt before it was set
+	writeWAL(t, dir,
+		walRecord{Op: walOpPut, Key: "hello", Post: published},
+		walRecord{Op: walOpPut, Key: "world", Post: draft},
+	)
+
+	store := getDurableMemoryStore(t, dir, 0)
+	defer store.Disconnect(ctx)
+
+	page, err := store.List(ctx, ListOptions{Sort: "-" + SortPublishedAt})
+	assertNil(t, err)
+
+	if len(page.Posts) != 1 || page.Posts[0].PublishedAt == nil || !page.Posts[0].PublishedAt.Equal(createdAt) {
+		t.Errorf("Expected hello to be published when created at %s but got %v", createdAt, page.Posts)
+	}
+}
+
+func TestDurableMemoryAuthorStoreReplay(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		store, err := CreateDurableMemoryAuthorStore(dir, compactEvery)
+		assertNil(t, err)
+
+		vishnu, err := store.Insert(ctx, models.Author{Name: "Vishnu"})
+		assertNil(t, err)
+
+		shankar, err := store.Insert(ctx, models.Author{Name: "Shankar"})
+		assertNil(t, err)
+
+		_, err = store.Modify(ctx, vishnu.ID, models.Author{Name: "Vishnu", Bio: "Writes about Go"})
+		assertNil(t, err)
+
+		assertNil(t, store.Remove(ctx, shankar.ID))
+		assertNil(t, store.Disconnect(ctx))
+
+		store, err = CreateDurableMemoryAuthorStore(dir, compactEvery)
+		assertNil(t, err)
+
+		authors, err := store.All(ctx)
+		assertNil(t, err)
+
+		if len(authors) != 1 || authors[0].ID != vishnu.ID || authors[0].Bio != "Writes about Go" {
+			t.Errorf("Expected modified %v to be replayed but got %v", vishnu, authors)
+		}
+
+		assertNil(t, store.Disconnect(ctx))
+	}
+}
+
+func TestDurableMemoryCommentStoreReplay(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		ctx := context.Background()
+		postID := primitive.NewObjectID()
+
+		store, err := CreateDurableMemoryCommentStore(dir, compactEvery)
+		assertNil(t, err)
+
+		first, err := store.Insert(ctx, models.Comment{PostID: postID, Name: "Vishnu", Content: "First"})
+		assertNil(t, err)
+
+		second, err := store.Insert(ctx, models.Comment{PostID: postID, Name: "Shankar", Content: "Second"})
+		assertNil(t, err)
+
+		_, err = store.Moderate(ctx, first.ID, models.CommentApproved)
+		assertNil(t, err)
+
+		assertNil(t, store.Remove(ctx, second.ID))
+		assertNil(t, store.Disconnect(ctx))
+
+		store, err = CreateDurableMemoryCommentStore(dir, compactEvery)
+		assertNil(t, err)
+
+		comments, err := store.List(ctx, postID, "")
+		assertNil(t, err)
+
+		if len(comments) != 1 || comments[0].ID != first.ID || comments[0].Status != models.CommentApproved {
+			t.Errorf("Expected approved %v to be replayed but got %v", first, comments)
+		}
+
+		assertNil(t, store.Disconnect(ctx))
+	}
+}
+
+func TestDurableMemoryStoreTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	testPostOne := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	testPostTwo := models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"}
+
+	store := getDurableMemoryStore(t, dir, 0)
+	insert(t, store, testPostOne)
+	assertNil(t, store.Disconnect(ctx))
+
+	logPath := filepath.Join(dir, walLogFile)
+
+	info, err := os.Stat(logPath)
+	assertNil(t, err)
+
+	intactSize := info.Size()
+
+	// Simulate a crash in the middle of appending a record
+	frame, err := encodeWALRecord(walRecord{Op: walOpPut, Key: "world", Post: testPostTwo})
+	assertNil(t, err)
+
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0)
+	assertNil(t, err)
+
+	_, err = file.Write(frame[:len(frame)/2])
+	assertNil(t, err)
+	assertNil(t, file.Close())
+
+	store = getDurableMemoryStore(t, dir, 0)
+
+	info, err = os.Stat(logPath)
+	assertNil(t, err)
+
+	if info.Size() != intactSize {
+		t.Errorf("Expected torn record to be truncated to %d bytes but got %d", intactSize, info.Size())
+	}
+
+	assertCount(t, store, 1)
+	assertFound(t, store, testPostOne)
+
+	// Records appended after recovery are replayed
+	insert(t, store, testPostTwo)
+	assertNil(t, store.Disconnect(ctx))
+
+	store = getDurableMemoryStore(t, dir, 0)
+	defer store.Disconnect(ctx)
+
+	assertCount(t, store, 2)
+	assertFound(t, store, testPostTwo)
+}
+
+func TestDurableMemoryStoreCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := getDurableMemoryStore(t, dir, 0)
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+	insert(t, store, models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"})
+	assertNil(t, store.Disconnect(ctx))
+
+	logPath := filepath.Join(dir, walLogFile)
+
+	raw, err := os.ReadFile(logPath)
+	assertNil(t, err)
+
+	// Flip a byte of the payload of the first of the records
+	raw[walHeaderSize+4] ^= 0xff
+
+	assertNil(t, os.WriteFile(logPath, raw, 0o644))
+
+	// Records following a bad one are not dropped as a torn write would be
+	_, err = CreateDurableMemoryStore(dir, 0)
+	assertError(t, err, errWALCorrupt)
+
+	info, err := os.Stat(logPath)
+	assertNil(t, err)
+
+	if info.Size() != int64(len(raw)) {
+		t.Errorf("Expected corrupt log to be left as it was at %d bytes but got %d", len(raw), info.Size())
+	}
+}
+
+func TestDurableMemoryStoreLocked(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := getDurableMemoryStore(t, dir, 0)
+
+	_, err := CreateDurableMemoryStore(dir, 0)
+	assertError(t, err, ErrWALLocked)
+
+	// The lock is released on disconnect
+	assertNil(t, store.Disconnect(ctx))
+
+	store = getDurableMemoryStore(t, dir, 0)
+	assertNil(t, store.Disconnect(ctx))
+}
+
+func TestReadOnlyMemoryStore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	testPostOne := models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"}
+	testPostTwo := models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"}
+
+	// Stores that do not exist are not created
+	_, err := CreateReadOnlyMemoryStore(filepath.Join(dir, "missing"))
+
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected a missing store to be reported but got %v", err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "missing"))
+
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected no directory to be created but got %v", err)
+	}
+
+	store := getDurableMemoryStore(t, dir, 1)
+	insert(t, store, testPostOne)
+	insert(t, store, testPostTwo)
+
+	// Not while a durable store has the log open
+	_, err = CreateReadOnlyMemoryStore(dir)
+	assertError(t, err, ErrWALLocked)
+
+	assertNil(t, store.Disconnect(ctx))
+
+	// A torn final record is skipped but left in place
+	logPath := filepath.Join(dir, walLogFile)
+
+	frame, err := encodeWALRecord(walRecord{Op: walOpPut, Key: "golang", Post: models.Post{Title: "Golang"}})
+	assertNil(t, err)
+
+	assertNil(t, os.WriteFile(logPath, frame[:len(frame)/2], 0o644))
+
+	store, err = CreateReadOnlyMemoryStore(dir)
+	assertNil(t, err)
+
+	assertCount(t, store, 2)
+	assertFound(t, store, testPostOne)
+	assertFound(t, store, testPostTwo)
+
+	_, err = store.Insert(ctx, models.Post{Title: "Golang", Name: "Bob", Content: "Golang is awesome!!"})
+	assertError(t, err, ErrReadOnly)
+	assertError(t, store.Remove(ctx, "hello", AnyVersion), ErrReadOnly)
+	assertCount(t, store, 2)
+
+	// Durable stores are not opened meanwhile
+	_, err = CreateDurableMemoryStore(dir, 0)
+	assertError(t, err, ErrWALLocked)
+
+	assertNil(t, store.Disconnect(ctx))
+
+	info, err := os.Stat(logPath)
+	assertNil(t, err)
+
+	if info.Size() != int64(len(frame)/2) {
+		t.Errorf("Expected the log to be left as it was at %d bytes but got %d", len(frame)/2, info.Size())
+	}
+}
+
+// hammer runs writers that each insert, find, modify and remove their own
+// posts while readers list every post. Run with -race to catch data races
+func hammer(t *testing.T, store Storage) {
+	const workers = 8
+	const postsPerWorker = 50
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < postsPerWorker; i++ {
+				title := fmt.Sprintf("Post %d-%d", w, i)
+				key := slug.Make(title)
+				post := models.Post{Title: title, Name: "Vishnu", Content: "Hello world"}
+
+				insert(t, store, post)
+
+				_, err := store.Find(ctx, key)
+				assertNil(t, err)
+
+				post.Content = "Golang is awesome!!"
+				modify(t, store, key, post)
+
+				// Keep every other post so the final count is known
+				if i%2 == 0 {
+					assertNil(t, store.Remove(ctx, key, AnyVersion))
+				}
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < postsPerWorker; i++ {
+				_, err := store.All(ctx)
+				assertNil(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assertCount(t, store, workers*postsPerWorker/2)
+}
+
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	hammer(t, CreateMemoryStore())
+}
+
+func TestDurableMemoryStoreConcurrentAccess(t *testing.T) {
+	dir := t.TempDir()
+
+	store := getDurableMemoryStore(t, dir, 64)
+	hammer(t, store)
+	assertNil(t, store.Disconnect(context.Background()))
+
+	store = getDurableMemoryStore(t, dir, 64)
+	defer store.Disconnect(context.Background())
+
+	assertCount(t, store, 8*50/2)
+}
+
+// editedStore is a store where the post is edited by someone else right
+// after it is found
+type editedStore struct {
+	Storage
+	t *testing.T
+}
+
+func (s editedStore) Find(ctx context.Context, key string) (models.Post, error) {
+	post, err := s.Storage.Find(ctx, key)
+
+	if err == nil {
+		modify(s.t, s.Storage, key, models.Post{Title: post.Title, Name: "Shankar", Content: "Edited meanwhile"})
+	}
+
+	return post, err
+}
+
+func TestRestoreRevisionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := CreateMemoryStore()
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+	modify(t, store, "hello", models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello again"})
+
+	_, err := restoreRevision(ctx, editedStore{store, t}, "hello", 1)
+	assertError(t, err, ErrVersionConflict)
+
+	// The edit made meanwhile is kept
+	post, err := store.Find(ctx, "hello")
+	assertNil(t, err)
+
+	if post.Content != "Edited meanwhile" {
+		t.Errorf("Expected the edit to be kept but got %v", post)
+	}
+}
@@ -2,8 +2,12 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
@@ -11,8 +15,16 @@ import (
 )
 
 type MongoStore struct {
-	client *mongo.Client
-	coll   *mongo.Collection
+	client    *mongo.Client
+	coll      *mongo.Collection
+	revisions *mongo.Collection
+	aliases   *mongo.Collection
+}
+
+// mongoAlias is an old slug of the post with PostID
+type mongoAlias struct {
+	Slug   string             `bson:"slug"`
+	PostID primitive.ObjectID `bson:"postId"`
 }
 
 func CreateMongoStore(ctx context.Context, database string, collection string) (Storage, error) {
@@ -25,39 +37,240 @@ func CreateMongoStore(ctx context.Context, database string, collection string) (
 	// Create mongo collection
 	coll := client.Database(database).Collection(collection)
 
-	// Create index
+	// Titles used to be unique, posts are now keyed by slug
+	err = dropMongoIndex(ctx, coll, "title_1")
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = backfillMongoSlugs(ctx, coll)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Posts stored before there were tags get none
+	_, err = coll.UpdateMany(ctx, bson.M{"tags": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tags": bson.A{}, "category": ""}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Posts stored before there was a workflow were public
+	_, err = coll.UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"status": models.StatusPublished}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Posts published before the time was kept were published when created
+	_, err = coll.UpdateMany(ctx, bson.M{"status": models.StatusPublished, "publishedAt": nil}, mongo.Pipeline{{{Key: "$set", Value: bson.M{"publishedAt": "$createdAt"}}}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Posts stored before there were versions are at their first
+	_, err = coll.UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": int64(1)}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Create indexes
+	mods := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "title", Value: 1}, {Key: "slug", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "name", Value: 1}, {Key: "slug", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "slug", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "updatedAt", Value: 1}, {Key: "slug", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "publishedAt", Value: 1}, {Key: "slug", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "deletedAt", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "tags", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "category", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "slug", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "authorId", Value: 1}, {Key: "slug", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "publishAt", Value: 1}, {Key: "slug", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "content", Value: "text"}},
+			Options: options.Index().SetWeights(bson.D{
+				{Key: "title", Value: searchTitleWeight},
+				{Key: "content", Value: searchContentWeight},
+			}),
+		},
+	}
+
+	_, err = coll.Indexes().CreateMany(ctx, mods)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Create revisions collection and index
+	revisions := client.Database(database).Collection(collection + "_revisions")
+
 	mod := mongo.IndexModel{
-		Keys:    bson.D{{Key: "title", Value: 1}},
+		Keys:    bson.D{{Key: "postId", Value: 1}, {Key: "number", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	}
 
-	_, err = coll.Indexes().CreateOne(ctx, mod)
+	_, err = revisions.Indexes().CreateOne(ctx, mod)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Create aliases collection and indexes
+	aliases := client.Database(database).Collection(collection + "_aliases")
+
+	mods = []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "postId", Value: 1}},
+		},
+	}
+
+	_, err = aliases.Indexes().CreateMany(ctx, mods)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &MongoStore{client, coll}, nil
+	return &MongoStore{client, coll, revisions, aliases}, nil
 }
 
-func (m *MongoStore) Insert(ctx context.Context, post models.Post) error {
-	_, err := m.coll.InsertOne(ctx, post)
+// dropMongoIndex drops the index with name if there is one
+func dropMongoIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	_, err := coll.Indexes().DropOne(ctx, name)
 
-	if mongo.IsDuplicateKeyError(err) {
-		return ErrAlreadyExists
+	var cmdErr mongo.CommandError
+
+	// Missing indexes and collections are reported as command errors
+	if errors.As(err, &cmdErr) && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound") {
+		return nil
 	}
 
+	return err
+}
+
+// backfillMongoSlugs generates slugs for posts stored before there were
+// slugs, oldest posts first so they get the unsuffixed slugs
+func backfillMongoSlugs(ctx context.Context, coll *mongo.Collection) error {
+	var posts []models.Post
+
+	filter := bson.M{"slug": bson.M{"$exists": false}}
+
+	cursor, err := coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+
 	if err != nil {
 		return err
 	}
 
+	err = cursor.All(ctx, &posts)
+
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		s, err := uniqueSlug(post.Title, func(s string) (bool, error) {
+			count, err := coll.CountDocuments(ctx, bson.M{"slug": s})
+
+			return count > 0, err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = coll.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"slug": s}})
+
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (m *MongoStore) Find(ctx context.Context, title string) (models.Post, error) {
+func (m *MongoStore) Insert(ctx context.Context, post models.Post) (models.Post, error) {
+	post, err := normalizeTaxonomy(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = insertWithUniqueSlug(newPost(post), func(post models.Post) error {
+		aliased, err := m.aliased(ctx, post.Slug, primitive.NilObjectID)
+
+		if err != nil {
+			return err
+		}
+
+		if aliased {
+			return ErrAlreadyExists
+		}
+
+		_, err = m.coll.InsertOne(ctx, post)
+
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrAlreadyExists
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	_, err = m.revisions.InsertOne(ctx, revisionOf(post))
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return post, nil
+}
+
+// findOne returns the post matched by filter unless it is in the trash
+func (m *MongoStore) findOne(ctx context.Context, filter bson.M) (models.Post, error) {
 	var foundPost models.Post
 
-	result := m.coll.FindOne(ctx, bson.M{"title": title})
+	filter["deletedAt"] = nil
+
+	result := m.coll.FindOne(ctx, filter)
 	err := result.Err()
 
 	if err == mongo.ErrNoDocuments {
@@ -68,43 +281,213 @@ func (m *MongoStore) Find(ctx context.Context, title string) (models.Post, error
 		return foundPost, err
 	}
 
-	result.Decode(&foundPost)
+	err = result.Decode(&foundPost)
+
+	return foundPost, err
+}
+
+// find returns the post with slug, unlike Find it does not resolve aliases
+func (m *MongoStore) find(ctx context.Context, slug string) (models.Post, error) {
+	return m.findOne(ctx, bson.M{"slug": slug})
+}
+
+// aliased reports whether slug is an alias of a post other than id
+func (m *MongoStore) aliased(ctx context.Context, slug string, id primitive.ObjectID) (bool, error) {
+	count, err := m.aliases.CountDocuments(ctx, bson.M{"slug": slug, "postId": bson.M{"$ne": id}})
 
-	return foundPost, nil
+	return count > 0, err
 }
 
-func (m *MongoStore) Remove(ctx context.Context, title string) error {
-	result, err := m.coll.DeleteOne(ctx, bson.M{"title": title})
+func (m *MongoStore) Find(ctx context.Context, slug string) (models.Post, error) {
+	foundPost, err := m.find(ctx, slug)
+
+	if err != ErrDoesNotExist {
+		return foundPost, err
+	}
+
+	var alias mongoAlias
+
+	err = m.aliases.FindOne(ctx, bson.M{"slug": slug}).Decode(&alias)
+
+	if err == mongo.ErrNoDocuments {
+		return foundPost, ErrDoesNotExist
+	}
 
 	if err != nil {
-		return err
+		return foundPost, err
+	}
+
+	return m.findOne(ctx, bson.M{"_id": alias.PostID})
+}
+
+// versionFilter matches the post with slug that is not in the trash, at the
+// expected version unless it is AnyVersion
+func versionFilter(slug string, version int64) bson.M {
+	if version == AnyVersion {
+		return bson.M{"slug": slug, "deletedAt": nil}
 	}
 
-	if result.DeletedCount == 0 {
-		return ErrDoesNotExist
+	return bson.M{"slug": slug, "deletedAt": nil, "version": version}
+}
+
+// mongoCASAttempts is how many times Transition and Schedule read a post and
+// update it if it did not change meanwhile before giving up with
+// ErrVersionConflict
+const mongoCASAttempts = 10
+
+// versionValue matches the version of a post as it was read, posts stored
+// before there were versions read as version 0
+func versionValue(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{int64(0), nil}}
 	}
 
-	return nil
+	return version
+}
+
+// notMatched tells apart why versionFilter matched no post
+func (m *MongoStore) notMatched(ctx context.Context, slug string) error {
+	_, err := m.find(ctx, slug)
+
+	if err != nil {
+		return err
+	}
+
+	return ErrVersionConflict
 }
 
-func (m *MongoStore) Modify(ctx context.Context, title string, post models.Post) error {
-	result, err := m.coll.UpdateOne(ctx, bson.M{"title": title}, bson.M{"$set": post})
+func (m *MongoStore) Remove(ctx context.Context, slug string, version int64) error {
+	update := bson.M{"$set": bson.M{"deletedAt": now()}}
+
+	result, err := m.coll.UpdateOne(ctx, versionFilter(slug, version), update)
 
 	if err != nil {
 		return err
 	}
 
 	if result.MatchedCount == 0 {
-		return ErrDoesNotExist
+		return m.notMatched(ctx, slug)
 	}
 
 	return nil
 }
 
+func (m *MongoStore) Modify(ctx context.Context, slug string, version int64, post models.Post) (models.Post, error) {
+	var modifiedPost models.Post
+
+	err := checkSlug(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = normalizeTaxonomy(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	// Only set client editable fields, server managed fields are kept
+	set := bson.M{
+		"name":      post.Name,
+		"title":     post.Title,
+		"content":   post.Content,
+		"tags":      post.Tags,
+		"category":  post.Category,
+		"updatedAt": now(),
+	}
+
+	// Slugs that are aliases of other posts are taken
+	if post.Slug != "" && post.Slug != slug {
+		existing, err := m.find(ctx, slug)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		aliased, err := m.aliased(ctx, post.Slug, existing.ID)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		if aliased {
+			return models.Post{}, ErrAlreadyExists
+		}
+	}
+
+	if post.Slug != "" {
+		set["slug"] = post.Slug
+	}
+
+	// The author is kept unless a new one is given
+	if !post.AuthorID.IsZero() {
+		set["authorId"] = post.AuthorID
+	}
+
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+
+	result := m.coll.FindOneAndUpdate(ctx, versionFilter(slug, version), update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	err = result.Err()
+
+	if err == mongo.ErrNoDocuments {
+		return models.Post{}, m.notMatched(ctx, slug)
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Post{}, ErrAlreadyExists
+	}
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = result.Decode(&modifiedPost)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	_, err = m.revisions.InsertOne(ctx, revisionOf(modifiedPost))
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if modifiedPost.Slug != slug {
+		err = m.addAlias(ctx, slug, modifiedPost)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+	}
+
+	return modifiedPost, nil
+}
+
+// addAlias makes slug an alias of post, which no longer needs its current
+// slug as alias if it took it back
+func (m *MongoStore) addAlias(ctx context.Context, slug string, post models.Post) error {
+	filter := bson.M{"slug": slug}
+	update := bson.M{"$set": bson.M{"postId": post.ID}}
+
+	_, err := m.aliases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	if err != nil {
+		return err
+	}
+
+	_, err = m.aliases.DeleteOne(ctx, bson.M{"slug": post.Slug})
+
+	return err
+}
+
 func (m *MongoStore) All(ctx context.Context) ([]models.Post, error) {
 	var allPosts []models.Post
 
-	cursor, err := m.coll.Find(ctx, bson.M{})
+	// Sort by _id, which increases with insertion, so that posts are
+	// returned in insertion order
+	cursor, err := m.coll.Find(ctx, bson.M{"deletedAt": nil}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
 
 	if err != nil {
 		return nil, err
@@ -119,6 +502,506 @@ func (m *MongoStore) All(ctx context.Context) ([]models.Post, error) {
 	return allPosts, nil
 }
 
+func (m *MongoStore) List(ctx context.Context, opts ListOptions) (Page, error) {
+	q, err := parseListOptions(opts)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	order, op := 1, "$gt"
+
+	if !q.ascending() {
+		order, op = -1, "$lt"
+	}
+
+	// Sort by field and break ties by slug, which is unique
+	sort := bson.D{{Key: q.field.name, Value: order}, {Key: "slug", Value: order}}
+	filter := bson.M{"deletedAt": nil}
+
+	if q.tag != "" {
+		filter["tags"] = q.tag
+	}
+
+	if q.category != "" {
+		filter["category"] = q.category
+	}
+
+	if q.status != "" {
+		filter["status"] = q.status
+	}
+
+	if !q.authorID.IsZero() {
+		filter["authorId"] = q.authorID
+	}
+
+	if q.field.optional {
+		filter[q.field.name] = bson.M{"$ne": nil}
+	}
+
+	if q.cursor != nil {
+		filter["$or"] = bson.A{
+			bson.M{q.field.name: bson.M{op: q.cursorValue}},
+			bson.M{q.field.name: q.cursorValue, "slug": bson.M{op: q.cursor.Slug}},
+		}
+	}
+
+	findOptions := options.Find().SetSort(sort).SetLimit(int64(q.limit + 1))
+
+	cursor, err := m.coll.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	var posts []models.Post
+
+	err = cursor.All(ctx, &posts)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	return q.page(posts), nil
+}
+
+func (m *MongoStore) Search(ctx context.Context, query string, opts SearchOptions) (SearchPage, error) {
+	q, err := parseSearchOptions(query, opts)
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	// The text index stems words itself, so search for them as typed
+	filter := bson.M{"$text": bson.M{"$search": strings.Join(q.words, " ")}, "deletedAt": nil}
+
+	if q.status != "" {
+		filter["status"] = q.status
+	}
+	score := bson.M{"$meta": "textScore"}
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "slug", Value: 1}}).
+		SetSkip(int64(q.offset)).
+		SetLimit(int64(q.limit + 1))
+
+	cursor, err := m.coll.Find(ctx, filter, findOptions)
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	var scoredPosts []struct {
+		models.Post `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+
+	err = cursor.All(ctx, &scoredPosts)
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	var results []SearchResult
+
+	for _, scoredPost := range scoredPosts {
+		results = append(results, SearchResult{Post: scoredPost.Post, Score: scoredPost.Score})
+	}
+
+	return q.page(results), nil
+}
+
+// facets counts the values of field over the posts outside the trash,
+// array fields count each of their elements
+func (m *MongoStore) facets(ctx context.Context, field string) ([]Facet, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deletedAt": nil}}},
+		{{Key: "$unwind", Value: "$" + field}},
+		{{Key: "$match", Value: bson.M{field: bson.M{"$ne": ""}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+
+	cursor, err := m.coll.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+
+	err = cursor.All(ctx, &counts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make([]Facet, 0, len(counts))
+
+	for _, count := range counts {
+		facets = append(facets, Facet{count.Value, count.Count})
+	}
+
+	return facets, nil
+}
+
+func (m *MongoStore) Tags(ctx context.Context) ([]Facet, error) {
+	return m.facets(ctx, "tags")
+}
+
+func (m *MongoStore) Categories(ctx context.Context) ([]Facet, error) {
+	return m.facets(ctx, "category")
+}
+
+func (m *MongoStore) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	var taggedPosts []models.Post
+
+	from, to, err := parseRename(from, to)
+
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := m.coll.Find(ctx, bson.M{"tags": bson.M{"$in": from}}, options.Find().SetProjection(bson.M{"tags": 1}))
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = cursor.All(ctx, &taggedPosts)
+
+	if err != nil || len(taggedPosts) == 0 {
+		return 0, err
+	}
+
+	// Each post is only updated if its tags did not change meanwhile
+	var writes []mongo.WriteModel
+
+	for _, post := range taggedPosts {
+		filter := bson.M{"_id": post.ID, "tags": post.Tags}
+		update := bson.M{"$set": bson.M{"tags": renamedTags(post.Tags, from, to)}}
+
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+	}
+
+	result, err := m.coll.BulkWrite(ctx, writes)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+func (m *MongoStore) Transition(ctx context.Context, slug string, version int64, status models.Status) (models.Post, error) {
+	err := checkStatus(status)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	for attempt := 0; attempt < mongoCASAttempts; attempt++ {
+		existing, err := m.find(ctx, slug)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		post, err := transitionedPost(existing, version, status)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		// Only update the post if it did not change meanwhile, try again
+		// otherwise
+		filter := bson.M{"_id": existing.ID, "deletedAt": nil, "version": versionValue(existing.Version), "status": existing.Status}
+		update := bson.M{"$set": bson.M{"status": post.Status, "publishedAt": post.PublishedAt}, "$unset": bson.M{"publishAt": ""}}
+
+		result, err := m.coll.UpdateOne(ctx, filter, update)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		if result.MatchedCount != 0 {
+			return post, nil
+		}
+	}
+
+	return models.Post{}, ErrVersionConflict
+}
+
+func (m *MongoStore) Schedule(ctx context.Context, slug string, version int64, at *time.Time) (models.Post, error) {
+	for attempt := 0; attempt < mongoCASAttempts; attempt++ {
+		existing, err := m.find(ctx, slug)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		post, err := scheduledPost(existing, version, at)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		update := bson.M{"$unset": bson.M{"publishAt": ""}}
+
+		if post.PublishAt != nil {
+			update = bson.M{"$set": bson.M{"publishAt": post.PublishAt}}
+		}
+
+		// Only update the post if it did not change meanwhile, try again
+		// otherwise
+		filter := bson.M{"_id": existing.ID, "deletedAt": nil, "version": versionValue(existing.Version), "status": existing.Status, "publishAt": existing.PublishAt}
+
+		result, err := m.coll.UpdateOne(ctx, filter, update)
+
+		if err != nil {
+			return models.Post{}, err
+		}
+
+		if result.MatchedCount != 0 {
+			return post, nil
+		}
+	}
+
+	return models.Post{}, ErrVersionConflict
+}
+
+func (m *MongoStore) Scheduled(ctx context.Context) ([]models.Post, error) {
+	var scheduledPosts []models.Post
+
+	sort := bson.D{{Key: "publishAt", Value: 1}, {Key: "slug", Value: 1}}
+
+	cursor, err := m.coll.Find(ctx, bson.M{"deletedAt": nil, "publishAt": bson.M{"$ne": nil}}, options.Find().SetSort(sort))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &scheduledPosts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduledPosts, nil
+}
+
+func (m *MongoStore) PublishDue(ctx context.Context, t time.Time) ([]models.Post, error) {
+	var duePosts, publishedPosts []models.Post
+
+	sort := bson.D{{Key: "publishAt", Value: 1}, {Key: "slug", Value: 1}}
+
+	cursor, err := m.coll.Find(ctx, bson.M{"deletedAt": nil, "publishAt": bson.M{"$lte": t}}, options.Find().SetSort(sort))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &duePosts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range duePosts {
+		post = publishedOnSchedule(post)
+
+		// Posts rescheduled, cancelled or removed meanwhile are skipped
+		filter := bson.M{"_id": post.ID, "deletedAt": nil, "publishAt": post.PublishedAt}
+		update := bson.M{"$set": bson.M{"status": post.Status, "publishedAt": post.PublishedAt}, "$unset": bson.M{"publishAt": ""}}
+
+		result, err := m.coll.UpdateOne(ctx, filter, update)
+
+		if err != nil {
+			return publishedPosts, err
+		}
+
+		if result.MatchedCount != 0 {
+			publishedPosts = append(publishedPosts, post)
+		}
+	}
+
+	return publishedPosts, nil
+}
+
+func (m *MongoStore) Revisions(ctx context.Context, slug string) ([]models.Revision, error) {
+	var revisions []models.Revision
+
+	post, err := m.find(ctx, slug)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := m.revisions.Find(ctx, bson.M{"postId": post.ID}, options.Find().SetSort(bson.D{{Key: "number", Value: 1}}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &revisions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m *MongoStore) Revision(ctx context.Context, slug string, number int) (models.Revision, error) {
+	var revision models.Revision
+
+	post, err := m.find(ctx, slug)
+
+	if err != nil {
+		return revision, err
+	}
+
+	result := m.revisions.FindOne(ctx, bson.M{"postId": post.ID, "number": number})
+	err = result.Err()
+
+	if err == mongo.ErrNoDocuments {
+		return revision, ErrRevisionDoesNotExist
+	}
+
+	if err != nil {
+		return revision, err
+	}
+
+	err = result.Decode(&revision)
+
+	return revision, err
+}
+
+func (m *MongoStore) RestoreRevision(ctx context.Context, slug string, number int) (models.Post, error) {
+	return restoreRevision(ctx, m, slug, number)
+}
+
+func (m *MongoStore) Trash(ctx context.Context) ([]models.Post, error) {
+	var trashedPosts []models.Post
+
+	sort := bson.D{{Key: "deletedAt", Value: -1}, {Key: "slug", Value: 1}}
+
+	cursor, err := m.coll.Find(ctx, bson.M{"deletedAt": bson.M{"$ne": nil}}, options.Find().SetSort(sort))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &trashedPosts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return trashedPosts, nil
+}
+
+func (m *MongoStore) Restore(ctx context.Context, slug string) (models.Post, error) {
+	var restoredPost models.Post
+
+	filter := bson.M{"slug": slug, "deletedAt": bson.M{"$ne": nil}}
+	update := bson.M{"$unset": bson.M{"deletedAt": ""}}
+
+	result := m.coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	err := result.Err()
+
+	if err == mongo.ErrNoDocuments {
+		return restoredPost, ErrDoesNotExist
+	}
+
+	if err != nil {
+		return restoredPost, err
+	}
+
+	err = result.Decode(&restoredPost)
+
+	return restoredPost, err
+}
+
+// purge permanently deletes the post matched by filter along with its
+// revisions and aliases, returning its ID or ErrDoesNotExist if there was
+// none
+func (m *MongoStore) purge(ctx context.Context, filter bson.M) (primitive.ObjectID, error) {
+	var purgedPost models.Post
+
+	result := m.coll.FindOneAndDelete(ctx, filter)
+	err := result.Err()
+
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, ErrDoesNotExist
+	}
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	err = result.Decode(&purgedPost)
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	_, err = m.revisions.DeleteMany(ctx, bson.M{"postId": purgedPost.ID})
+
+	if err != nil {
+		return purgedPost.ID, err
+	}
+
+	_, err = m.aliases.DeleteMany(ctx, bson.M{"postId": purgedPost.ID})
+
+	return purgedPost.ID, err
+}
+
+func (m *MongoStore) Purge(ctx context.Context, slug string) (primitive.ObjectID, error) {
+	return m.purge(ctx, bson.M{"slug": slug, "deletedAt": bson.M{"$ne": nil}})
+}
+
+func (m *MongoStore) PurgeDeletedBefore(ctx context.Context, t time.Time) ([]primitive.ObjectID, error) {
+	var expiredPosts []models.Post
+
+	filter := bson.M{"deletedAt": bson.M{"$lt": t}}
+
+	cursor, err := m.coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &expiredPosts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []primitive.ObjectID
+
+	// Purge one at a time, matching again in case a post was restored since
+	for _, post := range expiredPosts {
+		id, err := m.purge(ctx, bson.M{"_id": post.ID, "deletedAt": bson.M{"$lt": t}})
+
+		if err == ErrDoesNotExist {
+			continue
+		}
+
+		if err != nil {
+			return purged, err
+		}
+
+		purged = append(purged, id)
+	}
+
+	return purged, nil
+}
+
 func (m *MongoStore) Disconnect(ctx context.Context) error {
 	return m.client.Disconnect(ctx)
 }
@@ -126,5 +1009,17 @@ func (m *MongoStore) Disconnect(ctx context.Context) error {
 func (m *MongoStore) Clean(ctx context.Context) error {
 	_, err := m.coll.DeleteMany(ctx, bson.M{})
 
+	if err != nil {
+		return err
+	}
+
+	_, err = m.revisions.DeleteMany(ctx, bson.M{})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = m.aliases.DeleteMany(ctx, bson.M{})
+
 	return err
 }
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"examples/bloggy/pkg/models"
+)
+
+// MongoUnavailable fails t, which could not connect to Mongo, unless
+// BLOGGY_SKIP_MONGO is set to skip the Mongo tests. Mongo is started by
+// test_services.yml, it is exported for the tests of package storage_test
+func MongoUnavailable(t *testing.T, err error) {
+	t.Helper()
+
+	if os.Getenv("BLOGGY_SKIP_MONGO") != "" {
+		t.Skipf("Mongo is not available: %s", err)
+	}
+
+	t.Fatalf("Mongo is not available, set BLOGGY_SKIP_MONGO to skip the Mongo tests: %s", err)
+}
+
+func TestMongoStoreUnversionedPosts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store, err := CreateMongoStore(ctx, "test", "test_unversioned")
+	if err != nil {
+		MongoUnavailable(t, err)
+	}
+
+	defer store.Disconnect(context.Background())
+
+	m := store.(*MongoStore)
+	assertNil(t, m.Clean(ctx))
+
+	// Posts stored before there were versions
+	_, err = m.coll.InsertOne(ctx, bson.M{"slug": "hello", "title": "Hello", "name": "Vishnu", "content": "Hello world", "tags": bson.A{}, "status": models.StatusDraft})
+	assertNil(t, err)
+
+	store, err = CreateMongoStore(ctx, "test", "test_unversioned")
+	assertNil(t, err)
+
+	defer store.Disconnect(context.Background())
+
+	post, err := store.Find(ctx, "hello")
+	assertNil(t, err)
+
+	if post.Version != 1 {
+		t.Errorf("Expected unversioned posts to be migrated to version 1 but got %d", post.Version)
+	}
+
+	// Those written meanwhile by an older server read as version 0
+	_, err = m.coll.UpdateOne(ctx, bson.M{"slug": "hello"}, bson.M{"$unset": bson.M{"version": ""}})
+	assertNil(t, err)
+
+	post, err = store.Transition(ctx, "hello", AnyVersion, models.StatusInReview)
+	assertNil(t, err)
+
+	if post.Status != models.StatusInReview {
+		t.Errorf("Expected unversioned post to be transitioned but got %v", post)
+	}
+
+	at := time.Now().Add(time.Hour)
+
+	_, err = store.Schedule(ctx, "hello", AnyVersion, &at)
+	assertNil(t, err)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"examples/bloggy/pkg/models"
+)
+
+var ErrNotScheduled = errors.New("storage: post is not scheduled")
+
+// scheduledPost schedules post to be published at at, or cancels its
+// schedule if at is nil
+func scheduledPost(post models.Post, version int64, at *time.Time) (models.Post, error) {
+	err := checkVersion(post, version)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if at == nil {
+		if post.PublishAt == nil {
+			return models.Post{}, ErrNotScheduled
+		}
+
+		post.PublishAt = nil
+
+		return post, nil
+	}
+
+	if !post.Status.CanTransitionTo(models.StatusPublished) {
+		return models.Post{}, ErrInvalidTransition
+	}
+
+	t := at.UTC().Truncate(time.Millisecond)
+	post.PublishAt = &t
+
+	return post, nil
+}
+
+// isDue reports whether post is scheduled to be published at or before t
+func isDue(post models.Post, t time.Time) bool {
+	return post.PublishAt != nil && !post.PublishAt.After(t)
+}
+
+// publishedOnSchedule publishes post as of the time it was scheduled for
+func publishedOnSchedule(post models.Post) models.Post {
+	post.Status = models.StatusPublished
+	post.PublishedAt = post.PublishAt
+	post.PublishAt = nil
+
+	return post
+}
+
+// sortScheduled orders scheduled posts soonest first, ties are broken by
+// slug
+func sortScheduled(posts []models.Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+
+		if a.PublishAt.Equal(*b.PublishAt) {
+			return a.Slug < b.Slug
+		}
+
+		return a.PublishAt.Before(*b.PublishAt)
+	})
+}
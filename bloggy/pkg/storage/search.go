@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/search"
+)
+
+var ErrInvalidQuery = errors.New("storage: invalid search query")
+
+// SnippetSize is the approximate length of the snippets of search results
+const SnippetSize = 160
+
+// Weight of a term in the title of a post relative to one in its content
+const (
+	searchTitleWeight   = 10
+	searchContentWeight = 1
+)
+
+// SearchOptions selects a page of search results, only posts with Status if
+// set. Cursor is taken from a previous SearchPage and must be used with the
+// same query and Status
+type SearchOptions struct {
+	Limit  int
+	Cursor string
+	Status models.Status
+}
+
+// SearchResult is a post matching a search. Score ranks results within one
+// search, it is not comparable across backends. Snippet is an HTML excerpt
+// of the content with the matching words highlighted
+type SearchResult struct {
+	Post    models.Post `json:"post"`
+	Score   float64     `json:"score"`
+	Snippet string      `json:"snippet"`
+}
+
+// SearchPage is a slice of search results, most relevant first. Next and
+// Prev are cursors to the adjacent pages, empty if there is no such page
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Next    string         `json:"next,omitempty"`
+	Prev    string         `json:"prev,omitempty"`
+}
+
+// searchCursor is the offset of a page in the results of a query
+type searchCursor struct {
+	Query  string        `json:"q"`
+	Status models.Status `json:"st,omitempty"`
+	Offset int           `json:"o"`
+}
+
+// searchQuery is a validated search. Backends fetch up to limit+1 results
+// starting at offset, ranked by relevance with ties broken by slug
+type searchQuery struct {
+	query  string
+	words  []string
+	terms  []string
+	status models.Status
+	limit  int
+	offset int
+}
+
+func parseSearchOptions(query string, opts SearchOptions) (searchQuery, error) {
+	q := searchQuery{
+		query:  query,
+		words:  search.Words(query),
+		terms:  search.Terms(query),
+		status: opts.Status,
+		limit:  opts.Limit,
+	}
+
+	if len(q.terms) == 0 {
+		return q, ErrInvalidQuery
+	}
+
+	if q.status != "" {
+		err := checkStatus(q.status)
+
+		if err != nil {
+			return q, err
+		}
+	}
+
+	if q.limit <= 0 {
+		q.limit = DefaultPageLimit
+	}
+
+	if q.limit > MaxPageLimit {
+		q.limit = MaxPageLimit
+	}
+
+	if opts.Cursor == "" {
+		return q, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+
+	if err != nil {
+		return q, ErrInvalidCursor
+	}
+
+	var c searchCursor
+
+	err = json.Unmarshal(raw, &c)
+
+	// A cursor is only meaningful for the query it was created for
+	if err != nil || c.Query != query || c.Status != q.status || c.Offset < 0 {
+		return q, ErrInvalidCursor
+	}
+
+	q.offset = c.Offset
+
+	return q, nil
+}
+
+func (q searchQuery) encodeCursor(offset int) string {
+	raw, _ := json.Marshal(searchCursor{q.query, q.status, offset})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// page builds a SearchPage from up to limit+1 results fetched by a backend,
+// adding their snippets
+func (q searchQuery) page(results []SearchResult) SearchPage {
+	page := SearchPage{Results: results}
+
+	if len(results) > q.limit {
+		page.Results = results[:q.limit]
+		page.Next = q.encodeCursor(q.offset + q.limit)
+	}
+
+	if page.Results == nil {
+		page.Results = []SearchResult{}
+	}
+
+	if q.offset > 0 {
+		prev := q.offset - q.limit
+
+		if prev < 0 {
+			prev = 0
+		}
+
+		page.Prev = q.encodeCursor(prev)
+	}
+
+	for i := range page.Results {
+		page.Results[i].Snippet = search.Snippet(page.Results[i].Post.Content, q.terms, SnippetSize)
+	}
+
+	return page
+}
+
+// searchFields are the weighted fields of post that are searched
+func searchFields(post models.Post) []search.Field {
+	return []search.Field{
+		{Text: post.Title, Weight: searchTitleWeight},
+		{Text: post.Content, Weight: searchContentWeight},
+	}
+}
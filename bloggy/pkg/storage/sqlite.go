@@ -0,0 +1,1191 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"examples/bloggy/pkg/models"
+)
+
+// sqliteMigrations bring a database up to date when applied in order, the
+// number of applied migrations is kept in the user_version pragma. Only
+// append to this list, databases in use have already applied the others
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS posts (
+		seq     INTEGER PRIMARY KEY AUTOINCREMENT,
+		id      TEXT NOT NULL UNIQUE,
+		name    TEXT NOT NULL,
+		title   TEXT NOT NULL UNIQUE,
+		content TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS posts_name ON posts (name, title)`,
+	`ALTER TABLE posts ADD COLUMN createdAt TEXT NOT NULL DEFAULT '';
+	ALTER TABLE posts ADD COLUMN updatedAt TEXT NOT NULL DEFAULT '';
+	ALTER TABLE posts ADD COLUMN publishedAt TEXT;
+	CREATE INDEX posts_createdAt ON posts (createdAt, title);
+	CREATE INDEX posts_updatedAt ON posts (updatedAt, title);
+	CREATE INDEX posts_publishedAt ON posts (publishedAt, title)`,
+	`CREATE TABLE revisions (
+		postId    TEXT NOT NULL,
+		number    INTEGER NOT NULL,
+		author    TEXT NOT NULL,
+		title     TEXT NOT NULL,
+		content   TEXT NOT NULL,
+		createdAt TEXT NOT NULL,
+		PRIMARY KEY (postId, number)
+	)`,
+	`ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	UPDATE posts SET version = (SELECT COALESCE(MAX(number), 1) FROM revisions WHERE postId = posts.id)`,
+	`ALTER TABLE posts ADD COLUMN deletedAt TEXT;
+	CREATE INDEX posts_deletedAt ON posts (deletedAt)`,
+	// Posts are keyed by a unique slug instead of their title, SQLite can
+	// only drop a constraint by rebuilding the table. Slugs are generated for
+	// existing rows by backfillSQLiteSlugs
+	`CREATE TABLE posts_slugs (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		id          TEXT NOT NULL UNIQUE,
+		slug        TEXT UNIQUE,
+		name        TEXT NOT NULL,
+		title       TEXT NOT NULL,
+		content     TEXT NOT NULL,
+		createdAt   TEXT NOT NULL DEFAULT '',
+		updatedAt   TEXT NOT NULL DEFAULT '',
+		publishedAt TEXT,
+		deletedAt   TEXT,
+		version     INTEGER NOT NULL DEFAULT 1
+	);
+	INSERT INTO posts_slugs (seq, id, name, title, content, createdAt, updatedAt, publishedAt, deletedAt, version)
+		SELECT seq, id, name, title, content, createdAt, updatedAt, publishedAt, deletedAt, version FROM posts;
+	DROP TABLE posts;
+	ALTER TABLE posts_slugs RENAME TO posts;
+	CREATE INDEX posts_title ON posts (title, slug);
+	CREATE INDEX posts_name ON posts (name, slug);
+	CREATE INDEX posts_createdAt ON posts (createdAt, slug);
+	CREATE INDEX posts_updatedAt ON posts (updatedAt, slug);
+	CREATE INDEX posts_publishedAt ON posts (publishedAt, slug);
+	CREATE INDEX posts_deletedAt ON posts (deletedAt)`,
+	`CREATE TABLE aliases (
+		slug   TEXT PRIMARY KEY,
+		postId TEXT NOT NULL
+	);
+	CREATE INDEX aliases_postId ON aliases (postId)`,
+	// Full-text index of the posts table, kept up to date by triggers
+	`CREATE VIRTUAL TABLE posts_search USING fts5(
+		title,
+		content,
+		content='posts',
+		content_rowid='seq',
+		tokenize='porter unicode61 remove_diacritics 2'
+	);
+	CREATE TRIGGER posts_search_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_search (rowid, title, content) VALUES (new.seq, new.title, new.content);
+	END;
+	CREATE TRIGGER posts_search_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO posts_search (posts_search, rowid, title, content) VALUES ('delete', old.seq, old.title, old.content);
+	END;
+	CREATE TRIGGER posts_search_update AFTER UPDATE OF title, content ON posts BEGIN
+		INSERT INTO posts_search (posts_search, rowid, title, content) VALUES ('delete', old.seq, old.title, old.content);
+		INSERT INTO posts_search (rowid, title, content) VALUES (new.seq, new.title, new.content);
+	END;
+	INSERT INTO posts_search (posts_search) VALUES ('rebuild')`,
+	// Tags are stored as a JSON array for reading and in post_tags, kept up
+	// to date by triggers, for filtering and counting
+	`ALTER TABLE posts ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE posts ADD COLUMN category TEXT NOT NULL DEFAULT '';
+	CREATE INDEX posts_category ON posts (category, slug);
+	CREATE TABLE post_tags (
+		tag    TEXT NOT NULL,
+		postId TEXT NOT NULL,
+		PRIMARY KEY (tag, postId)
+	);
+	CREATE INDEX post_tags_postId ON post_tags (postId);
+	CREATE TRIGGER post_tags_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO post_tags (tag, postId) SELECT value, new.id FROM json_each(new.tags);
+	END;
+	CREATE TRIGGER post_tags_delete AFTER DELETE ON posts BEGIN
+		DELETE FROM post_tags WHERE postId = old.id;
+	END;
+	CREATE TRIGGER post_tags_update AFTER UPDATE OF tags ON posts BEGIN
+		DELETE FROM post_tags WHERE postId = old.id;
+		INSERT INTO post_tags (tag, postId) SELECT value, new.id FROM json_each(new.tags);
+	END`,
+	// Rows stored before there was a workflow were public
+	`ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+	CREATE INDEX posts_status ON posts (status, slug)`,
+	`ALTER TABLE posts ADD COLUMN publishAt TEXT;
+	CREATE INDEX posts_publishAt ON posts (publishAt, slug) WHERE publishAt IS NOT NULL`,
+	// Posts without an author have an empty authorId
+	`ALTER TABLE posts ADD COLUMN authorId TEXT NOT NULL DEFAULT '';
+	CREATE INDEX posts_authorId ON posts (authorId, slug)`,
+	// Posts published before the time was kept were published when created
+	`UPDATE posts SET publishedAt = createdAt WHERE status = 'published' AND publishedAt IS NULL`,
+}
+
+// sqlitePostColumns are the columns scanSQLitePost reads, in order
+const sqlitePostColumns = "id, slug, authorId, name, title, content, tags, category, status, createdAt, updatedAt, publishedAt, publishAt, deletedAt, version"
+
+// sqliteRevisionColumns are the columns scanSQLiteRevision reads, in order
+const sqliteRevisionColumns = "postId, number, author, title, content, createdAt"
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func CreateSQLiteStore(ctx context.Context, path string) (Storage, error) {
+	// Open database file, it is created if it does not exist
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	// Create or update tables
+	err = migrateSQLite(ctx, db, sqliteMigrations)
+
+	if err == nil {
+		err = backfillSQLiteSlugs(ctx, db)
+	}
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db}, nil
+}
+
+// migrateSQLite applies the migrations db has not applied yet
+func migrateSQLite(ctx context.Context, db *sql.DB, migrations []string) error {
+	var version int
+
+	err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+
+	if err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, migrations[version])
+
+		if err == nil {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version+1))
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("storage: sqlite migration %d: %w", version+1, err)
+		}
+
+		err = tx.Commit()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillSQLiteSlugs generates slugs for rows stored before there were
+// slugs, oldest rows first so they get the unsuffixed slugs
+func backfillSQLiteSlugs(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT id, title FROM posts WHERE slug IS NULL ORDER BY seq")
+
+	if err != nil {
+		return err
+	}
+
+	var ids, titles []string
+
+	for rows.Next() {
+		var id, title string
+
+		err = rows.Scan(&id, &title)
+
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		ids = append(ids, id)
+		titles = append(titles, title)
+	}
+
+	rows.Close()
+
+	err = rows.Err()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range ids {
+		slug, err := uniqueSlug(titles[i], func(slug string) (bool, error) {
+			var taken bool
+
+			err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM posts WHERE slug = ?)", slug).Scan(&taken)
+
+			return taken, err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = db.ExecContext(ctx, "UPDATE posts SET slug = ? WHERE id = ?", slug, ids[i])
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isUniqueConstraintError(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// withTx runs fn in a transaction which is committed if fn succeeds
+func (s *SQLiteStore) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertSQLiteRevision(ctx context.Context, tx *sql.Tx, revision models.Revision) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO revisions ("+sqliteRevisionColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		revision.PostID.Hex(), revision.Number, revision.Author, revision.Title, revision.Content,
+		formatSQLiteTime(revision.CreatedAt),
+	)
+
+	return err
+}
+
+func (s *SQLiteStore) Insert(ctx context.Context, post models.Post) (models.Post, error) {
+	post, err := normalizeTaxonomy(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = insertWithUniqueSlug(newPost(post), func(post models.Post) error {
+		err := s.withTx(ctx, func(tx *sql.Tx) error {
+			var aliased bool
+
+			err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM aliases WHERE slug = ?)", post.Slug).Scan(&aliased)
+
+			if err != nil {
+				return err
+			}
+
+			if aliased {
+				return ErrAlreadyExists
+			}
+
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO posts ("+sqlitePostColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				post.ID.Hex(), post.Slug, formatSQLiteObjectID(post.AuthorID), post.Name, post.Title, post.Content, formatSQLiteTags(post.Tags), post.Category, post.Status,
+				formatSQLiteTime(post.CreatedAt), formatSQLiteTime(post.UpdatedAt), formatSQLiteNullTime(post.PublishedAt), formatSQLiteNullTime(post.PublishAt),
+				formatSQLiteNullTime(post.DeletedAt), post.Version,
+			)
+
+			if err != nil {
+				return err
+			}
+
+			return insertSQLiteRevision(ctx, tx, revisionOf(post))
+		})
+
+		if isUniqueConstraintError(err) {
+			return ErrAlreadyExists
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return post, nil
+}
+
+type sqliteScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// sqliteExtraScanner scans columns selected after the ones a scan function
+// reads into extra
+type sqliteExtraScanner struct {
+	sqliteScanner
+	extra []interface{}
+}
+
+func (s sqliteExtraScanner) Scan(dest ...interface{}) error {
+	return s.sqliteScanner.Scan(append(dest, s.extra...)...)
+}
+
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func formatSQLiteNullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: formatSQLiteTime(*t), Valid: true}
+}
+
+func parseSQLiteTime(value string) time.Time {
+	// Rows from before timestamps were added have empty values
+	t, _ := time.Parse(timeLayout, value)
+
+	return t
+}
+
+func parseSQLiteNullTime(value sql.NullString) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	t := parseSQLiteTime(value.String)
+
+	return &t
+}
+
+func formatSQLiteTags(tags []string) string {
+	raw, _ := json.Marshal(tags)
+
+	return string(raw)
+}
+
+// formatSQLiteObjectID formats id as hex, the zero ID as an empty string
+func formatSQLiteObjectID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+
+	return id.Hex()
+}
+
+func parseSQLiteObjectID(value string) (primitive.ObjectID, error) {
+	if value == "" {
+		return primitive.NilObjectID, nil
+	}
+
+	return primitive.ObjectIDFromHex(value)
+}
+
+func scanSQLitePost(row sqliteScanner) (models.Post, error) {
+	var post models.Post
+	var id, authorID, tags, createdAt, updatedAt string
+	var publishedAt, publishAt, deletedAt sql.NullString
+
+	err := row.Scan(&id, &post.Slug, &authorID, &post.Name, &post.Title, &post.Content, &tags, &post.Category, &post.Status, &createdAt, &updatedAt, &publishedAt, &publishAt, &deletedAt, &post.Version)
+
+	if err != nil {
+		return post, err
+	}
+
+	err = json.Unmarshal([]byte(tags), &post.Tags)
+
+	if err != nil {
+		return post, err
+	}
+
+	post.CreatedAt = parseSQLiteTime(createdAt)
+	post.UpdatedAt = parseSQLiteTime(updatedAt)
+
+	post.PublishedAt = parseSQLiteNullTime(publishedAt)
+	post.PublishAt = parseSQLiteNullTime(publishAt)
+	post.DeletedAt = parseSQLiteNullTime(deletedAt)
+
+	post.AuthorID, err = parseSQLiteObjectID(authorID)
+
+	if err != nil {
+		return post, err
+	}
+
+	post.ID, err = primitive.ObjectIDFromHex(id)
+
+	return post, err
+}
+
+// findWhere returns the post matching condition unless it is in the trash
+func (s *SQLiteStore) findWhere(ctx context.Context, condition string, args ...interface{}) (models.Post, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+sqlitePostColumns+" FROM posts WHERE "+condition+" AND deletedAt IS NULL", args...)
+
+	foundPost, err := scanSQLitePost(row)
+
+	if err == sql.ErrNoRows {
+		return foundPost, ErrDoesNotExist
+	}
+
+	if err != nil {
+		return foundPost, err
+	}
+
+	return foundPost, nil
+}
+
+// find returns the post with slug, unlike Find it does not resolve aliases
+func (s *SQLiteStore) find(ctx context.Context, slug string) (models.Post, error) {
+	return s.findWhere(ctx, "slug = ?", slug)
+}
+
+func (s *SQLiteStore) Find(ctx context.Context, slug string) (models.Post, error) {
+	foundPost, err := s.find(ctx, slug)
+
+	if err != ErrDoesNotExist {
+		return foundPost, err
+	}
+
+	return s.findWhere(ctx, "id = (SELECT postId FROM aliases WHERE slug = ?)", slug)
+}
+
+// sqliteVersionMatches is the condition for a post to be at the expected
+// version, which is bound twice
+const sqliteVersionMatches = "(? = 0 OR version = ?)"
+
+// sqliteNotMatched tells apart why no post with slug was at the expected
+// version
+func sqliteNotMatched(ctx context.Context, tx *sql.Tx, slug string) error {
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM posts WHERE slug = ? AND deletedAt IS NULL)", slug).Scan(&exists)
+
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrVersionConflict
+	}
+
+	return ErrDoesNotExist
+}
+
+func (s *SQLiteStore) Remove(ctx context.Context, slug string, version int64) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			"UPDATE posts SET deletedAt = ? WHERE slug = ? AND deletedAt IS NULL AND "+sqliteVersionMatches,
+			formatSQLiteTime(now()), slug, version, version,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		removed, err := result.RowsAffected()
+
+		if err != nil {
+			return err
+		}
+
+		if removed == 0 {
+			return sqliteNotMatched(ctx, tx, slug)
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLiteStore) Modify(ctx context.Context, slug string, version int64, post models.Post) (models.Post, error) {
+	var modifiedPost models.Post
+
+	err := checkSlug(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err = normalizeTaxonomy(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		var aliased bool
+
+		// Slugs that are aliases of other posts are taken
+		err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM aliases WHERE slug = ? AND postId != (SELECT id FROM posts WHERE slug = ?))",
+			post.Slug, slug,
+		).Scan(&aliased)
+
+		if err != nil {
+			return err
+		}
+
+		if aliased {
+			return ErrAlreadyExists
+		}
+
+		// Only set client editable columns, server managed columns are kept.
+		// The slug and author are kept unless new ones are given
+		row := tx.QueryRowContext(ctx,
+			"UPDATE posts SET slug = COALESCE(NULLIF(?, ''), slug), authorId = COALESCE(NULLIF(?, ''), authorId), name = ?, title = ?, content = ?, tags = ?, category = ?, updatedAt = ?, version = version + 1 WHERE slug = ? AND deletedAt IS NULL AND "+sqliteVersionMatches+" RETURNING "+sqlitePostColumns,
+			post.Slug, formatSQLiteObjectID(post.AuthorID), post.Name, post.Title, post.Content, formatSQLiteTags(post.Tags), post.Category, formatSQLiteTime(now()), slug, version, version,
+		)
+
+		modifiedPost, err = scanSQLitePost(row)
+
+		if err == sql.ErrNoRows {
+			return sqliteNotMatched(ctx, tx, slug)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = insertSQLiteRevision(ctx, tx, revisionOf(modifiedPost))
+
+		if err != nil || modifiedPost.Slug == slug {
+			return err
+		}
+
+		// The old slug becomes an alias, the new one may have been one
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO aliases (slug, postId) VALUES (?, ?) ON CONFLICT (slug) DO UPDATE SET postId = excluded.postId",
+			slug, modifiedPost.ID.Hex(),
+		)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM aliases WHERE slug = ?", modifiedPost.Slug)
+
+		return err
+	})
+
+	if isUniqueConstraintError(err) {
+		return models.Post{}, ErrAlreadyExists
+	}
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return modifiedPost, nil
+}
+
+func (s *SQLiteStore) queryPosts(ctx context.Context, query string, args ...interface{}) ([]models.Post, error) {
+	var posts []models.Post
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		post, err := scanSQLitePost(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (s *SQLiteStore) All(ctx context.Context) ([]models.Post, error) {
+	return s.queryPosts(ctx, "SELECT "+sqlitePostColumns+" FROM posts WHERE deletedAt IS NULL ORDER BY seq")
+}
+
+func (s *SQLiteStore) List(ctx context.Context, opts ListOptions) (Page, error) {
+	q, err := parseListOptions(opts)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	order, op := "ASC", ">"
+
+	if !q.ascending() {
+		order, op = "DESC", "<"
+	}
+
+	where := "deletedAt IS NULL"
+
+	var args []interface{}
+
+	if q.tag != "" {
+		where += " AND id IN (SELECT postId FROM post_tags WHERE tag = ?)"
+		args = append(args, q.tag)
+	}
+
+	if q.category != "" {
+		where += " AND category = ?"
+		args = append(args, q.category)
+	}
+
+	if q.status != "" {
+		where += " AND status = ?"
+		args = append(args, q.status)
+	}
+
+	if !q.authorID.IsZero() {
+		where += " AND authorId = ?"
+		args = append(args, q.authorID.Hex())
+	}
+
+	if q.field.optional {
+		where += fmt.Sprintf(" AND %s IS NOT NULL", q.field.name)
+	}
+
+	// Column comes from the sortFields allowlist, it is safe to format in.
+	// Sort by column and break ties by slug, which is unique
+	if q.cursor != nil {
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND slug %[2]s ?))", q.field.name, op)
+		args = append(args, q.cursor.Value, q.cursor.Value, q.cursor.Slug)
+	}
+
+	query := fmt.Sprintf("SELECT %[1]s FROM posts WHERE %[2]s ORDER BY %[3]s %[4]s, slug %[4]s LIMIT ?", sqlitePostColumns, where, q.field.name, order)
+	args = append(args, q.limit+1)
+
+	posts, err := s.queryPosts(ctx, query, args...)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	return q.page(posts), nil
+}
+
+func (s *SQLiteStore) Search(ctx context.Context, query string, opts SearchOptions) (SearchPage, error) {
+	q, err := parseSearchOptions(query, opts)
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	// The full-text index stems words itself, so search for them as typed.
+	// Words only hold letters and digits, they are safe to quote
+	match := `"` + strings.Join(q.words, `" OR "`) + `"`
+
+	// bm25 ranks better matches lower, weighing title and content like the
+	// other backends do
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sqlitePostColumns+", score FROM posts JOIN (SELECT rowid, -bm25(posts_search, ?, ?) AS score FROM posts_search WHERE posts_search MATCH ?) AS matches ON posts.seq = matches.rowid WHERE deletedAt IS NULL AND (? = '' OR status = ?) ORDER BY score DESC, slug LIMIT ? OFFSET ?",
+		searchTitleWeight, searchContentWeight, match, q.status, q.status, q.limit+1, q.offset,
+	)
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	defer rows.Close()
+
+	var results []SearchResult
+
+	for rows.Next() {
+		var result SearchResult
+
+		result.Post, err = scanSQLitePost(sqliteExtraScanner{rows, []interface{}{&result.Score}})
+
+		if err != nil {
+			return SearchPage{}, err
+		}
+
+		results = append(results, result)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	return q.page(results), nil
+}
+
+// queryFacets reads the value and count columns of query as facets
+func (s *SQLiteStore) queryFacets(ctx context.Context, query string) ([]Facet, error) {
+	facets := []Facet{}
+
+	rows, err := s.db.QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet Facet
+
+		err = rows.Scan(&facet.Value, &facet.Count)
+
+		if err != nil {
+			return nil, err
+		}
+
+		facets = append(facets, facet)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+func (s *SQLiteStore) Tags(ctx context.Context) ([]Facet, error) {
+	return s.queryFacets(ctx, "SELECT tag, COUNT(*) AS count FROM post_tags JOIN posts ON posts.id = post_tags.postId WHERE deletedAt IS NULL GROUP BY tag ORDER BY count DESC, tag")
+}
+
+func (s *SQLiteStore) Categories(ctx context.Context) ([]Facet, error) {
+	return s.queryFacets(ctx, "SELECT category, COUNT(*) AS count FROM posts WHERE deletedAt IS NULL AND category != '' GROUP BY category ORDER BY count DESC, category")
+}
+
+func (s *SQLiteStore) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	from, to, err := parseRename(from, to)
+
+	if err != nil || len(from) == 0 {
+		return 0, err
+	}
+
+	renamed := 0
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(from)), ", ")
+		args := make([]interface{}, len(from))
+
+		for i, tag := range from {
+			args[i] = tag
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT id, tags FROM posts WHERE id IN (SELECT postId FROM post_tags WHERE tag IN ("+placeholders+"))", args...)
+
+		if err != nil {
+			return err
+		}
+
+		updated := map[string]string{}
+
+		for rows.Next() {
+			var id, raw string
+			var tags []string
+
+			err = rows.Scan(&id, &raw)
+
+			if err == nil {
+				err = json.Unmarshal([]byte(raw), &tags)
+			}
+
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			updated[id] = formatSQLiteTags(renamedTags(tags, from, to))
+		}
+
+		rows.Close()
+
+		err = rows.Err()
+
+		if err != nil {
+			return err
+		}
+
+		for id, tags := range updated {
+			_, err = tx.ExecContext(ctx, "UPDATE posts SET tags = ? WHERE id = ?", tags, id)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		renamed = len(updated)
+
+		return nil
+	})
+
+	return renamed, err
+}
+
+func (s *SQLiteStore) Transition(ctx context.Context, slug string, version int64, status models.Status) (models.Post, error) {
+	var transitioned models.Post
+
+	err := checkStatus(status)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, "SELECT "+sqlitePostColumns+" FROM posts WHERE slug = ? AND deletedAt IS NULL", slug)
+
+		existing, err := scanSQLitePost(row)
+
+		if err == sql.ErrNoRows {
+			return ErrDoesNotExist
+		}
+
+		if err != nil {
+			return err
+		}
+
+		transitioned, err = transitionedPost(existing, version, status)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE posts SET status = ?, publishedAt = ?, publishAt = NULL WHERE id = ?",
+			transitioned.Status, formatSQLiteNullTime(transitioned.PublishedAt), transitioned.ID.Hex(),
+		)
+
+		return err
+	})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return transitioned, nil
+}
+
+func (s *SQLiteStore) Schedule(ctx context.Context, slug string, version int64, at *time.Time) (models.Post, error) {
+	var scheduled models.Post
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, "SELECT "+sqlitePostColumns+" FROM posts WHERE slug = ? AND deletedAt IS NULL", slug)
+
+		existing, err := scanSQLitePost(row)
+
+		if err == sql.ErrNoRows {
+			return ErrDoesNotExist
+		}
+
+		if err != nil {
+			return err
+		}
+
+		scheduled, err = scheduledPost(existing, version, at)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE posts SET publishAt = ? WHERE id = ?", formatSQLiteNullTime(scheduled.PublishAt), scheduled.ID.Hex())
+
+		return err
+	})
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return scheduled, nil
+}
+
+func (s *SQLiteStore) Scheduled(ctx context.Context) ([]models.Post, error) {
+	return s.queryPosts(ctx, "SELECT "+sqlitePostColumns+" FROM posts WHERE deletedAt IS NULL AND publishAt IS NOT NULL ORDER BY publishAt, slug")
+}
+
+func (s *SQLiteStore) PublishDue(ctx context.Context, t time.Time) ([]models.Post, error) {
+	var publishedPosts []models.Post
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			"SELECT "+sqlitePostColumns+" FROM posts WHERE deletedAt IS NULL AND publishAt <= ? ORDER BY publishAt, slug",
+			formatSQLiteTime(t),
+		)
+
+		if err != nil {
+			return err
+		}
+
+		var duePosts []models.Post
+
+		for rows.Next() {
+			post, err := scanSQLitePost(rows)
+
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			duePosts = append(duePosts, post)
+		}
+
+		rows.Close()
+
+		err = rows.Err()
+
+		if err != nil {
+			return err
+		}
+
+		for _, post := range duePosts {
+			post = publishedOnSchedule(post)
+
+			_, err = tx.ExecContext(ctx,
+				"UPDATE posts SET status = ?, publishedAt = ?, publishAt = NULL WHERE id = ?",
+				post.Status, formatSQLiteNullTime(post.PublishedAt), post.ID.Hex(),
+			)
+
+			if err != nil {
+				return err
+			}
+
+			publishedPosts = append(publishedPosts, post)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return publishedPosts, nil
+}
+
+func scanSQLiteRevision(row sqliteScanner) (models.Revision, error) {
+	var revision models.Revision
+	var postID, createdAt string
+
+	err := row.Scan(&postID, &revision.Number, &revision.Author, &revision.Title, &revision.Content, &createdAt)
+
+	if err != nil {
+		return revision, err
+	}
+
+	revision.CreatedAt = parseSQLiteTime(createdAt)
+	revision.PostID, err = primitive.ObjectIDFromHex(postID)
+
+	return revision, err
+}
+
+func (s *SQLiteStore) Revisions(ctx context.Context, slug string) ([]models.Revision, error) {
+	var revisions []models.Revision
+
+	post, err := s.find(ctx, slug)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+sqliteRevisionColumns+" FROM revisions WHERE postId = ? ORDER BY number", post.ID.Hex())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		revision, err := scanSQLiteRevision(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *SQLiteStore) Revision(ctx context.Context, slug string, number int) (models.Revision, error) {
+	post, err := s.find(ctx, slug)
+
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+sqliteRevisionColumns+" FROM revisions WHERE postId = ? AND number = ?", post.ID.Hex(), number)
+
+	revision, err := scanSQLiteRevision(row)
+
+	if err == sql.ErrNoRows {
+		return revision, ErrRevisionDoesNotExist
+	}
+
+	if err != nil {
+		return revision, err
+	}
+
+	return revision, nil
+}
+
+func (s *SQLiteStore) RestoreRevision(ctx context.Context, slug string, number int) (models.Post, error) {
+	return restoreRevision(ctx, s, slug, number)
+}
+
+func (s *SQLiteStore) Trash(ctx context.Context) ([]models.Post, error) {
+	return s.queryPosts(ctx, "SELECT "+sqlitePostColumns+" FROM posts WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC, slug")
+}
+
+func (s *SQLiteStore) Restore(ctx context.Context, slug string) (models.Post, error) {
+	row := s.db.QueryRowContext(ctx,
+		"UPDATE posts SET deletedAt = NULL WHERE slug = ? AND deletedAt IS NOT NULL RETURNING "+sqlitePostColumns,
+		slug,
+	)
+
+	restoredPost, err := scanSQLitePost(row)
+
+	if err == sql.ErrNoRows {
+		return restoredPost, ErrDoesNotExist
+	}
+
+	return restoredPost, err
+}
+
+func (s *SQLiteStore) Purge(ctx context.Context, slug string) (primitive.ObjectID, error) {
+	var id string
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "DELETE FROM posts WHERE slug = ? AND deletedAt IS NOT NULL RETURNING id", slug).Scan(&id)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM revisions WHERE postId = ?", id)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM aliases WHERE postId = ?", id)
+
+		return err
+	})
+
+	if err == sql.ErrNoRows {
+		return primitive.NilObjectID, ErrDoesNotExist
+	}
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return parseSQLiteObjectID(id)
+}
+
+func (s *SQLiteStore) PurgeDeletedBefore(ctx context.Context, t time.Time) ([]primitive.ObjectID, error) {
+	var purged []primitive.ObjectID
+
+	before := formatSQLiteTime(t)
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM revisions WHERE postId IN (SELECT id FROM posts WHERE deletedAt < ?)", before)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM aliases WHERE postId IN (SELECT id FROM posts WHERE deletedAt < ?)", before)
+
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "DELETE FROM posts WHERE deletedAt < ? RETURNING id", before)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+
+			err = rows.Scan(&id)
+
+			if err != nil {
+				return err
+			}
+
+			purgedID, err := parseSQLiteObjectID(id)
+
+			if err != nil {
+				return err
+			}
+
+			purged = append(purged, purgedID)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return purged, nil
+}
+
+func (s *SQLiteStore) Disconnect(_ context.Context) error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Clean(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM posts; DELETE FROM revisions; DELETE FROM aliases")
+
+	return err
+}
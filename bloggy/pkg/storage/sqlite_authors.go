@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+// sqliteAuthorMigrations are the migrations of author databases, which are
+// kept apart from post databases like sqliteMigrations
+var sqliteAuthorMigrations = []string{
+	`CREATE TABLE authors (
+		id        TEXT PRIMARY KEY,
+		name      TEXT NOT NULL,
+		email     TEXT NOT NULL,
+		bio       TEXT NOT NULL,
+		createdAt TEXT NOT NULL,
+		updatedAt TEXT NOT NULL
+	);
+	CREATE INDEX authors_name ON authors (name, id)`,
+}
+
+// sqliteAuthorColumns are the columns scanSQLiteAuthor reads, in order
+const sqliteAuthorColumns = "id, name, email, bio, createdAt, updatedAt"
+
+type SQLiteAuthorStore struct {
+	db *sql.DB
+}
+
+func CreateSQLiteAuthorStore(ctx context.Context, path string) (AuthorStorage, error) {
+	// Open database file, it is created if it does not exist
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	// Create or update tables
+	err = migrateSQLite(ctx, db, sqliteAuthorMigrations)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteAuthorStore{db}, nil
+}
+
+func scanSQLiteAuthor(row sqliteScanner) (models.Author, error) {
+	var author models.Author
+	var id, createdAt, updatedAt string
+
+	err := row.Scan(&id, &author.Name, &author.Email, &author.Bio, &createdAt, &updatedAt)
+
+	if err != nil {
+		return author, err
+	}
+
+	author.CreatedAt = parseSQLiteTime(createdAt)
+	author.UpdatedAt = parseSQLiteTime(updatedAt)
+
+	author.ID, err = primitive.ObjectIDFromHex(id)
+
+	return author, err
+}
+
+func (s *SQLiteAuthorStore) Insert(ctx context.Context, author models.Author) (models.Author, error) {
+	author, err := normalizeAuthor(author)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	author = newAuthor(author)
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO authors ("+sqliteAuthorColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		author.ID.Hex(), author.Name, author.Email, author.Bio,
+		formatSQLiteTime(author.CreatedAt), formatSQLiteTime(author.UpdatedAt),
+	)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return author, nil
+}
+
+func (s *SQLiteAuthorStore) Find(ctx context.Context, id primitive.ObjectID) (models.Author, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+sqliteAuthorColumns+" FROM authors WHERE id = ?", id.Hex())
+
+	foundAuthor, err := scanSQLiteAuthor(row)
+
+	if err == sql.ErrNoRows {
+		return foundAuthor, ErrAuthorDoesNotExist
+	}
+
+	return foundAuthor, err
+}
+
+func (s *SQLiteAuthorStore) Modify(ctx context.Context, id primitive.ObjectID, author models.Author) (models.Author, error) {
+	author, err := normalizeAuthor(author)
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	// Only set client editable columns, server managed columns are kept
+	row := s.db.QueryRowContext(ctx,
+		"UPDATE authors SET name = ?, email = ?, bio = ?, updatedAt = ? WHERE id = ? RETURNING "+sqliteAuthorColumns,
+		author.Name, author.Email, author.Bio, formatSQLiteTime(now()), id.Hex(),
+	)
+
+	modifiedAuthor, err := scanSQLiteAuthor(row)
+
+	if err == sql.ErrNoRows {
+		return models.Author{}, ErrAuthorDoesNotExist
+	}
+
+	if err != nil {
+		return models.Author{}, err
+	}
+
+	return modifiedAuthor, nil
+}
+
+func (s *SQLiteAuthorStore) Remove(ctx context.Context, id primitive.ObjectID) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM authors WHERE id = ?", id.Hex())
+
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return ErrAuthorDoesNotExist
+	}
+
+	return nil
+}
+
+func (s *SQLiteAuthorStore) All(ctx context.Context) ([]models.Author, error) {
+	allAuthors := []models.Author{}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+sqliteAuthorColumns+" FROM authors ORDER BY name, id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		author, err := scanSQLiteAuthor(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		allAuthors = append(allAuthors, author)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return allAuthors, nil
+}
+
+func (s *SQLiteAuthorStore) Disconnect(_ context.Context) error {
+	return s.db.Close()
+}
+
+func (s *SQLiteAuthorStore) Clean(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM authors")
+
+	return err
+}
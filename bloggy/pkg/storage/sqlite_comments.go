@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+// sqliteCommentMigrations are the migrations of comment databases, which are
+// kept apart from post databases like sqliteMigrations
+var sqliteCommentMigrations = []string{
+	`CREATE TABLE comments (
+		id        TEXT PRIMARY KEY,
+		postId    TEXT NOT NULL,
+		parentId  TEXT NOT NULL,
+		name      TEXT NOT NULL,
+		content   TEXT NOT NULL,
+		status    TEXT NOT NULL,
+		createdAt TEXT NOT NULL,
+		updatedAt TEXT NOT NULL
+	);
+	CREATE INDEX comments_postId ON comments (postId, createdAt, id);
+	CREATE INDEX comments_status ON comments (status, createdAt, id);
+	CREATE INDEX comments_parentId ON comments (parentId)`,
+}
+
+// sqliteCommentColumns are the columns scanSQLiteComment reads, in order
+const sqliteCommentColumns = "id, postId, parentId, name, content, status, createdAt, updatedAt"
+
+type SQLiteCommentStore struct {
+	db *sql.DB
+}
+
+func CreateSQLiteCommentStore(ctx context.Context, path string) (CommentStorage, error) {
+	// Open database file, it is created if it does not exist
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	// Create or update tables
+	err = migrateSQLite(ctx, db, sqliteCommentMigrations)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteCommentStore{db}, nil
+}
+
+func scanSQLiteComment(row sqliteScanner) (models.Comment, error) {
+	var comment models.Comment
+	var id, postID, parentID, status, createdAt, updatedAt string
+
+	err := row.Scan(&id, &postID, &parentID, &comment.Name, &comment.Content, &status, &createdAt, &updatedAt)
+
+	if err != nil {
+		return comment, err
+	}
+
+	comment.Status = models.CommentStatus(status)
+	comment.CreatedAt = parseSQLiteTime(createdAt)
+	comment.UpdatedAt = parseSQLiteTime(updatedAt)
+
+	comment.ID, err = primitive.ObjectIDFromHex(id)
+
+	if err != nil {
+		return comment, err
+	}
+
+	comment.PostID, err = primitive.ObjectIDFromHex(postID)
+
+	if err != nil {
+		return comment, err
+	}
+
+	comment.ParentID, err = parseSQLiteObjectID(parentID)
+
+	return comment, err
+}
+
+func (s *SQLiteCommentStore) Insert(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	comment, err := normalizeComment(comment)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if !comment.ParentID.IsZero() {
+		parent, err := s.Find(ctx, comment.ParentID)
+
+		if err == ErrCommentDoesNotExist {
+			return models.Comment{}, ErrInvalidParent
+		}
+
+		if err != nil {
+			return models.Comment{}, err
+		}
+
+		err = checkParent(comment, parent)
+
+		if err != nil {
+			return models.Comment{}, err
+		}
+	}
+
+	comment = newComment(comment)
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO comments ("+sqliteCommentColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		comment.ID.Hex(), comment.PostID.Hex(), formatSQLiteObjectID(comment.ParentID),
+		comment.Name, comment.Content, string(comment.Status),
+		formatSQLiteTime(comment.CreatedAt), formatSQLiteTime(comment.UpdatedAt),
+	)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return comment, nil
+}
+
+func (s *SQLiteCommentStore) Find(ctx context.Context, id primitive.ObjectID) (models.Comment, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+sqliteCommentColumns+" FROM comments WHERE id = ?", id.Hex())
+
+	foundComment, err := scanSQLiteComment(row)
+
+	if err == sql.ErrNoRows {
+		return foundComment, ErrCommentDoesNotExist
+	}
+
+	return foundComment, err
+}
+
+func (s *SQLiteCommentStore) List(ctx context.Context, postID primitive.ObjectID, status models.CommentStatus) ([]models.Comment, error) {
+	comments := []models.Comment{}
+
+	err := checkCommentStatus(status, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Empty parameters match every post and status
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sqliteCommentColumns+" FROM comments WHERE (? = '' OR postId = ?) AND (? = '' OR status = ?) ORDER BY createdAt, id",
+		formatSQLiteObjectID(postID), formatSQLiteObjectID(postID), string(status), string(status),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanSQLiteComment(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (s *SQLiteCommentStore) Moderate(ctx context.Context, id primitive.ObjectID, status models.CommentStatus) (models.Comment, error) {
+	err := checkCommentStatus(status, false)
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	row := s.db.QueryRowContext(ctx,
+		"UPDATE comments SET status = ?, updatedAt = ? WHERE id = ? RETURNING "+sqliteCommentColumns,
+		string(status), formatSQLiteTime(now()), id.Hex(),
+	)
+
+	moderatedComment, err := scanSQLiteComment(row)
+
+	if err == sql.ErrNoRows {
+		return models.Comment{}, ErrCommentDoesNotExist
+	}
+
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return moderatedComment, nil
+}
+
+func (s *SQLiteCommentStore) Remove(ctx context.Context, id primitive.ObjectID) error {
+	// The thread is the comment and the replies to it, down to the last reply
+	result, err := s.db.ExecContext(ctx,
+		`WITH RECURSIVE thread(id) AS (
+			SELECT id FROM comments WHERE id = ?
+			UNION SELECT comments.id FROM comments JOIN thread ON comments.parentId = thread.id
+		)
+		DELETE FROM comments WHERE id IN thread`,
+		id.Hex(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return ErrCommentDoesNotExist
+	}
+
+	return nil
+}
+
+func (s *SQLiteCommentStore) RemovePost(ctx context.Context, postID primitive.ObjectID) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM comments WHERE postId = ?", postID.Hex())
+
+	return err
+}
+
+func (s *SQLiteCommentStore) Disconnect(_ context.Context) error {
+	return s.db.Close()
+}
+
+func (s *SQLiteCommentStore) Clean(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM comments")
+
+	return err
+}
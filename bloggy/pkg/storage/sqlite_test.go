@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"examples/bloggy/pkg/models"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteSlugBackfill(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("sqlite", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a database as it was before slugs, titles were unique then
+	const slugMigration = 6
+
+	for i, migration := range sqliteMigrations[:slugMigration] {
+		_, err = db.ExecContext(ctx, migration)
+		assertNil(t, err)
+
+		_, err = db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1))
+		assertNil(t, err)
+	}
+
+	for i, title := range []string{"Hello, World!", "Hello world", "Crème brûlée"} {
+		_, err = db.ExecContext(ctx,
+			"INSERT INTO posts (id, name, title, content) VALUES (?, 'Vishnu', ?, 'Hello world')",
+			fmt.Sprintf("%024x", i+1), title,
+		)
+		assertNil(t, err)
+	}
+
+	assertNil(t, db.Close())
+
+	store, err := CreateSQLiteStore(ctx, path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Disconnect(ctx)
+
+	posts, err := store.All(ctx)
+	assertNil(t, err)
+
+	expectedSlugs := []string{"hello-world", "hello-world-2", "creme-brulee"}
+
+	if len(posts) != len(expectedSlugs) {
+		t.Fatalf("Expected item count to be %d but got %d", len(expectedSlugs), len(posts))
+	}
+
+	for i, post := range posts {
+		if post.Slug != expectedSlugs[i] {
+			t.Errorf("Expected slug of %q to be %s but got %s", post.Title, expectedSlugs[i], post.Slug)
+		}
+
+		// Posts were public before there was a workflow
+		if post.Status != models.StatusPublished {
+			t.Errorf("Expected %q to be published but got %s", post.Title, post.Status)
+		}
+
+		if post.PublishedAt == nil || !post.PublishedAt.Equal(post.CreatedAt) {
+			t.Errorf("Expected %q to be published when created at %s but got %v", post.Title, post.CreatedAt, post.PublishedAt)
+		}
+	}
+
+	// Titles no longer need to be unique
+	post := posts[0]
+	post.Slug = ""
+
+	insertedPost, err := store.Insert(ctx, post)
+	assertNil(t, err)
+
+	if insertedPost.Slug != "hello-world-3" {
+		t.Errorf("Expected slug to be hello-world-3 but got %s", insertedPost.Slug)
+	}
+}
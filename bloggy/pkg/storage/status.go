@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+
+	"examples/bloggy/pkg/models"
+)
+
+var ErrInvalidStatus = errors.New("storage: invalid status")
+var ErrInvalidTransition = errors.New("storage: invalid status transition")
+
+// checkStatus returns ErrInvalidStatus if status is not a workflow status
+func checkStatus(status models.Status) error {
+	if !status.Valid() {
+		return ErrInvalidStatus
+	}
+
+	return nil
+}
+
+// transitionedPost moves post to status, stamping its publication time when
+// it is published and cancelling its schedule. Returns ErrVersionConflict
+// if post is not at the expected version and ErrInvalidTransition if the
+// workflow does not allow the move
+func transitionedPost(post models.Post, version int64, status models.Status) (models.Post, error) {
+	err := checkVersion(post, version)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if !post.Status.CanTransitionTo(status) {
+		return models.Post{}, ErrInvalidTransition
+	}
+
+	post.Status = status
+	post.PublishAt = nil
+
+	if status == models.StatusPublished {
+		t := now()
+		post.PublishedAt = &t
+	}
+
+	return post, nil
+}
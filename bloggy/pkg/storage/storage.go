@@ -4,17 +4,246 @@ import (
 	"context"
 	"errors"
 	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/slug"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var ErrDoesNotExist = errors.New("storage: post does not exists")
 var ErrAlreadyExists = errors.New("storage: post already exists")
+var ErrRevisionDoesNotExist = errors.New("storage: revision does not exist")
+var ErrVersionConflict = errors.New("storage: post version conflict")
+var ErrInvalidSlug = errors.New("storage: invalid slug")
+
+// AnyVersion can be passed to Modify and Remove instead of the expected
+// version of the post to skip the version check
+const AnyVersion int64 = 0
 
+// Posts are looked up by slug, which is unique. Insert generates it from the
+// title unless the post has one, adding a -2, -3, ... suffix if it is taken.
+// Modify keeps the slug unless the post has one, so links survive title
+// changes. Slugs given by clients must be valid, see slug.Valid, and free.
+//
+// The old slugs of a post are kept as aliases so that links to them keep
+// working, Find returns the post an alias belongs to. Aliases are taken like
+// slugs, only the post they belong to can take them back. Every other method
+// only accepts the current slug.
+//
+// Insert and Modify return the post as stored, with the server managed ID,
+// slug, timestamps and version filled in. Both store a new revision of the post,
+// numbered by its version, which can later be restored as the current one.
+// Modify and Remove return ErrVersionConflict if the stored post is not at
+// the expected version, unless it is AnyVersion.
+//
+// Search returns the posts whose title or content match any word of query,
+// most relevant first. Words are matched by their stem, so "running" finds
+// "runs", and matches in the title weigh more than in the content.
+//
+// Posts link to the profile of their author by AuthorID, which is stored
+// as given. Checking that the author exists is left to callers, as authors
+// are kept in an AuthorStorage of their own.
+//
+// Tags and categories must be in slug form once lowercased, Insert and
+// Modify return ErrInvalidTag otherwise. Tags and Categories count the posts
+// outside the trash that have each tag or category, most used first.
+// RenameTags replaces the tags in from with to on every post, merging them
+// where a post has several, and returns the number of posts changed. It is
+// bookkeeping, the posts get no new version or revision. Revisions do not
+// hold tags and categories, restoring one keeps those of the post.
+//
+// Posts are inserted as drafts and only Transition changes their status,
+// following the workflow of models.Status. It returns ErrInvalidTransition
+// if the post can not move to the status and, like Modify, ErrVersionConflict
+// if it is not at the expected version. A transition is bookkeeping like
+// RenameTags, the post keeps its version. List and Search return posts of
+// any status unless their options select one.
+//
+// Posts in review can be scheduled to be published at a later time,
+// scheduling again replaces the time and nil cancels it. Schedule returns
+// ErrInvalidTransition if the post is not in review and ErrNotScheduled if
+// there is nothing to cancel. Any transition cancels the schedule. Scheduled
+// returns the posts outside the trash that are scheduled, soonest first, and
+// PublishDue publishes those due at t, which are returned with their
+// scheduled time as publication time.
+//
+// Remove moves a post to the trash, where it is hidden from every other
+// method until it is restored or purged. A trashed post keeps its slug and
+// aliases, so no other post can take them meanwhile. Purge and
+// PurgeDeletedBefore return the IDs of the posts they deleted, so that what
+// is kept apart from posts, such as comments, can be deleted along
 type Storage interface {
-	Insert(ctx context.Context, post models.Post) error
-	Find(ctx context.Context, title string) (models.Post, error)
-	Remove(ctx context.Context, title string) error
-	Modify(ctx context.Context, title string, post models.Post) error
+	Insert(ctx context.Context, post models.Post) (models.Post, error)
+	Find(ctx context.Context, slug string) (models.Post, error)
+	Remove(ctx context.Context, slug string, version int64) error
+	Modify(ctx context.Context, slug string, version int64, post models.Post) (models.Post, error)
 	All(ctx context.Context) ([]models.Post, error)
+	List(ctx context.Context, opts ListOptions) (Page, error)
+	Search(ctx context.Context, query string, opts SearchOptions) (SearchPage, error)
+	Tags(ctx context.Context) ([]Facet, error)
+	Categories(ctx context.Context) ([]Facet, error)
+	RenameTags(ctx context.Context, from []string, to string) (int, error)
+	Transition(ctx context.Context, slug string, version int64, status models.Status) (models.Post, error)
+	Schedule(ctx context.Context, slug string, version int64, at *time.Time) (models.Post, error)
+	Scheduled(ctx context.Context) ([]models.Post, error)
+	PublishDue(ctx context.Context, t time.Time) ([]models.Post, error)
+	Revisions(ctx context.Context, slug string) ([]models.Revision, error)
+	Revision(ctx context.Context, slug string, number int) (models.Revision, error)
+	RestoreRevision(ctx context.Context, slug string, number int) (models.Post, error)
+	Trash(ctx context.Context) ([]models.Post, error)
+	Restore(ctx context.Context, slug string) (models.Post, error)
+	Purge(ctx context.Context, slug string) (primitive.ObjectID, error)
+	PurgeDeletedBefore(ctx context.Context, t time.Time) ([]primitive.ObjectID, error)
 	Disconnect(ctx context.Context) error
 	Clean(ctx context.Context) error
 }
+
+// timeLayout formats timestamps so that they sort as strings, it is used
+// for cursors and SQLite columns
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+// now returns the current time at the millisecond precision every backend
+// is able to store
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// newPost fills in the server managed fields of a post about to be inserted
+func newPost(post models.Post) models.Post {
+	t := now()
+
+	post.ID = primitive.NewObjectID()
+	post.CreatedAt = t
+	post.UpdatedAt = t
+	post.PublishedAt = nil
+	post.PublishAt = nil
+	post.Status = models.StatusDraft
+	post.Version = 1
+
+	return post
+}
+
+// modifiedPost applies the client editable fields of post to existing. The
+// author is kept unless a new one is given
+func modifiedPost(existing models.Post, post models.Post) models.Post {
+	if !post.AuthorID.IsZero() {
+		existing.AuthorID = post.AuthorID
+	}
+
+	existing.Name = post.Name
+	existing.Title = post.Title
+	existing.Content = post.Content
+	existing.Tags = post.Tags
+	existing.Category = post.Category
+
+	if post.Slug != "" {
+		existing.Slug = post.Slug
+	}
+
+	existing.UpdatedAt = now()
+	existing.Version++
+
+	return existing
+}
+
+// checkSlug returns ErrInvalidSlug if post has a slug that is not valid
+func checkSlug(post models.Post) error {
+	if post.Slug != "" && !slug.Valid(post.Slug) {
+		return ErrInvalidSlug
+	}
+
+	return nil
+}
+
+// uniqueSlug generates the slug of title, adding a suffix while taken reports
+// the slug as taken
+func uniqueSlug(title string, taken func(s string) (bool, error)) (string, error) {
+	base := slug.Make(title)
+
+	for n := 1; ; n++ {
+		s := slug.WithSuffix(base, n)
+
+		exists, err := taken(s)
+
+		if err != nil || !exists {
+			return s, err
+		}
+	}
+}
+
+// insertWithUniqueSlug calls insert with post, generating its slug from the
+// title if it has none. insert returns ErrAlreadyExists if the slug is
+// taken, generated slugs are then retried with the next suffix
+func insertWithUniqueSlug(post models.Post, insert func(post models.Post) error) (models.Post, error) {
+	err := checkSlug(post)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if post.Slug != "" {
+		return post, insert(post)
+	}
+
+	base := slug.Make(post.Title)
+
+	for n := 1; ; n++ {
+		post.Slug = slug.WithSuffix(base, n)
+
+		err = insert(post)
+
+		if err != ErrAlreadyExists {
+			return post, err
+		}
+	}
+}
+
+// checkVersion returns ErrVersionConflict if post is not at the expected
+// version
+func checkVersion(post models.Post, version int64) error {
+	if version != AnyVersion && post.Version != version {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// revisionOf returns the revision of post as stored, numbered by its version
+func revisionOf(post models.Post) models.Revision {
+	return models.Revision{
+		PostID:    post.ID,
+		Number:    int(post.Version),
+		Author:    post.Name,
+		Title:     post.Title,
+		Content:   post.Content,
+		CreatedAt: post.UpdatedAt,
+	}
+}
+
+// restoreRevision modifies the post to be as it was in a revision, which
+// stores it as a new revision. Posts modified since they were read are not
+// overwritten, ErrVersionConflict is returned instead
+func restoreRevision(ctx context.Context, s Storage, key string, number int) (models.Post, error) {
+	revision, err := s.Revision(ctx, key, number)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	current, err := s.Find(ctx, key)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post := models.Post{
+		AuthorID: current.AuthorID,
+		Name:     revision.Author,
+		Title:    revision.Title,
+		Content:  revision.Content,
+		Tags:     current.Tags,
+		Category: current.Category,
+	}
+
+	return s.Modify(ctx, key, current.Version, post)
+}
@@ -1,22 +1,23 @@
-package storage
+package storage_test
 
 import (
 	"context"
-	"examples/bloggy/pkg/models"
+	"path/filepath"
 	"testing"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"examples/bloggy/pkg/storage"
+	"examples/bloggy/pkg/storage/storagetest"
 )
 
-func getMongoStore() Storage {
+func getMongoStore(t *testing.T) storage.Storage {
 	// Create Mongo store
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	store, err := CreateMongoStore(ctx, "test", "test")
+	store, err := storage.CreateMongoStore(ctx, "test", "test")
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
 
 	store.Clean(ctx)
@@ -24,306 +25,168 @@ func getMongoStore() Storage {
 	return store
 }
 
-func assertPostsSlice(t *testing.T, store Storage, expectedPosts []models.Post) {
-	posts, err := store.All(context.Background())
-
+func getSQLiteStore(t *testing.T) storage.Storage {
+	store, err := storage.CreateSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "test.db"))
 	if err != nil {
-		t.Error(err)
-		return
-	}
-
-	if len(posts) != len(expectedPosts) {
-		t.Errorf("Expected item count to be %d but got %d", len(expectedPosts), len(posts))
-		return
+		t.Fatal(err)
 	}
 
-	for i := range expectedPosts {
-		if !expectedPosts[i].IsEqual(posts[i]) {
-			t.Errorf("Expected index %d post to be %s but got %s", i, posts[i], expectedPosts[i])
-		}
-	}
+	return store
 }
 
-func assertNil(t *testing.T, err error) {
+func getDurableMemoryStore(t *testing.T) storage.Storage {
+	store, err := storage.CreateDurableMemoryStore(t.TempDir(), 2)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-}
 
-func assertError(t *testing.T, err error, expected error) {
-	if err != expected {
-		t.Errorf("Expected error to be %s but got %s", expected.Error(), err.Error())
-	}
-}
-
-func TestCount(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
-
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
-		testPostOne := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
-
-		testPostTwo := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "World",
-			Name:    "Shankar",
-			Content: "This is golang!!",
-		}
-
-		testPostThree := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Golang",
-			Name:    "Bob",
-			Content: "Golang is awesome!!",
-		}
-
-		store.Insert(context.Background(), testPostOne)
-		store.Insert(context.Background(), testPostTwo)
-		store.Insert(context.Background(), testPostThree)
-
-		expectedPosts := []models.Post{testPostOne, testPostTwo, testPostThree}
-
-		assertPostsSlice(t, store, expectedPosts)
-	}
+	return store
 }
 
-func TestCreate(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
-
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
-
-		testPost := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
-
-		err := store.Insert(context.Background(), testPost)
-
-		assertNil(t, err)
-
-		expectedPosts := []models.Post{testPost}
+func getMongoAuthorStore(t *testing.T) storage.AuthorStorage {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-		assertPostsSlice(t, store, expectedPosts)
+	store, err := storage.CreateMongoAuthorStore(ctx, "test", "test_authors")
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestCreateAlreadyExists(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
-
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
-		testPost := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
-
-		err := store.Insert(context.Background(), testPost)
-
-		assertNil(t, err)
-
-		err = store.Insert(context.Background(), testPost)
-
-		assertError(t, err, ErrAlreadyExists)
-
-		expectedPosts := []models.Post{testPost}
+	store.Clean(ctx)
 
-		assertPostsSlice(t, store, expectedPosts)
-	}
+	return store
 }
 
-func TestFind(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
-
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
-		testPostOne := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
-
-		testPostTwo := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "World",
-			Name:    "Shankar",
-			Content: "This is golang!!",
-		}
-
-		testPostThree := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Golang",
-			Name:    "Bob",
-			Content: "Golang is awesome!!",
-		}
-
-		store.Insert(context.Background(), testPostOne)
-		store.Insert(context.Background(), testPostTwo)
-		store.Insert(context.Background(), testPostThree)
-
-		post, err := store.Find(context.Background(), "World")
-
-		assertNil(t, err)
-
-		if !post.IsEqual(testPostTwo) {
-			t.Errorf("Expected %s but got %s", testPostTwo, post)
-		}
+func getSQLiteAuthorStore(t *testing.T) storage.AuthorStorage {
+	store, err := storage.CreateSQLiteAuthorStore(context.Background(), filepath.Join(t.TempDir(), "authors.db"))
+	if err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFindDoesNotExist(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
-
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
 
-		_, err := store.Find(context.Background(), "Hello")
-
-		assertError(t, err, ErrDoesNotExist)
-	}
+	return store
 }
 
-func TestModify(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
-
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
+func getDurableMemoryAuthorStore(t *testing.T) storage.AuthorStorage {
+	store, err := storage.CreateDurableMemoryAuthorStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		testPost := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
+	return store
+}
 
-		testPostModify := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Golang is awesome!!",
-		}
+func getMongoCommentStore(t *testing.T) storage.CommentStorage {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-		err := store.Insert(context.Background(), testPost)
+	store, err := storage.CreateMongoCommentStore(ctx, "test", "test_comments")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		assertNil(t, err)
+	store.Clean(ctx)
 
-		err = store.Modify(context.Background(), "Hello", testPostModify)
+	return store
+}
 
-		assertNil(t, err)
+func getSQLiteCommentStore(t *testing.T) storage.CommentStorage {
+	store, err := storage.CreateSQLiteCommentStore(context.Background(), filepath.Join(t.TempDir(), "comments.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		expectedPosts := []models.Post{testPostModify}
+	return store
+}
 
-		assertPostsSlice(t, store, expectedPosts)
+func getDurableMemoryCommentStore(t *testing.T) storage.CommentStorage {
+	store, err := storage.CreateDurableMemoryCommentStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestModifyDoesNotExist(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
+	return store
+}
 
-	memStore := CreateMemoryStore()
+func TestMongoStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-	for _, store := range []Storage{mongoStore, memStore} {
-		testPost := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
+	store, err := storage.CreateMongoStore(ctx, "test", "test")
+	if err != nil {
+		storage.MongoUnavailable(t, err)
+	}
 
-		testPostModify := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Golang is awesome!!",
-		}
+	store.Disconnect(context.Background())
 
-		err := store.Insert(context.Background(), testPost)
+	storagetest.RunConformance(t, getMongoStore)
+}
 
-		assertNil(t, err)
+func TestMemoryStore(t *testing.T) {
+	storagetest.RunConformance(t, func(t *testing.T) storage.Storage {
+		return storage.CreateMemoryStore()
+	})
+}
 
-		err = store.Modify(context.Background(), "DoesNotExist", testPostModify)
+func TestDurableMemoryStore(t *testing.T) {
+	storagetest.RunConformance(t, getDurableMemoryStore)
+}
 
-		assertError(t, err, ErrDoesNotExist)
+func TestSQLiteStore(t *testing.T) {
+	storagetest.RunConformance(t, getSQLiteStore)
+}
 
-		expectedPosts := []models.Post{testPost}
+func TestMongoAuthorStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-		assertPostsSlice(t, store, expectedPosts)
+	store, err := storage.CreateMongoAuthorStore(ctx, "test", "test_authors")
+	if err != nil {
+		storage.MongoUnavailable(t, err)
 	}
-}
 
-func TestRemove(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
+	store.Disconnect(context.Background())
 
-	memStore := CreateMemoryStore()
-
-	for _, store := range []Storage{mongoStore, memStore} {
-		testPostOne := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Hello",
-			Name:    "Vishnu",
-			Content: "Hello world",
-		}
+	storagetest.RunAuthorConformance(t, getMongoAuthorStore)
+}
 
-		testPostTwo := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "World",
-			Name:    "Shankar",
-			Content: "This is golang!!",
-		}
+func TestMemoryAuthorStore(t *testing.T) {
+	storagetest.RunAuthorConformance(t, func(t *testing.T) storage.AuthorStorage {
+		return storage.CreateMemoryAuthorStore()
+	})
+}
 
-		testPostThree := models.Post{
-			ID:      primitive.NilObjectID,
-			Title:   "Golang",
-			Name:    "Bob",
-			Content: "Golang is awesome!!",
-		}
+func TestDurableMemoryAuthorStore(t *testing.T) {
+	storagetest.RunAuthorConformance(t, getDurableMemoryAuthorStore)
+}
 
-		store.Insert(context.Background(), testPostOne)
-		store.Insert(context.Background(), testPostTwo)
-		store.Insert(context.Background(), testPostThree)
+func TestSQLiteAuthorStore(t *testing.T) {
+	storagetest.RunAuthorConformance(t, getSQLiteAuthorStore)
+}
 
-		err := store.Remove(context.Background(), "World")
+func TestMongoCommentStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-		assertNil(t, err)
+	store, err := storage.CreateMongoCommentStore(ctx, "test", "test_comments")
+	if err != nil {
+		storage.MongoUnavailable(t, err)
+	}
 
-		expectedPosts := []models.Post{testPostOne, testPostThree}
+	store.Disconnect(context.Background())
 
-		assertPostsSlice(t, store, expectedPosts)
-	}
+	storagetest.RunCommentConformance(t, getMongoCommentStore)
 }
 
-func TestRemoveDoesNotExist(t *testing.T) {
-	mongoStore := getMongoStore()
-	defer mongoStore.Disconnect(context.Background())
+func TestMemoryCommentStore(t *testing.T) {
+	storagetest.RunCommentConformance(t, func(t *testing.T) storage.CommentStorage {
+		return storage.CreateMemoryCommentStore()
+	})
+}
 
-	memStore := CreateMemoryStore()
-	for _, store := range []Storage{mongoStore, memStore} {
-		err := store.Remove(context.Background(), "Hello")
+func TestDurableMemoryCommentStore(t *testing.T) {
+	storagetest.RunCommentConformance(t, getDurableMemoryCommentStore)
+}
 
-		assertError(t, err, ErrDoesNotExist)
-	}
+func TestSQLiteCommentStore(t *testing.T) {
+	storagetest.RunCommentConformance(t, getSQLiteCommentStore)
 }
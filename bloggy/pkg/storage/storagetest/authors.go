@@ -0,0 +1,217 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// AuthorFactory returns a new, empty author store. The suite disconnects it
+// when done
+type AuthorFactory func(t *testing.T) storage.AuthorStorage
+
+// RunAuthorConformance runs every author conformance test against stores
+// created by factory, each test as a subtest with its own store
+func RunAuthorConformance(t *testing.T, factory AuthorFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.AuthorStorage)
+	}{
+		{"Create", testCreateAuthor},
+		{"Invalid", testInvalidAuthor},
+		{"Modify", testModifyAuthor},
+		{"Remove", testRemoveAuthor},
+		{"DoesNotExist", testAuthorDoesNotExist},
+		{"All", testAllAuthors},
+		{"Clean", testCleanAuthors},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			store := factory(t)
+			defer store.Disconnect(context.Background())
+
+			tc.test(t, store)
+		})
+	}
+}
+
+func testAuthor() models.Author {
+	return models.Author{Name: "Vishnu", Email: "vishnu@example.com", Bio: "Writes about Go"}
+}
+
+func assertAuthor(t *testing.T, author models.Author, expected models.Author) {
+	t.Helper()
+
+	if author.Name != expected.Name || author.Email != expected.Email || author.Bio != expected.Bio {
+		t.Errorf("Expected %v but got %v", expected, author)
+	}
+}
+
+func insertAuthors(t *testing.T, store storage.AuthorStorage, names ...string) []models.Author {
+	t.Helper()
+
+	var authors []models.Author
+
+	for _, name := range names {
+		author, err := store.Insert(context.Background(), models.Author{Name: name})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authors = append(authors, author)
+	}
+
+	return authors
+}
+
+func assertAuthorNames(t *testing.T, store storage.AuthorStorage, names ...string) {
+	t.Helper()
+
+	authors, err := store.All(context.Background())
+	assertNil(t, err)
+
+	var authorNames []string
+
+	for _, author := range authors {
+		authorNames = append(authorNames, author.Name)
+	}
+
+	if len(authorNames) != len(names) {
+		t.Errorf("Expected authors to be %v but got %v", names, authorNames)
+		return
+	}
+
+	for i := range names {
+		if authorNames[i] != names[i] {
+			t.Errorf("Expected authors to be %v but got %v", names, authorNames)
+			return
+		}
+	}
+}
+
+func testCreateAuthor(t *testing.T, store storage.AuthorStorage) {
+	ctx := context.Background()
+	author := testAuthor()
+
+	// Server managed fields sent by clients are ignored
+	author.ID = primitive.NewObjectID()
+
+	insertedAuthor, err := store.Insert(ctx, author)
+	assertNil(t, err)
+	assertAuthor(t, insertedAuthor, author)
+
+	if insertedAuthor.ID.IsZero() || insertedAuthor.ID == author.ID {
+		t.Errorf("Expected a new ID but got %s", insertedAuthor.ID.Hex())
+	}
+
+	if insertedAuthor.CreatedAt.IsZero() || !insertedAuthor.UpdatedAt.Equal(insertedAuthor.CreatedAt) {
+		t.Errorf("Expected creation timestamps but got %v", insertedAuthor)
+	}
+
+	foundAuthor, err := store.Find(ctx, insertedAuthor.ID)
+	assertNil(t, err)
+	assertAuthor(t, foundAuthor, author)
+
+	if foundAuthor.ID != insertedAuthor.ID || !foundAuthor.CreatedAt.Equal(insertedAuthor.CreatedAt) {
+		t.Errorf("Expected %v but got %v", insertedAuthor, foundAuthor)
+	}
+}
+
+func testInvalidAuthor(t *testing.T, store storage.AuthorStorage) {
+	ctx := context.Background()
+
+	_, err := store.Insert(ctx, models.Author{Name: "  ", Email: "vishnu@example.com"})
+	assertError(t, err, storage.ErrInvalidAuthor)
+
+	author := insertAuthors(t, store, "Vishnu")[0]
+
+	_, err = store.Modify(ctx, author.ID, models.Author{})
+	assertError(t, err, storage.ErrInvalidAuthor)
+
+	// Names are trimmed
+	modifiedAuthor, err := store.Modify(ctx, author.ID, models.Author{Name: " Shankar "})
+	assertNil(t, err)
+	assertAuthor(t, modifiedAuthor, models.Author{Name: "Shankar"})
+
+	assertAuthorNames(t, store, "Shankar")
+}
+
+func testModifyAuthor(t *testing.T, store storage.AuthorStorage) {
+	ctx := context.Background()
+
+	insertedAuthor, err := store.Insert(ctx, testAuthor())
+	assertNil(t, err)
+
+	time.Sleep(2 * time.Millisecond)
+
+	author := models.Author{Name: "Vishnu P", Bio: "Writes about Rust"}
+
+	modifiedAuthor, err := store.Modify(ctx, insertedAuthor.ID, author)
+	assertNil(t, err)
+	assertAuthor(t, modifiedAuthor, author)
+
+	if modifiedAuthor.ID != insertedAuthor.ID || !modifiedAuthor.CreatedAt.Equal(insertedAuthor.CreatedAt) {
+		t.Errorf("Expected ID and creation time to be kept but got %v", modifiedAuthor)
+	}
+
+	if !modifiedAuthor.UpdatedAt.After(insertedAuthor.UpdatedAt) {
+		t.Errorf("Expected update time after %s but got %s", insertedAuthor.UpdatedAt, modifiedAuthor.UpdatedAt)
+	}
+
+	foundAuthor, err := store.Find(ctx, insertedAuthor.ID)
+	assertNil(t, err)
+	assertAuthor(t, foundAuthor, author)
+}
+
+func testRemoveAuthor(t *testing.T, store storage.AuthorStorage) {
+	ctx := context.Background()
+	authors := insertAuthors(t, store, "Vishnu", "Shankar")
+
+	err := store.Remove(ctx, authors[0].ID)
+	assertNil(t, err)
+
+	_, err = store.Find(ctx, authors[0].ID)
+	assertError(t, err, storage.ErrAuthorDoesNotExist)
+
+	assertAuthorNames(t, store, "Shankar")
+}
+
+func testAuthorDoesNotExist(t *testing.T, store storage.AuthorStorage) {
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+
+	_, err := store.Find(ctx, id)
+	assertError(t, err, storage.ErrAuthorDoesNotExist)
+
+	_, err = store.Modify(ctx, id, testAuthor())
+	assertError(t, err, storage.ErrAuthorDoesNotExist)
+
+	err = store.Remove(ctx, id)
+	assertError(t, err, storage.ErrAuthorDoesNotExist)
+}
+
+func testAllAuthors(t *testing.T, store storage.AuthorStorage) {
+	assertAuthorNames(t, store)
+
+	insertAuthors(t, store, "Vishnu", "Bob", "Shankar", "Bob")
+
+	assertAuthorNames(t, store, "Bob", "Bob", "Shankar", "Vishnu")
+}
+
+func testCleanAuthors(t *testing.T, store storage.AuthorStorage) {
+	insertAuthors(t, store, "Vishnu", "Shankar")
+
+	err := store.Clean(context.Background())
+	assertNil(t, err)
+
+	assertAuthorNames(t, store)
+}
@@ -0,0 +1,304 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// CommentFactory returns a new, empty comment store. The suite disconnects
+// it when done
+type CommentFactory func(t *testing.T) storage.CommentStorage
+
+// RunCommentConformance runs every comment conformance test against stores
+// created by factory, each test as a subtest with its own store
+func RunCommentConformance(t *testing.T, factory CommentFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.CommentStorage)
+	}{
+		{"Create", testCreateComment},
+		{"Invalid", testInvalidComment},
+		{"Replies", testReplies},
+		{"Moderate", testModerateComment},
+		{"List", testListComments},
+		{"Remove", testRemoveComment},
+		{"RemovePost", testRemovePostComments},
+		{"DoesNotExist", testCommentDoesNotExist},
+		{"Clean", testCleanComments},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			store := factory(t)
+			defer store.Disconnect(context.Background())
+
+			tc.test(t, store)
+		})
+	}
+}
+
+func testComment(postID primitive.ObjectID) models.Comment {
+	return models.Comment{PostID: postID, Name: "Vishnu", Content: "Nice post"}
+}
+
+func assertComment(t *testing.T, comment models.Comment, expected models.Comment) {
+	t.Helper()
+
+	if comment.PostID != expected.PostID || comment.ParentID != expected.ParentID || comment.Name != expected.Name || comment.Content != expected.Content {
+		t.Errorf("Expected %v but got %v", expected, comment)
+	}
+}
+
+// insertComment inserts a comment with content on the post with postID in
+// reply to parentID, approving it if approve is set
+func insertComment(t *testing.T, store storage.CommentStorage, postID primitive.ObjectID, parentID primitive.ObjectID, content string, approve bool) models.Comment {
+	t.Helper()
+
+	comment, err := store.Insert(context.Background(), models.Comment{PostID: postID, ParentID: parentID, Name: "Vishnu", Content: content})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if approve {
+		comment, err = store.Moderate(context.Background(), comment.ID, models.CommentApproved)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return comment
+}
+
+func assertComments(t *testing.T, store storage.CommentStorage, postID primitive.ObjectID, status models.CommentStatus, contents ...string) {
+	t.Helper()
+
+	comments, err := store.List(context.Background(), postID, status)
+	assertNil(t, err)
+
+	var commentContents []string
+
+	for _, comment := range comments {
+		commentContents = append(commentContents, comment.Content)
+	}
+
+	if len(commentContents) != len(contents) {
+		t.Errorf("Expected comments to be %v but got %v", contents, commentContents)
+		return
+	}
+
+	for i := range contents {
+		if commentContents[i] != contents[i] {
+			t.Errorf("Expected comments to be %v but got %v", contents, commentContents)
+			return
+		}
+	}
+}
+
+func testCreateComment(t *testing.T, store storage.CommentStorage) {
+	ctx := context.Background()
+	comment := testComment(primitive.NewObjectID())
+
+	// Server managed fields sent by clients are ignored
+	comment.ID = primitive.NewObjectID()
+	comment.Status = models.CommentApproved
+
+	insertedComment, err := store.Insert(ctx, comment)
+	assertNil(t, err)
+	assertComment(t, insertedComment, comment)
+
+	if insertedComment.ID.IsZero() || insertedComment.ID == comment.ID {
+		t.Errorf("Expected a new ID but got %s", insertedComment.ID.Hex())
+	}
+
+	if insertedComment.Status != models.CommentPending {
+		t.Errorf("Expected comment to be pending but got %s", insertedComment.Status)
+	}
+
+	if insertedComment.CreatedAt.IsZero() || !insertedComment.UpdatedAt.Equal(insertedComment.CreatedAt) {
+		t.Errorf("Expected creation timestamps but got %v", insertedComment)
+	}
+
+	foundComment, err := store.Find(ctx, insertedComment.ID)
+	assertNil(t, err)
+	assertComment(t, foundComment, comment)
+
+	if foundComment.ID != insertedComment.ID || foundComment.Status != models.CommentPending || !foundComment.CreatedAt.Equal(insertedComment.CreatedAt) {
+		t.Errorf("Expected %v but got %v", insertedComment, foundComment)
+	}
+}
+
+func testInvalidComment(t *testing.T, store storage.CommentStorage) {
+	ctx := context.Background()
+	postID := primitive.NewObjectID()
+
+	invalidComments := []models.Comment{
+		{Name: "Vishnu", Content: "Nice post"},
+		{PostID: postID, Name: " ", Content: "Nice post"},
+		{PostID: postID, Name: "Vishnu", Content: "\n"},
+	}
+
+	for _, comment := range invalidComments {
+		_, err := store.Insert(ctx, comment)
+		assertError(t, err, storage.ErrInvalidComment)
+	}
+
+	// Names and content are trimmed
+	comment, err := store.Insert(ctx, models.Comment{PostID: postID, Name: " Vishnu ", Content: " Nice post\n"})
+	assertNil(t, err)
+	assertComment(t, comment, testComment(postID))
+
+	_, err = store.List(ctx, postID, "spam")
+	assertError(t, err, storage.ErrInvalidStatus)
+
+	_, err = store.Moderate(ctx, comment.ID, "")
+	assertError(t, err, storage.ErrInvalidStatus)
+}
+
+func testReplies(t *testing.T, store storage.CommentStorage) {
+	ctx := context.Background()
+	postID := primitive.NewObjectID()
+
+	approved := insertComment(t, store, postID, primitive.NilObjectID, "First", true)
+	pending := insertComment(t, store, postID, primitive.NilObjectID, "Second", false)
+
+	reply := insertComment(t, store, postID, approved.ID, "Reply", true)
+	insertComment(t, store, postID, reply.ID, "Reply to reply", false)
+
+	if reply.ParentID != approved.ID {
+		t.Errorf("Expected reply to answer %s but got %s", approved.ID.Hex(), reply.ParentID.Hex())
+	}
+
+	// Replies answer approved comments on the same post
+	invalidReplies := []models.Comment{
+		{PostID: postID, ParentID: pending.ID, Name: "Shankar", Content: "Reply"},
+		{PostID: postID, ParentID: primitive.NewObjectID(), Name: "Shankar", Content: "Reply"},
+		{PostID: primitive.NewObjectID(), ParentID: approved.ID, Name: "Shankar", Content: "Reply"},
+	}
+
+	for _, comment := range invalidReplies {
+		_, err := store.Insert(ctx, comment)
+		assertError(t, err, storage.ErrInvalidParent)
+	}
+
+	assertComments(t, store, postID, "", "First", "Second", "Reply", "Reply to reply")
+}
+
+func testModerateComment(t *testing.T, store storage.CommentStorage) {
+	ctx := context.Background()
+	comment := insertComment(t, store, primitive.NewObjectID(), primitive.NilObjectID, "Nice post", false)
+
+	time.Sleep(2 * time.Millisecond)
+
+	for _, status := range []models.CommentStatus{models.CommentApproved, models.CommentRejected, models.CommentPending} {
+		moderatedComment, err := store.Moderate(ctx, comment.ID, status)
+		assertNil(t, err)
+		assertComment(t, moderatedComment, comment)
+
+		if moderatedComment.Status != status || !moderatedComment.UpdatedAt.After(comment.UpdatedAt) {
+			t.Errorf("Expected comment to be %s since after %s but got %v", status, comment.UpdatedAt, moderatedComment)
+		}
+
+		foundComment, err := store.Find(ctx, comment.ID)
+		assertNil(t, err)
+
+		if foundComment.Status != status {
+			t.Errorf("Expected comment to be %s but got %s", status, foundComment.Status)
+		}
+	}
+}
+
+func testListComments(t *testing.T, store storage.CommentStorage) {
+	postOne := primitive.NewObjectID()
+	postTwo := primitive.NewObjectID()
+
+	assertComments(t, store, postOne, "")
+
+	insertComment(t, store, postOne, primitive.NilObjectID, "One", true)
+	insertComment(t, store, postTwo, primitive.NilObjectID, "Two", false)
+	insertComment(t, store, postOne, primitive.NilObjectID, "Three", false)
+	insertComment(t, store, postTwo, primitive.NilObjectID, "Four", true)
+
+	assertComments(t, store, postOne, "", "One", "Three")
+	assertComments(t, store, postOne, models.CommentApproved, "One")
+	assertComments(t, store, postTwo, models.CommentPending, "Two")
+	assertComments(t, store, postTwo, models.CommentRejected)
+
+	// The moderation queue spans every post
+	assertComments(t, store, primitive.NilObjectID, models.CommentPending, "Two", "Three")
+	assertComments(t, store, primitive.NilObjectID, "", "One", "Two", "Three", "Four")
+}
+
+func testRemoveComment(t *testing.T, store storage.CommentStorage) {
+	ctx := context.Background()
+	postID := primitive.NewObjectID()
+
+	first := insertComment(t, store, postID, primitive.NilObjectID, "First", true)
+	second := insertComment(t, store, postID, primitive.NilObjectID, "Second", true)
+	reply := insertComment(t, store, postID, first.ID, "Reply", true)
+	insertComment(t, store, postID, reply.ID, "Reply to reply", true)
+	insertComment(t, store, postID, second.ID, "Other reply", true)
+
+	// Replies are removed with the comment they answer
+	err := store.Remove(ctx, first.ID)
+	assertNil(t, err)
+
+	assertComments(t, store, postID, "", "Second", "Other reply")
+
+	_, err = store.Find(ctx, reply.ID)
+	assertError(t, err, storage.ErrCommentDoesNotExist)
+}
+
+func testRemovePostComments(t *testing.T, store storage.CommentStorage) {
+	postOne := primitive.NewObjectID()
+	postTwo := primitive.NewObjectID()
+
+	comment := insertComment(t, store, postOne, primitive.NilObjectID, "One", true)
+	insertComment(t, store, postOne, comment.ID, "Reply", false)
+	insertComment(t, store, postTwo, primitive.NilObjectID, "Two", false)
+
+	err := store.RemovePost(context.Background(), postOne)
+	assertNil(t, err)
+
+	assertComments(t, store, postOne, "")
+	assertComments(t, store, primitive.NilObjectID, "", "Two")
+
+	// Posts without comments have nothing to remove
+	err = store.RemovePost(context.Background(), postOne)
+	assertNil(t, err)
+}
+
+func testCommentDoesNotExist(t *testing.T, store storage.CommentStorage) {
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+
+	_, err := store.Find(ctx, id)
+	assertError(t, err, storage.ErrCommentDoesNotExist)
+
+	_, err = store.Moderate(ctx, id, models.CommentApproved)
+	assertError(t, err, storage.ErrCommentDoesNotExist)
+
+	err = store.Remove(ctx, id)
+	assertError(t, err, storage.ErrCommentDoesNotExist)
+}
+
+func testCleanComments(t *testing.T, store storage.CommentStorage) {
+	postID := primitive.NewObjectID()
+
+	insertComment(t, store, postID, primitive.NilObjectID, "One", true)
+	insertComment(t, store, postID, primitive.NilObjectID, "Two", false)
+
+	err := store.Clean(context.Background())
+	assertNil(t, err)
+
+	assertComments(t, store, primitive.NilObjectID, "")
+}
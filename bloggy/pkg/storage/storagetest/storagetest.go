@@ -0,0 +1,1961 @@
+// Package storagetest provides a conformance suite for implementations of
+// storage.Storage. Third party backends can run it from their own tests:
+//
+//	func TestMyStore(t *testing.T) {
+//		storagetest.RunConformance(t, func(t *testing.T) storage.Storage {
+//			return newEmptyMyStore(t)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/storage"
+)
+
+// Factory returns a new, empty store. The suite disconnects it when done
+type Factory func(t *testing.T) storage.Storage
+
+type config struct {
+	unordered bool
+}
+
+type Option func(*config)
+
+// WithoutOrdering relaxes the requirement that All returns posts in
+// insertion order
+func WithoutOrdering() Option {
+	return func(c *config) {
+		c.unordered = true
+	}
+}
+
+type suite struct {
+	config
+	factory Factory
+}
+
+// RunConformance runs every conformance test against stores created by
+// factory, each test as a subtest with its own store
+func RunConformance(t *testing.T, factory Factory, opts ...Option) {
+	s := suite{factory: factory}
+
+	for _, opt := range opts {
+		opt(&s.config)
+	}
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.Storage)
+	}{
+		{"Count", s.testCount},
+		{"Create", s.testCreate},
+		{"CreateAlreadyExists", s.testCreateAlreadyExists},
+		{"Find", s.testFind},
+		{"FindDoesNotExist", s.testFindDoesNotExist},
+		{"Modify", s.testModify},
+		{"ModifyDoesNotExist", s.testModifyDoesNotExist},
+		{"ModifyTitle", s.testModifyTitle},
+		{"ModifySlug", s.testModifySlug},
+		{"Slugs", s.testSlugs},
+		{"SlugGiven", s.testSlugGiven},
+		{"Aliases", s.testAliases},
+		{"AliasesTaken", s.testAliasesTaken},
+		{"AliasesPurged", s.testAliasesPurged},
+		{"Remove", s.testRemove},
+		{"RemoveDoesNotExist", s.testRemoveDoesNotExist},
+		{"Clean", s.testClean},
+		{"ContextCancelled", s.testContextCancelled},
+		{"ListPagination", s.testListPagination},
+		{"ListSortDescending", s.testListSortDescending},
+		{"ListInvalidOptions", s.testListInvalidOptions},
+		{"ListSortCreatedAt", s.testListSortCreatedAt},
+		{"ListSortPublishedAt", s.testListSortPublishedAt},
+		{"Timestamps", s.testTimestamps},
+		{"Search", s.testSearch},
+		{"SearchPagination", s.testSearchPagination},
+		{"SearchInvalidOptions", s.testSearchInvalidOptions},
+		{"SearchModified", s.testSearchModified},
+		{"Taxonomy", s.testTaxonomy},
+		{"TaxonomyInvalid", s.testTaxonomyInvalid},
+		{"ListByTaxonomy", s.testListByTaxonomy},
+		{"Facets", s.testFacets},
+		{"RenameTags", s.testRenameTags},
+		{"Workflow", s.testWorkflow},
+		{"WorkflowInvalid", s.testWorkflowInvalid},
+		{"ListByStatus", s.testListByStatus},
+		{"ListByAuthor", s.testListByAuthor},
+		{"Schedule", s.testSchedule},
+		{"ScheduleInvalid", s.testScheduleInvalid},
+		{"PublishDue", s.testPublishDue},
+		{"Revisions", s.testRevisions},
+		{"RevisionDoesNotExist", s.testRevisionDoesNotExist},
+		{"RestoreRevision", s.testRestoreRevision},
+		{"PurgeRevisions", s.testPurgeRevisions},
+		{"Version", s.testVersion},
+		{"VersionConflict", s.testVersionConflict},
+		{"Trash", s.testTrash},
+		{"Restore", s.testRestore},
+		{"Purge", s.testPurge},
+		{"PurgeDeletedBefore", s.testPurgeDeletedBefore},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			store := s.factory(t)
+			defer store.Disconnect(context.Background())
+
+			tc.test(t, store)
+		})
+	}
+}
+
+func testPosts() (models.Post, models.Post, models.Post) {
+	testPostOne := models.Post{
+		Slug:    "hello",
+		Title:   "Hello",
+		Name:    "Vishnu",
+		Content: "Hello world",
+	}
+
+	testPostTwo := models.Post{
+		Slug:    "world",
+		Title:   "World",
+		Name:    "Shankar",
+		Content: "This is golang!!",
+	}
+
+	testPostThree := models.Post{
+		Slug:    "golang",
+		Title:   "Golang",
+		Name:    "Bob",
+		Content: "Golang is awesome!!",
+	}
+
+	return testPostOne, testPostTwo, testPostThree
+}
+
+func (s *suite) assertPostsSlice(t *testing.T, store storage.Storage, expectedPosts []models.Post) {
+	t.Helper()
+
+	posts, err := store.All(context.Background())
+
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(posts) != len(expectedPosts) {
+		t.Errorf("Expected item count to be %d but got %d", len(expectedPosts), len(posts))
+		return
+	}
+
+	if s.unordered {
+		for _, expectedPost := range expectedPosts {
+			if !containsPost(posts, expectedPost) {
+				t.Errorf("Expected %v to be in %v", expectedPost, posts)
+			}
+		}
+
+		return
+	}
+
+	for i := range expectedPosts {
+		if !expectedPosts[i].IsEqual(posts[i]) {
+			t.Errorf("Expected index %d post to be %v but got %v", i, expectedPosts[i], posts[i])
+		}
+	}
+}
+
+func containsPost(posts []models.Post, post models.Post) bool {
+	for _, p := range posts {
+		if p.IsEqual(post) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func assertNil(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func assertError(t *testing.T, err error, expected error) {
+	t.Helper()
+
+	if err != expected {
+		t.Errorf("Expected error to be %v but got %v", expected, err)
+	}
+}
+
+func insertAll(t *testing.T, store storage.Storage, posts ...models.Post) {
+	t.Helper()
+
+	for _, post := range posts {
+		_, err := store.Insert(context.Background(), post)
+		assertNil(t, err)
+	}
+}
+
+func (s *suite) testCount(t *testing.T, store storage.Storage) {
+	testPostOne, testPostTwo, testPostThree := testPosts()
+
+	insertAll(t, store, testPostOne, testPostTwo, testPostThree)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostOne, testPostTwo, testPostThree})
+}
+
+func (s *suite) testCreate(t *testing.T, store storage.Storage) {
+	testPost, _, _ := testPosts()
+
+	_, err := store.Insert(context.Background(), testPost)
+
+	assertNil(t, err)
+
+	s.assertPostsSlice(t, store, []models.Post{testPost})
+}
+
+func (s *suite) testCreateAlreadyExists(t *testing.T, store storage.Storage) {
+	testPost, _, _ := testPosts()
+
+	_, err := store.Insert(context.Background(), testPost)
+
+	assertNil(t, err)
+
+	testPostDuplicate := testPost
+	testPostDuplicate.Content = "Duplicate title"
+
+	_, err = store.Insert(context.Background(), testPostDuplicate)
+
+	assertError(t, err, storage.ErrAlreadyExists)
+
+	s.assertPostsSlice(t, store, []models.Post{testPost})
+}
+
+func (s *suite) testFind(t *testing.T, store storage.Storage) {
+	testPostOne, testPostTwo, testPostThree := testPosts()
+
+	insertAll(t, store, testPostOne, testPostTwo, testPostThree)
+
+	post, err := store.Find(context.Background(), "world")
+
+	assertNil(t, err)
+
+	if !post.IsEqual(testPostTwo) {
+		t.Errorf("Expected %v but got %v", testPostTwo, post)
+	}
+}
+
+func (s *suite) testFindDoesNotExist(t *testing.T, store storage.Storage) {
+	_, err := store.Find(context.Background(), "hello")
+
+	assertError(t, err, storage.ErrDoesNotExist)
+}
+
+func (s *suite) testModify(t *testing.T, store storage.Storage) {
+	testPost, _, _ := testPosts()
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	insertAll(t, store, testPost)
+
+	_, err := store.Modify(context.Background(), "hello", storage.AnyVersion, testPostModify)
+
+	assertNil(t, err)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostModify})
+}
+
+func (s *suite) testModifyDoesNotExist(t *testing.T, store storage.Storage) {
+	testPost, _, _ := testPosts()
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	insertAll(t, store, testPost)
+
+	_, err := store.Modify(context.Background(), "DoesNotExist", storage.AnyVersion, testPostModify)
+
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	s.assertPostsSlice(t, store, []models.Post{testPost})
+}
+
+func (s *suite) testModifyTitle(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	// Titles are free-form and need not be unique, the slug is kept
+	testPostRename := testPostOne
+	testPostRename.Slug = ""
+	testPostRename.Title = testPostTwo.Title
+
+	modifiedPost, err := store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostRename)
+	assertNil(t, err)
+
+	if modifiedPost.Slug != testPostOne.Slug || modifiedPost.Title != testPostTwo.Title {
+		t.Errorf("Expected slug %s to be kept but got %v", testPostOne.Slug, modifiedPost)
+	}
+
+	foundPost, err := store.Find(ctx, testPostOne.Slug)
+	assertNil(t, err)
+
+	if !foundPost.IsEqual(testPostRename) {
+		t.Errorf("Expected %v but got %v", testPostRename, foundPost)
+	}
+}
+
+func (s *suite) testModifySlug(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	testPostRename := testPostOne
+	testPostRename.Slug = testPostTwo.Slug
+
+	_, err := store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostRename)
+	assertError(t, err, storage.ErrAlreadyExists)
+
+	testPostRename.Slug = "Hello again"
+
+	_, err = store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostRename)
+	assertError(t, err, storage.ErrInvalidSlug)
+
+	testPostRename.Slug = "hello-again"
+
+	modifiedPost, err := store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostRename)
+	assertNil(t, err)
+
+	if modifiedPost.Slug != testPostRename.Slug {
+		t.Errorf("Expected slug to be %s but got %s", testPostRename.Slug, modifiedPost.Slug)
+	}
+
+	// The old slug is an alias of the post
+	for _, slug := range []string{testPostRename.Slug, testPostOne.Slug} {
+		foundPost, err := store.Find(ctx, slug)
+		assertNil(t, err)
+
+		if foundPost.ID != modifiedPost.ID || foundPost.Slug != testPostRename.Slug {
+			t.Errorf("Expected %v but got %v", modifiedPost, foundPost)
+		}
+	}
+
+	// Position is kept when the slug changes
+	s.assertPostsSlice(t, store, []models.Post{testPostRename, testPostTwo})
+}
+
+func (s *suite) testSlugs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	titles := []string{"Hello, World!", "Hello world", "hello WORLD?", "Crème brûlée", "日本語"}
+	expectedSlugs := []string{"hello-world", "hello-world-2", "hello-world-3", "creme-brulee", "post"}
+
+	for i, title := range titles {
+		insertedPost, err := store.Insert(ctx, models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+		assertNil(t, err)
+
+		if insertedPost.Slug != expectedSlugs[i] {
+			t.Errorf("Expected slug of %q to be %s but got %s", title, expectedSlugs[i], insertedPost.Slug)
+		}
+
+		foundPost, err := store.Find(ctx, expectedSlugs[i])
+		assertNil(t, err)
+
+		if foundPost.Title != title {
+			t.Errorf("Expected post %s to have title %q but got %q", expectedSlugs[i], title, foundPost.Title)
+		}
+	}
+}
+
+func (s *suite) testSlugGiven(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	testPost.Slug = "greeting"
+
+	insertedPost, err := store.Insert(ctx, testPost)
+	assertNil(t, err)
+
+	if insertedPost.Slug != "greeting" {
+		t.Errorf("Expected given slug to be kept but got %s", insertedPost.Slug)
+	}
+
+	// Given slugs are not suffixed
+	_, err = store.Insert(ctx, testPost)
+	assertError(t, err, storage.ErrAlreadyExists)
+
+	testPost.Slug = "Not a slug"
+
+	_, err = store.Insert(ctx, testPost)
+	assertError(t, err, storage.ErrInvalidSlug)
+
+	// A generated slug steps around given ones
+	testPost.Slug = ""
+	testPost.Title = "Greeting"
+
+	insertedPost, err = store.Insert(ctx, testPost)
+	assertNil(t, err)
+
+	if insertedPost.Slug != "greeting-2" {
+		t.Errorf("Expected slug to be greeting-2 but got %s", insertedPost.Slug)
+	}
+}
+
+// rename modifies the slug of the post with slug
+func rename(t *testing.T, store storage.Storage, post models.Post, slug string, newSlug string) models.Post {
+	post.Slug = newSlug
+
+	modifiedPost, err := store.Modify(context.Background(), slug, storage.AnyVersion, post)
+	assertNil(t, err)
+
+	return modifiedPost
+}
+
+// assertFoundAs asserts that Find resolves every one of slugs to the post
+// with the canonical slug
+func assertFoundAs(t *testing.T, store storage.Storage, canonical string, slugs ...string) {
+	for _, slug := range slugs {
+		foundPost, err := store.Find(context.Background(), slug)
+
+		if err != nil {
+			t.Errorf("Expected %s to be found but got %s", slug, err)
+			continue
+		}
+
+		if foundPost.Slug != canonical {
+			t.Errorf("Expected %s to resolve to %s but got %s", slug, canonical, foundPost.Slug)
+		}
+	}
+}
+
+func (s *suite) testAliases(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	// Every old slug resolves to the current one
+	rename(t, store, testPost, "hello", "hello-again")
+	rename(t, store, testPost, "hello-again", "hello-at-last")
+
+	assertFoundAs(t, store, "hello-at-last", "hello", "hello-again", "hello-at-last")
+
+	// Only Find resolves aliases
+	_, err := store.Modify(ctx, "hello", storage.AnyVersion, testPost)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Revisions(ctx, "hello-again")
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	// A post can take back one of its old slugs
+	rename(t, store, testPost, "hello-at-last", "hello")
+
+	assertFoundAs(t, store, "hello", "hello", "hello-again", "hello-at-last")
+
+	// Aliases of trashed posts are hidden until restored
+	assertNil(t, store.Remove(ctx, "hello", storage.AnyVersion))
+
+	_, err = store.Find(ctx, "hello-again")
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Restore(ctx, "hello")
+	assertNil(t, err)
+
+	assertFoundAs(t, store, "hello", "hello-again")
+}
+
+func (s *suite) testAliasesTaken(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	rename(t, store, testPostOne, "hello", "greeting")
+
+	// Old slugs are taken so links to them keep pointing to the same post
+	_, err := store.Insert(ctx, testPostOne)
+	assertError(t, err, storage.ErrAlreadyExists)
+
+	testPostTwo.Slug = "hello"
+
+	_, err = store.Modify(ctx, "world", storage.AnyVersion, testPostTwo)
+	assertError(t, err, storage.ErrAlreadyExists)
+
+	testPostOne.Slug = ""
+
+	insertedPost, err := store.Insert(ctx, testPostOne)
+	assertNil(t, err)
+
+	if insertedPost.Slug != "hello-2" {
+		t.Errorf("Expected slug to be hello-2 but got %s", insertedPost.Slug)
+	}
+
+	assertFoundAs(t, store, "greeting", "hello")
+}
+
+func (s *suite) testAliasesPurged(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	rename(t, store, testPost, "hello", "greeting")
+
+	assertNil(t, store.Remove(ctx, "greeting", storage.AnyVersion))
+	_, err := store.Purge(ctx, "greeting")
+	assertNil(t, err)
+
+	_, err = store.Find(ctx, "hello")
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	// Purged aliases are free again
+	insertAll(t, store, testPost)
+}
+
+func (s *suite) testRemove(t *testing.T, store storage.Storage) {
+	testPostOne, testPostTwo, testPostThree := testPosts()
+
+	insertAll(t, store, testPostOne, testPostTwo, testPostThree)
+
+	err := store.Remove(context.Background(), "world", storage.AnyVersion)
+
+	assertNil(t, err)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostOne, testPostThree})
+}
+
+func (s *suite) testRemoveDoesNotExist(t *testing.T, store storage.Storage) {
+	err := store.Remove(context.Background(), "hello", storage.AnyVersion)
+
+	assertError(t, err, storage.ErrDoesNotExist)
+}
+
+func (s *suite) testClean(t *testing.T, store storage.Storage) {
+	testPostOne, testPostTwo, testPostThree := testPosts()
+
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	err := store.Clean(context.Background())
+
+	assertNil(t, err)
+
+	s.assertPostsSlice(t, store, nil)
+
+	// Store is usable after cleaning
+	insertAll(t, store, testPostOne, testPostThree)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostOne, testPostThree})
+}
+
+func (s *suite) testContextCancelled(t *testing.T, store storage.Storage) {
+	testPostOne, testPostTwo, _ := testPosts()
+
+	insertAll(t, store, testPostOne)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testPostModify := testPostOne
+	testPostModify.Content = "Golang is awesome!!"
+
+	_, err := store.Insert(ctx, testPostTwo)
+
+	if err == nil {
+		t.Error("Expected Insert with cancelled context to fail")
+	}
+
+	_, err = store.Find(ctx, "hello")
+
+	if err == nil {
+		t.Error("Expected Find with cancelled context to fail")
+	}
+
+	_, err = store.Modify(ctx, "hello", storage.AnyVersion, testPostModify)
+
+	if err == nil {
+		t.Error("Expected Modify with cancelled context to fail")
+	}
+
+	err = store.Remove(ctx, "hello", storage.AnyVersion)
+
+	if err == nil {
+		t.Error("Expected Remove with cancelled context to fail")
+	}
+
+	_, err = store.All(ctx)
+
+	if err == nil {
+		t.Error("Expected All with cancelled context to fail")
+	}
+
+	_, err = store.List(ctx, storage.ListOptions{})
+
+	if err == nil {
+		t.Error("Expected List with cancelled context to fail")
+	}
+
+	_, err = store.Trash(ctx)
+
+	if err == nil {
+		t.Error("Expected Trash with cancelled context to fail")
+	}
+
+	// None of the cancelled calls have changed the store
+	s.assertPostsSlice(t, store, []models.Post{testPostOne})
+}
+
+func assertPage(t *testing.T, page storage.Page, titles []string, hasPrev bool, hasNext bool) {
+	t.Helper()
+
+	var pageTitles []string
+
+	for _, post := range page.Posts {
+		pageTitles = append(pageTitles, post.Title)
+	}
+
+	if fmt.Sprint(pageTitles) != fmt.Sprint(titles) {
+		t.Errorf("Expected page to be %v but got %v", titles, pageTitles)
+	}
+
+	if (page.Prev != "") != hasPrev {
+		t.Errorf("Expected prev cursor presence to be %t but got %q", hasPrev, page.Prev)
+	}
+
+	if (page.Next != "") != hasNext {
+		t.Errorf("Expected next cursor presence to be %t but got %q", hasNext, page.Next)
+	}
+}
+
+func (s *suite) testListPagination(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	for _, title := range []string{"C", "A", "E", "B", "D"} {
+		insertAll(t, store, models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+	}
+
+	page, err := store.List(ctx, storage.ListOptions{Limit: 2, Sort: storage.SortTitle})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: storage.SortTitle, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C", "D"}, true, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: storage.SortTitle, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"E"}, true, false)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: storage.SortTitle, Cursor: page.Prev})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C", "D"}, true, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: storage.SortTitle, Cursor: page.Prev})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B"}, false, true)
+
+	// Title is the default sort key
+	page, err = store.List(ctx, storage.ListOptions{Limit: 10})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B", "C", "D", "E"}, false, false)
+}
+
+func (s *suite) testListSortDescending(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	insertAll(t, store,
+		models.Post{Title: "A", Name: "Bob", Content: "Hello world"},
+		models.Post{Title: "B", Name: "Alice", Content: "Hello world"},
+		models.Post{Title: "C", Name: "Bob", Content: "Hello world"},
+		models.Post{Title: "D", Name: "Carol", Content: "Hello world"},
+	)
+
+	// Posts with the same name are ordered by title
+	page, err := store.List(ctx, storage.ListOptions{Limit: 3, Sort: "-" + storage.SortName})
+	assertNil(t, err)
+	assertPage(t, page, []string{"D", "C", "A"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 3, Sort: "-" + storage.SortName, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"B"}, true, false)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 1, Sort: "-" + storage.SortName, Cursor: page.Prev})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A"}, true, true)
+}
+
+func (s *suite) testListInvalidOptions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	insertAll(t, store, models.Post{Title: "A", Name: "Bob", Content: "Hello world"})
+	insertAll(t, store, models.Post{Title: "B", Name: "Bob", Content: "Hello world"})
+
+	_, err := store.List(ctx, storage.ListOptions{Sort: "content"})
+	assertError(t, err, storage.ErrInvalidSort)
+
+	_, err = store.List(ctx, storage.ListOptions{Cursor: "not a cursor"})
+	assertError(t, err, storage.ErrInvalidCursor)
+
+	// Cursors can not be reused with another sort key
+	page, err := store.List(ctx, storage.ListOptions{Limit: 1, Sort: storage.SortTitle})
+	assertNil(t, err)
+
+	_, err = store.List(ctx, storage.ListOptions{Limit: 1, Sort: storage.SortName, Cursor: page.Next})
+	assertError(t, err, storage.ErrInvalidCursor)
+}
+
+func (s *suite) testListSortCreatedAt(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	for _, title := range []string{"C", "A", "B"} {
+		insertAll(t, store, models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+
+		// Timestamps have millisecond precision
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	page, err := store.List(ctx, storage.ListOptions{Limit: 2, Sort: "-" + storage.SortCreatedAt})
+	assertNil(t, err)
+	assertPage(t, page, []string{"B", "A"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: "-" + storage.SortCreatedAt, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C"}, true, false)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: storage.SortCreatedAt})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C", "A"}, false, true)
+}
+
+func (s *suite) testListSortPublishedAt(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	for _, title := range []string{"A", "B", "C", "Draft"} {
+		insertAll(t, store, models.Post{Title: title, Name: "Vishnu", Content: "Hello world"})
+	}
+
+	// Published in another order than they were created, drafts are left out
+	for _, slug := range []string{"c", "a", "b"} {
+		time.Sleep(2 * time.Millisecond)
+
+		_, err := store.Transition(ctx, slug, storage.AnyVersion, models.StatusInReview)
+		assertNil(t, err)
+
+		_, err = store.Transition(ctx, slug, storage.AnyVersion, models.StatusPublished)
+		assertNil(t, err)
+	}
+
+	page, err := store.List(ctx, storage.ListOptions{Limit: 2, Sort: "-" + storage.SortPublishedAt})
+	assertNil(t, err)
+	assertPage(t, page, []string{"B", "A"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: "-" + storage.SortPublishedAt, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C"}, true, false)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Sort: "-" + storage.SortPublishedAt, Cursor: page.Prev})
+	assertNil(t, err)
+	assertPage(t, page, []string{"B", "A"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 3, Sort: storage.SortPublishedAt})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C", "A", "B"}, false, false)
+}
+
+func (s *suite) testTimestamps(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	clientTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testPost, _, _ := testPosts()
+	testPost.CreatedAt = clientTime
+	testPost.UpdatedAt = clientTime
+	testPost.PublishedAt = &clientTime
+	testPost.Status = models.StatusPublished
+
+	insertedPost, err := store.Insert(ctx, testPost)
+	assertNil(t, err)
+
+	if insertedPost.ID.IsZero() {
+		t.Error("Expected inserted post to have an ID")
+	}
+
+	if insertedPost.CreatedAt.Equal(clientTime) || insertedPost.UpdatedAt.Equal(clientTime) {
+		t.Errorf("Expected client timestamps to be ignored but got %v", insertedPost)
+	}
+
+	// Posts are inserted as unpublished drafts
+	if insertedPost.PublishedAt != nil || insertedPost.Status != models.StatusDraft {
+		t.Errorf("Expected an unpublished draft but got %v", insertedPost)
+	}
+
+	foundPost, err := store.Find(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if foundPost.ID != insertedPost.ID || !foundPost.CreatedAt.Equal(insertedPost.CreatedAt) {
+		t.Errorf("Expected found post to be %v but got %v", insertedPost, foundPost)
+	}
+
+	_, err = store.Transition(ctx, testPost.Slug, storage.AnyVersion, models.StatusInReview)
+	assertNil(t, err)
+
+	publishedPost, err := store.Transition(ctx, testPost.Slug, storage.AnyVersion, models.StatusPublished)
+	assertNil(t, err)
+
+	if publishedPost.PublishedAt == nil || publishedPost.PublishedAt.Before(insertedPost.CreatedAt) {
+		t.Fatalf("Expected publishedAt to be set on publication but got %v", publishedPost)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	modifiedPost, err := store.Modify(ctx, testPost.Slug, storage.AnyVersion, testPostModify)
+	assertNil(t, err)
+
+	if !modifiedPost.IsEqual(testPostModify) || modifiedPost.ID != insertedPost.ID {
+		t.Errorf("Expected modified post to be %v but got %v", testPostModify, modifiedPost)
+	}
+
+	if !modifiedPost.CreatedAt.Equal(insertedPost.CreatedAt) || modifiedPost.PublishedAt == nil || !modifiedPost.PublishedAt.Equal(*publishedPost.PublishedAt) {
+		t.Errorf("Expected createdAt and publishedAt to be kept but got %v", modifiedPost)
+	}
+
+	if modifiedPost.Status != models.StatusPublished {
+		t.Errorf("Expected status to be kept but got %s", modifiedPost.Status)
+	}
+
+	if !modifiedPost.UpdatedAt.After(insertedPost.UpdatedAt) {
+		t.Errorf("Expected updatedAt to be after %s but got %s", insertedPost.UpdatedAt, modifiedPost.UpdatedAt)
+	}
+
+	foundPost, err = store.Find(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if !foundPost.UpdatedAt.Equal(modifiedPost.UpdatedAt) {
+		t.Errorf("Expected stored updatedAt to be %s but got %s", modifiedPost.UpdatedAt, foundPost.UpdatedAt)
+	}
+}
+
+// assertSearch asserts that query finds the posts with slugs, in order
+func assertSearch(t *testing.T, store storage.Storage, query string, slugs ...string) storage.SearchPage {
+	t.Helper()
+
+	page, err := store.Search(context.Background(), query, storage.SearchOptions{})
+	assertNil(t, err)
+
+	var found []string
+
+	for _, result := range page.Results {
+		found = append(found, result.Post.Slug)
+	}
+
+	if fmt.Sprint(found) != fmt.Sprint(slugs) {
+		t.Errorf("Expected search for %q to find %v but got %v", query, slugs, found)
+	}
+
+	return page
+}
+
+func (s *suite) testSearch(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	insertAll(t, store,
+		models.Post{Title: "Concurrency in Go", Name: "Vishnu", Content: "Goroutines and channels make concurrent programs simple."},
+		models.Post{Title: "Cooking pasta", Name: "Shankar", Content: "Boil the water and add salt, then cook the pasta."},
+		models.Post{Title: "Weeknight dinners", Name: "Bob", Content: "Cook pasta while the sauce simmers, two concurrent pots save time."},
+	)
+
+	// Words match by stem and matches in titles rank higher
+	page := assertSearch(t, store, "concurrency", "concurrency-in-go", "weeknight-dinners")
+	assertSearch(t, store, "Cooking", "cooking-pasta", "weeknight-dinners")
+	assertSearch(t, store, "rust")
+
+	if page.Next != "" || page.Prev != "" {
+		t.Errorf("Expected a single page but got %+v", page)
+	}
+
+	for _, result := range page.Results {
+		if result.Score <= 0 {
+			t.Errorf("Expected %s to have a positive score but got %f", result.Post.Slug, result.Score)
+		}
+	}
+
+	snippet := page.Results[1].Snippet
+
+	if !strings.Contains(snippet, "<mark>concurrent</mark>") || strings.Contains(snippet, "<mark>pasta</mark>") {
+		t.Errorf("Expected snippet to highlight the match but got %q", snippet)
+	}
+
+	// Any of the words matches
+	assertSearch(t, store, "goroutines salt", "concurrency-in-go", "cooking-pasta")
+
+	// Trashed posts are not found
+	assertNil(t, store.Remove(ctx, "cooking-pasta", storage.AnyVersion))
+
+	assertSearch(t, store, "Cooking", "weeknight-dinners")
+}
+
+func (s *suite) testSearchPagination(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		insertAll(t, store, models.Post{Title: fmt.Sprintf("Post %d", i), Name: "Vishnu", Content: "Golang is awesome!!"})
+	}
+
+	var slugs []string
+
+	seen := map[string]bool{}
+	opts := storage.SearchOptions{Limit: 2}
+
+	for _, expectedCount := range []int{2, 2, 1} {
+		page, err := store.Search(ctx, "golang", opts)
+		assertNil(t, err)
+
+		if len(page.Results) != expectedCount {
+			t.Fatalf("Expected %d results but got %d", expectedCount, len(page.Results))
+		}
+
+		if (page.Prev == "") != (opts.Cursor == "") {
+			t.Errorf("Expected prev cursor only after the first page but got %q", page.Prev)
+		}
+
+		for _, result := range page.Results {
+			if seen[result.Post.Slug] {
+				t.Errorf("Expected %s to be found once", result.Post.Slug)
+			}
+
+			seen[result.Post.Slug] = true
+			slugs = append(slugs, result.Post.Slug)
+		}
+
+		opts.Cursor = page.Next
+	}
+
+	if opts.Cursor != "" {
+		t.Errorf("Expected no next cursor on the last page but got %q", opts.Cursor)
+	}
+
+	// Going back returns the previous page
+	page, err := store.Search(ctx, "golang", storage.SearchOptions{Limit: 2})
+	assertNil(t, err)
+
+	page, err = store.Search(ctx, "golang", storage.SearchOptions{Limit: 2, Cursor: page.Next})
+	assertNil(t, err)
+
+	page, err = store.Search(ctx, "golang", storage.SearchOptions{Limit: 2, Cursor: page.Prev})
+	assertNil(t, err)
+
+	if len(page.Results) != 2 || page.Results[0].Post.Slug != slugs[0] || page.Results[1].Post.Slug != slugs[1] {
+		t.Errorf("Expected first page %v but got %+v", slugs[:2], page.Results)
+	}
+}
+
+func (s *suite) testSearchInvalidOptions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, testPostThree := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo, testPostThree)
+
+	// Queries without a searchable word are rejected
+	for _, query := range []string{"", "  ", "?!", "the"} {
+		_, err := store.Search(ctx, query, storage.SearchOptions{})
+		assertError(t, err, storage.ErrInvalidQuery)
+	}
+
+	_, err := store.Search(ctx, "golang", storage.SearchOptions{Cursor: "bad"})
+	assertError(t, err, storage.ErrInvalidCursor)
+
+	// Cursors are tied to their query
+	page, err := store.Search(ctx, "golang", storage.SearchOptions{Limit: 1})
+	assertNil(t, err)
+
+	_, err = store.Search(ctx, "hello", storage.SearchOptions{Cursor: page.Next})
+	assertError(t, err, storage.ErrInvalidCursor)
+}
+
+func (s *suite) testSearchModified(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	testPostModify := testPostOne
+	testPostModify.Slug = "greeting"
+	testPostModify.Content = "Golang is awesome!!"
+
+	_, err := store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostModify)
+	assertNil(t, err)
+
+	assertSearch(t, store, "awesome", "greeting")
+	assertSearch(t, store, "world", "world")
+
+	assertNil(t, store.Remove(ctx, "greeting", storage.AnyVersion))
+	_, err = store.Purge(ctx, "greeting")
+	assertNil(t, err)
+
+	assertSearch(t, store, "awesome")
+}
+
+func (s *suite) testTaxonomy(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	testPost.Tags = []string{"Golang", " web ", "golang"}
+	testPost.Category = "Programming"
+
+	insertedPost, err := store.Insert(ctx, testPost)
+	assertNil(t, err)
+
+	// Tags are lowercased, sorted and deduplicated
+	if fmt.Sprint(insertedPost.Tags) != "[golang web]" || insertedPost.Category != "programming" {
+		t.Errorf("Expected normalized tags and category but got %v and %q", insertedPost.Tags, insertedPost.Category)
+	}
+
+	foundPost, err := store.Find(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if !foundPost.IsEqual(insertedPost) {
+		t.Errorf("Expected found post to be %v but got %v", insertedPost, foundPost)
+	}
+
+	testPostModify := insertedPost
+	testPostModify.Tags = nil
+	testPostModify.Category = ""
+
+	modifiedPost, err := store.Modify(ctx, testPost.Slug, storage.AnyVersion, testPostModify)
+	assertNil(t, err)
+
+	// Posts without tags have an empty list
+	if modifiedPost.Tags == nil || len(modifiedPost.Tags) != 0 || modifiedPost.Category != "" {
+		t.Errorf("Expected no tags and category but got %v and %q", modifiedPost.Tags, modifiedPost.Category)
+	}
+
+	modifiedPost.Tags = []string{"golang"}
+
+	_, err = store.Modify(ctx, testPost.Slug, storage.AnyVersion, modifiedPost)
+	assertNil(t, err)
+
+	// Restoring a revision keeps the current tags
+	restoredPost, err := store.RestoreRevision(ctx, testPost.Slug, 1)
+	assertNil(t, err)
+
+	if fmt.Sprint(restoredPost.Tags) != "[golang]" {
+		t.Errorf("Expected restored post to keep tags [golang] but got %v", restoredPost.Tags)
+	}
+}
+
+func (s *suite) testTaxonomyInvalid(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	testPost.Tags = []string{"not a tag"}
+
+	_, err := store.Insert(ctx, testPost)
+	assertError(t, err, storage.ErrInvalidTag)
+
+	testPost.Tags = nil
+	testPost.Category = "not/a/category"
+
+	_, err = store.Insert(ctx, testPost)
+	assertError(t, err, storage.ErrInvalidTag)
+
+	testPost.Category = ""
+	insertAll(t, store, testPost)
+
+	testPost.Tags = []string{""}
+
+	_, err = store.Modify(ctx, testPost.Slug, storage.AnyVersion, testPost)
+	assertError(t, err, storage.ErrInvalidTag)
+
+	_, err = store.List(ctx, storage.ListOptions{Tag: "not a tag"})
+	assertError(t, err, storage.ErrInvalidTag)
+
+	_, err = store.RenameTags(ctx, nil, "golang")
+	assertError(t, err, storage.ErrInvalidTag)
+
+	_, err = store.RenameTags(ctx, []string{"golang"}, "")
+	assertError(t, err, storage.ErrInvalidTag)
+}
+
+func (s *suite) testListByTaxonomy(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	insertAll(t, store,
+		models.Post{Title: "A", Name: "Bob", Content: "Hello world", Tags: []string{"go"}, Category: "code"},
+		models.Post{Title: "B", Name: "Bob", Content: "Hello world", Tags: []string{"go", "web"}},
+		models.Post{Title: "C", Name: "Bob", Content: "Hello world", Tags: []string{"web"}, Category: "code"},
+		models.Post{Title: "D", Name: "Bob", Content: "Hello world", Tags: []string{"go"}, Category: "code"},
+		models.Post{Title: "E", Name: "Bob", Content: "Hello world"},
+	)
+
+	page, err := store.List(ctx, storage.ListOptions{Limit: 2, Tag: "Go"})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 2, Tag: "Go", Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"D"}, true, false)
+
+	page, err = store.List(ctx, storage.ListOptions{Category: "code"})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "C", "D"}, false, false)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 1, Tag: "go", Category: "code"})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A"}, false, true)
+
+	// Cursors can not be reused with another filter
+	_, err = store.List(ctx, storage.ListOptions{Limit: 1, Tag: "web", Category: "code", Cursor: page.Next})
+	assertError(t, err, storage.ErrInvalidCursor)
+}
+
+func assertFacets(t *testing.T, facets []storage.Facet, err error, expected string) {
+	t.Helper()
+
+	assertNil(t, err)
+
+	if fmt.Sprint(facets) != expected {
+		t.Errorf("Expected facets to be %s but got %v", expected, facets)
+	}
+}
+
+func (s *suite) testFacets(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	facets, err := store.Tags(ctx)
+	assertFacets(t, facets, err, "[]")
+
+	insertAll(t, store,
+		models.Post{Slug: "a", Title: "A", Name: "Bob", Content: "Hello world", Tags: []string{"go"}, Category: "code"},
+		models.Post{Slug: "b", Title: "B", Name: "Bob", Content: "Hello world", Tags: []string{"go", "web"}},
+		models.Post{Slug: "c", Title: "C", Name: "Bob", Content: "Hello world", Tags: []string{"web", "news"}, Category: "code"},
+		models.Post{Slug: "d", Title: "D", Name: "Bob", Content: "Hello world", Tags: []string{"go"}, Category: "life"},
+	)
+
+	// Most used first, ties by value
+	facets, err = store.Tags(ctx)
+	assertFacets(t, facets, err, "[{go 3} {web 2} {news 1}]")
+
+	facets, err = store.Categories(ctx)
+	assertFacets(t, facets, err, "[{code 2} {life 1}]")
+
+	// Trashed posts are not counted
+	assertNil(t, store.Remove(ctx, "d", storage.AnyVersion))
+
+	facets, err = store.Tags(ctx)
+	assertFacets(t, facets, err, "[{go 2} {web 2} {news 1}]")
+
+	facets, err = store.Categories(ctx)
+	assertFacets(t, facets, err, "[{code 2}]")
+}
+
+func (s *suite) testRenameTags(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	insertAll(t, store,
+		models.Post{Slug: "a", Title: "A", Name: "Bob", Content: "Hello world", Tags: []string{"go", "golang"}},
+		models.Post{Slug: "b", Title: "B", Name: "Bob", Content: "Hello world", Tags: []string{"golang", "web"}},
+		models.Post{Slug: "c", Title: "C", Name: "Bob", Content: "Hello world", Tags: []string{"web"}},
+		models.Post{Slug: "d", Title: "D", Name: "Bob", Content: "Hello world", Tags: []string{"golang-lang"}},
+	)
+
+	assertNil(t, store.Remove(ctx, "d", storage.AnyVersion))
+
+	// Merging into a tag some posts already have leaves them with one copy,
+	// trashed posts are renamed too
+	renamed, err := store.RenameTags(ctx, []string{"Golang", "golang-lang"}, "go")
+	assertNil(t, err)
+
+	if renamed != 3 {
+		t.Errorf("Expected 3 renamed posts but got %d", renamed)
+	}
+
+	facets, err := store.Tags(ctx)
+	assertFacets(t, facets, err, "[{go 2} {web 2}]")
+
+	post, err := store.Find(ctx, "b")
+	assertNil(t, err)
+
+	if fmt.Sprint(post.Tags) != "[go web]" || post.Version != 1 {
+		t.Errorf("Expected tags [go web] at version 1 but got %v at version %d", post.Tags, post.Version)
+	}
+
+	restoredPost, err := store.Restore(ctx, "d")
+	assertNil(t, err)
+
+	if fmt.Sprint(restoredPost.Tags) != "[go]" {
+		t.Errorf("Expected trashed post to be renamed but got %v", restoredPost.Tags)
+	}
+
+	// Renaming a tag no post has changes nothing
+	renamed, err = store.RenameTags(ctx, []string{"rust"}, "go")
+	assertNil(t, err)
+
+	if renamed != 0 {
+		t.Errorf("Expected no renamed posts but got %d", renamed)
+	}
+}
+
+// transition moves the post with slug through statuses, asserting each
+// transition succeeds
+func transition(t *testing.T, store storage.Storage, slug string, statuses ...models.Status) models.Post {
+	t.Helper()
+
+	var post models.Post
+
+	for _, status := range statuses {
+		var err error
+
+		post, err = store.Transition(context.Background(), slug, storage.AnyVersion, status)
+		assertNil(t, err)
+
+		if post.Status != status {
+			t.Errorf("Expected post to be %s but got %s", status, post.Status)
+		}
+	}
+
+	return post
+}
+
+func (s *suite) testWorkflow(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	// Reviewers can send a post back to draft
+	transition(t, store, testPost.Slug, models.StatusInReview, models.StatusDraft, models.StatusInReview)
+
+	publishedPost := transition(t, store, testPost.Slug, models.StatusPublished)
+
+	// Transitions are stored but do not change the version
+	foundPost, err := store.Find(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if foundPost.Status != models.StatusPublished || foundPost.PublishedAt == nil || foundPost.Version != 1 {
+		t.Errorf("Expected published post at version 1 but got %v", foundPost)
+	}
+
+	// Archived posts keep their publication time until republished
+	archivedPost := transition(t, store, testPost.Slug, models.StatusArchived)
+
+	if archivedPost.PublishedAt == nil || !archivedPost.PublishedAt.Equal(*publishedPost.PublishedAt) {
+		t.Errorf("Expected archived post to keep publishedAt %v but got %v", publishedPost.PublishedAt, archivedPost.PublishedAt)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	republishedPost := transition(t, store, testPost.Slug, models.StatusDraft, models.StatusInReview, models.StatusPublished)
+
+	if !republishedPost.PublishedAt.After(*publishedPost.PublishedAt) {
+		t.Errorf("Expected publishedAt to be after %v but got %v", publishedPost.PublishedAt, republishedPost.PublishedAt)
+	}
+
+	// Trashed posts keep their status
+	assertNil(t, store.Remove(ctx, testPost.Slug, storage.AnyVersion))
+
+	restoredPost, err := store.Restore(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if restoredPost.Status != models.StatusPublished {
+		t.Errorf("Expected restored post to be published but got %s", restoredPost.Status)
+	}
+}
+
+func (s *suite) testWorkflowInvalid(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	// Drafts must be reviewed before they are published
+	_, err := store.Transition(ctx, testPost.Slug, storage.AnyVersion, models.StatusPublished)
+	assertError(t, err, storage.ErrInvalidTransition)
+
+	_, err = store.Transition(ctx, testPost.Slug, storage.AnyVersion, models.StatusDraft)
+	assertError(t, err, storage.ErrInvalidTransition)
+
+	_, err = store.Transition(ctx, testPost.Slug, storage.AnyVersion, models.Status("deleted"))
+	assertError(t, err, storage.ErrInvalidStatus)
+
+	_, err = store.Transition(ctx, testPost.Slug, 2, models.StatusInReview)
+	assertError(t, err, storage.ErrVersionConflict)
+
+	_, err = store.Transition(ctx, "does-not-exist", storage.AnyVersion, models.StatusInReview)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	// Rejected transitions change nothing
+	foundPost, err := store.Find(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if foundPost.Status != models.StatusDraft || foundPost.PublishedAt != nil {
+		t.Errorf("Expected unpublished draft but got %v", foundPost)
+	}
+
+	_, err = store.Transition(ctx, testPost.Slug, 1, models.StatusInReview)
+	assertNil(t, err)
+}
+
+func (s *suite) testListByStatus(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	for _, title := range []string{"A", "B", "C", "D"} {
+		insertAll(t, store, models.Post{Title: title, Name: "Bob", Content: "Golang is awesome!!"})
+	}
+
+	transition(t, store, "a", models.StatusInReview, models.StatusPublished)
+	transition(t, store, "c", models.StatusInReview, models.StatusPublished)
+	transition(t, store, "d", models.StatusInReview)
+
+	page, err := store.List(ctx, storage.ListOptions{Limit: 1, Status: models.StatusPublished})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A"}, false, true)
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 1, Status: models.StatusPublished, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C"}, true, false)
+
+	// Cursors can not be reused with another status
+	_, err = store.List(ctx, storage.ListOptions{Limit: 1, Status: models.StatusDraft, Cursor: page.Prev})
+	assertError(t, err, storage.ErrInvalidCursor)
+
+	page, err = store.List(ctx, storage.ListOptions{Status: models.StatusDraft})
+	assertNil(t, err)
+	assertPage(t, page, []string{"B"}, false, false)
+
+	// No status lists every post
+	page, err = store.List(ctx, storage.ListOptions{})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B", "C", "D"}, false, false)
+
+	_, err = store.List(ctx, storage.ListOptions{Status: models.Status("deleted")})
+	assertError(t, err, storage.ErrInvalidStatus)
+
+	searchPage, err := store.Search(ctx, "golang", storage.SearchOptions{Status: models.StatusPublished})
+	assertNil(t, err)
+
+	if len(searchPage.Results) != 2 {
+		t.Errorf("Expected 2 published results but got %d", len(searchPage.Results))
+	}
+
+	for _, result := range searchPage.Results {
+		if result.Post.Status != models.StatusPublished {
+			t.Errorf("Expected published result but got %v", result.Post)
+		}
+	}
+
+	_, err = store.Search(ctx, "golang", storage.SearchOptions{Status: models.Status("deleted")})
+	assertError(t, err, storage.ErrInvalidStatus)
+}
+
+func (s *suite) testListByAuthor(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	vishnu, shankar := primitive.NewObjectID(), primitive.NewObjectID()
+
+	insertAll(t, store,
+		models.Post{Title: "A", AuthorID: vishnu, Name: "Vishnu", Content: "Hello world"},
+		models.Post{Title: "B", AuthorID: shankar, Name: "Shankar", Content: "Hello world"},
+		models.Post{Title: "C", AuthorID: vishnu, Name: "Vishnu", Content: "Hello world"},
+		models.Post{Title: "D", Name: "Bob", Content: "Hello world"},
+	)
+
+	page, err := store.List(ctx, storage.ListOptions{Limit: 1, AuthorID: vishnu})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A"}, false, true)
+
+	if page.Posts[0].AuthorID != vishnu {
+		t.Errorf("Expected author to be %s but got %s", vishnu.Hex(), page.Posts[0].AuthorID.Hex())
+	}
+
+	page, err = store.List(ctx, storage.ListOptions{Limit: 1, AuthorID: vishnu, Cursor: page.Next})
+	assertNil(t, err)
+	assertPage(t, page, []string{"C"}, true, false)
+
+	// Cursors can not be reused with another author
+	_, err = store.List(ctx, storage.ListOptions{Limit: 1, AuthorID: shankar, Cursor: page.Prev})
+	assertError(t, err, storage.ErrInvalidCursor)
+
+	// Modify replaces the author, edits without one keep the author
+	_, err = store.Modify(ctx, "b", storage.AnyVersion, models.Post{Title: "B", AuthorID: vishnu, Name: "Vishnu", Content: "Hello world"})
+	assertNil(t, err)
+
+	modifiedPost, err := store.Modify(ctx, "a", storage.AnyVersion, models.Post{Title: "A", Name: "Vishnu", Content: "Hello again"})
+	assertNil(t, err)
+
+	if modifiedPost.AuthorID != vishnu {
+		t.Errorf("Expected author to be %s but got %s", vishnu.Hex(), modifiedPost.AuthorID.Hex())
+	}
+
+	foundPost, err := store.Find(ctx, "a")
+	assertNil(t, err)
+
+	if foundPost.AuthorID != vishnu {
+		t.Errorf("Expected author to be kept as %s but got %s", vishnu.Hex(), foundPost.AuthorID.Hex())
+	}
+
+	page, err = store.List(ctx, storage.ListOptions{AuthorID: vishnu})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B", "C"}, false, false)
+
+	page, err = store.List(ctx, storage.ListOptions{AuthorID: shankar})
+	assertNil(t, err)
+	assertPage(t, page, []string{}, false, false)
+}
+
+// assertScheduled asserts that the scheduled posts are those with slugs, in
+// order
+func assertScheduled(t *testing.T, store storage.Storage, slugs ...string) []models.Post {
+	t.Helper()
+
+	scheduledPosts, err := store.Scheduled(context.Background())
+	assertNil(t, err)
+
+	var scheduledSlugs []string
+
+	for _, post := range scheduledPosts {
+		scheduledSlugs = append(scheduledSlugs, post.Slug)
+	}
+
+	if fmt.Sprint(scheduledSlugs) != fmt.Sprint(slugs) {
+		t.Errorf("Expected scheduled posts to be %v but got %v", slugs, scheduledSlugs)
+	}
+
+	return scheduledPosts
+}
+
+func (s *suite) testSchedule(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	at := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := at.Add(time.Hour)
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+	transition(t, store, testPostOne.Slug, models.StatusInReview)
+	transition(t, store, testPostTwo.Slug, models.StatusInReview)
+
+	scheduledPost, err := store.Schedule(ctx, testPostOne.Slug, storage.AnyVersion, &later)
+	assertNil(t, err)
+
+	if scheduledPost.PublishAt == nil || !scheduledPost.PublishAt.Equal(later) || scheduledPost.Status != models.StatusInReview {
+		t.Errorf("Expected post in review scheduled at %s but got %v", later, scheduledPost)
+	}
+
+	_, err = store.Schedule(ctx, testPostTwo.Slug, 1, &at)
+	assertNil(t, err)
+
+	scheduledPosts := assertScheduled(t, store, testPostTwo.Slug, testPostOne.Slug)
+
+	if len(scheduledPosts) == 2 && !scheduledPosts[0].PublishAt.Equal(at) {
+		t.Errorf("Expected stored publishAt to be %s but got %s", at, scheduledPosts[0].PublishAt)
+	}
+
+	// Scheduling again reschedules
+	_, err = store.Schedule(ctx, testPostTwo.Slug, storage.AnyVersion, &later)
+	assertNil(t, err)
+
+	assertScheduled(t, store, testPostOne.Slug, testPostTwo.Slug)
+
+	cancelledPost, err := store.Schedule(ctx, testPostOne.Slug, storage.AnyVersion, nil)
+	assertNil(t, err)
+
+	if cancelledPost.PublishAt != nil {
+		t.Errorf("Expected schedule to be cancelled but got %v", cancelledPost)
+	}
+
+	assertScheduled(t, store, testPostTwo.Slug)
+
+	// Transitions cancel the schedule
+	transition(t, store, testPostTwo.Slug, models.StatusDraft)
+
+	assertScheduled(t, store)
+
+	foundPost, err := store.Find(ctx, testPostTwo.Slug)
+	assertNil(t, err)
+
+	if foundPost.PublishAt != nil || foundPost.Version != 1 {
+		t.Errorf("Expected unscheduled post at version 1 but got %v", foundPost)
+	}
+}
+
+func (s *suite) testScheduleInvalid(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	at := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	// Only posts in review can be published
+	_, err := store.Schedule(ctx, testPost.Slug, storage.AnyVersion, &at)
+	assertError(t, err, storage.ErrInvalidTransition)
+
+	transition(t, store, testPost.Slug, models.StatusInReview)
+
+	_, err = store.Schedule(ctx, testPost.Slug, storage.AnyVersion, nil)
+	assertError(t, err, storage.ErrNotScheduled)
+
+	_, err = store.Schedule(ctx, testPost.Slug, 2, &at)
+	assertError(t, err, storage.ErrVersionConflict)
+
+	_, err = store.Schedule(ctx, "does-not-exist", storage.AnyVersion, &at)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	assertScheduled(t, store)
+}
+
+func (s *suite) testPublishDue(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	at := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, title := range []string{"A", "B", "C", "D"} {
+		insertAll(t, store, models.Post{Title: title, Name: "Bob", Content: "Hello world"})
+
+		slug := strings.ToLower(title)
+		publishAt := at.Add(time.Duration(i) * time.Hour)
+
+		transition(t, store, slug, models.StatusInReview)
+
+		_, err := store.Schedule(ctx, slug, storage.AnyVersion, &publishAt)
+		assertNil(t, err)
+	}
+
+	// Trashed posts are not published until they are restored
+	assertNil(t, store.Remove(ctx, "a", storage.AnyVersion))
+
+	publishedPosts, err := store.PublishDue(ctx, at.Add(-time.Minute))
+	assertNil(t, err)
+
+	if len(publishedPosts) != 0 {
+		t.Errorf("Expected no post to be due but got %v", publishedPosts)
+	}
+
+	publishedPosts, err = store.PublishDue(ctx, at.Add(time.Hour))
+	assertNil(t, err)
+
+	if len(publishedPosts) != 1 || publishedPosts[0].Slug != "b" {
+		t.Fatalf("Expected b to be published but got %v", publishedPosts)
+	}
+
+	// Posts are published as of the time they were scheduled for
+	foundPost, err := store.Find(ctx, "b")
+	assertNil(t, err)
+
+	if foundPost.Status != models.StatusPublished || foundPost.PublishAt != nil || foundPost.PublishedAt == nil || !foundPost.PublishedAt.Equal(at.Add(time.Hour)) {
+		t.Errorf("Expected post published at %s but got %v", at.Add(time.Hour), foundPost)
+	}
+
+	assertScheduled(t, store, "c", "d")
+
+	_, err = store.Restore(ctx, "a")
+	assertNil(t, err)
+
+	publishedPosts, err = store.PublishDue(ctx, at.Add(2*time.Hour))
+	assertNil(t, err)
+
+	if len(publishedPosts) != 2 || publishedPosts[0].Slug != "a" || publishedPosts[1].Slug != "c" {
+		t.Errorf("Expected a and c to be published but got %v", publishedPosts)
+	}
+
+	assertScheduled(t, store, "d")
+
+	page, err := store.List(ctx, storage.ListOptions{Status: models.StatusPublished})
+	assertNil(t, err)
+	assertPage(t, page, []string{"A", "B", "C"}, false, false)
+}
+
+func assertRevision(t *testing.T, revision models.Revision, number int, post models.Post) {
+	t.Helper()
+
+	if revision.Number != number || revision.Author != post.Name || revision.Title != post.Title || revision.Content != post.Content {
+		t.Errorf("Expected revision %d of %v but got %+v", number, post, revision)
+	}
+}
+
+func (s *suite) testRevisions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+
+	insertedPost, err := store.Insert(ctx, testPost)
+	assertNil(t, err)
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	_, err = store.Modify(ctx, testPost.Slug, storage.AnyVersion, testPostModify)
+	assertNil(t, err)
+
+	// Revisions follow the post when its slug changes
+	testPostRename := testPostModify
+	testPostRename.Slug = "hello-again"
+	testPostRename.Title = "Hello again"
+	testPostRename.Name = "Shankar"
+
+	renamedPost, err := store.Modify(ctx, testPost.Slug, storage.AnyVersion, testPostRename)
+	assertNil(t, err)
+
+	revisions, err := store.Revisions(ctx, testPostRename.Slug)
+	assertNil(t, err)
+
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions but got %d", len(revisions))
+	}
+
+	assertRevision(t, revisions[0], 1, testPost)
+	assertRevision(t, revisions[1], 2, testPostModify)
+	assertRevision(t, revisions[2], 3, testPostRename)
+
+	for _, revision := range revisions {
+		if revision.PostID != insertedPost.ID {
+			t.Errorf("Expected revision post ID to be %s but got %s", insertedPost.ID, revision.PostID)
+		}
+	}
+
+	if !revisions[0].CreatedAt.Equal(insertedPost.CreatedAt) || !revisions[2].CreatedAt.Equal(renamedPost.UpdatedAt) {
+		t.Errorf("Expected revisions to be created when the post was saved but got %+v", revisions)
+	}
+
+	revision, err := store.Revision(ctx, testPostRename.Slug, 2)
+	assertNil(t, err)
+	assertRevision(t, revision, 2, testPostModify)
+}
+
+func (s *suite) testRevisionDoesNotExist(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	_, err := store.Revision(ctx, testPost.Slug, 2)
+	assertError(t, err, storage.ErrRevisionDoesNotExist)
+
+	_, err = store.Revision(ctx, testPost.Slug, 0)
+	assertError(t, err, storage.ErrRevisionDoesNotExist)
+
+	_, err = store.RestoreRevision(ctx, testPost.Slug, 2)
+	assertError(t, err, storage.ErrRevisionDoesNotExist)
+
+	_, err = store.Revisions(ctx, "Nonexistent")
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Revision(ctx, "Nonexistent", 1)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.RestoreRevision(ctx, "Nonexistent", 1)
+	assertError(t, err, storage.ErrDoesNotExist)
+}
+
+func (s *suite) testRestoreRevision(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	testPostModify := testPost
+	testPostModify.Slug = "hello-again"
+	testPostModify.Title = "Hello again"
+	testPostModify.Content = "Golang is awesome!!"
+
+	_, err := store.Modify(ctx, testPost.Slug, storage.AnyVersion, testPostModify)
+	assertNil(t, err)
+
+	restoredPost, err := store.RestoreRevision(ctx, testPostModify.Slug, 1)
+	assertNil(t, err)
+
+	// Revisions hold the title and content, the slug stays the same
+	if !restoredPost.IsEqual(testPost) || restoredPost.Slug != testPostModify.Slug {
+		t.Errorf("Expected restored post to be %v but got %v", testPost, restoredPost)
+	}
+
+	assertFoundAs(t, store, testPostModify.Slug, testPost.Slug)
+
+	// Restoring records a new revision rather than rewriting history
+	revisions, err := store.Revisions(ctx, testPostModify.Slug)
+	assertNil(t, err)
+
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions but got %d", len(revisions))
+	}
+
+	assertRevision(t, revisions[1], 2, testPostModify)
+	assertRevision(t, revisions[2], 3, testPost)
+}
+
+func (s *suite) testPurgeRevisions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+	assertNil(t, store.Remove(ctx, testPost.Slug, storage.AnyVersion))
+
+	_, err := store.Purge(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	// A new post with the same title starts a new history
+	insertAll(t, store, testPost)
+
+	revisions, err := store.Revisions(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if len(revisions) != 1 {
+		t.Fatalf("Expected 1 revision but got %d", len(revisions))
+	}
+
+	assertRevision(t, revisions[0], 1, testPost)
+}
+
+func (s *suite) testVersion(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+
+	insertedPost, err := store.Insert(ctx, testPost)
+	assertNil(t, err)
+
+	if insertedPost.Version != 1 {
+		t.Errorf("Expected inserted post version to be 1 but got %d", insertedPost.Version)
+	}
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	modifiedPost, err := store.Modify(ctx, testPost.Slug, 1, testPostModify)
+	assertNil(t, err)
+
+	modifiedPost, err = store.Modify(ctx, testPost.Slug, storage.AnyVersion, modifiedPost)
+	assertNil(t, err)
+
+	if modifiedPost.Version != 3 {
+		t.Errorf("Expected modified post version to be 3 but got %d", modifiedPost.Version)
+	}
+
+	foundPost, err := store.Find(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if foundPost.Version != 3 {
+		t.Errorf("Expected stored post version to be 3 but got %d", foundPost.Version)
+	}
+
+	assertNil(t, store.Remove(ctx, testPost.Slug, 3))
+}
+
+func (s *suite) testVersionConflict(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPost, _, _ := testPosts()
+	insertAll(t, store, testPost)
+
+	testPostModify := testPost
+	testPostModify.Content = "Golang is awesome!!"
+
+	_, err := store.Modify(ctx, testPost.Slug, 1, testPostModify)
+	assertNil(t, err)
+
+	// A second editor still holding version 1 must not clobber the change
+	testPostStale := testPost
+	testPostStale.Content = "This is golang!!"
+
+	_, err = store.Modify(ctx, testPost.Slug, 1, testPostStale)
+	assertError(t, err, storage.ErrVersionConflict)
+
+	err = store.Remove(ctx, testPost.Slug, 1)
+	assertError(t, err, storage.ErrVersionConflict)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostModify})
+
+	revisions, err := store.Revisions(ctx, testPost.Slug)
+	assertNil(t, err)
+
+	if len(revisions) != 2 {
+		t.Errorf("Expected a conflict to not add a revision but got %d revisions", len(revisions))
+	}
+
+	_, err = store.Modify(ctx, "Nonexistent", 1, testPostModify)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	err = store.Remove(ctx, "Nonexistent", 1)
+	assertError(t, err, storage.ErrDoesNotExist)
+}
+
+func assertTrash(t *testing.T, store storage.Storage, titles ...string) {
+	t.Helper()
+
+	trashedPosts, err := store.Trash(context.Background())
+	assertNil(t, err)
+
+	if len(trashedPosts) != len(titles) {
+		t.Fatalf("Expected %d posts in trash but got %d", len(titles), len(trashedPosts))
+	}
+
+	for i, title := range titles {
+		if trashedPosts[i].Title != title || trashedPosts[i].DeletedAt == nil {
+			t.Errorf("Expected index %d of trash to be %s but got %v", i, title, trashedPosts[i])
+		}
+	}
+}
+
+func (s *suite) testTrash(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, testPostThree := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo, testPostThree)
+
+	assertNil(t, store.Remove(ctx, testPostTwo.Slug, storage.AnyVersion))
+	time.Sleep(2 * time.Millisecond)
+	assertNil(t, store.Remove(ctx, testPostOne.Slug, storage.AnyVersion))
+
+	// Most recently removed first
+	assertTrash(t, store, testPostOne.Title, testPostTwo.Title)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostThree})
+
+	page, err := store.List(ctx, storage.ListOptions{})
+	assertNil(t, err)
+	assertPage(t, page, []string{testPostThree.Title}, false, false)
+
+	_, err = store.Find(ctx, testPostOne.Slug)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostOne)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Revisions(ctx, testPostOne.Slug)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	err = store.Remove(ctx, testPostOne.Slug, storage.AnyVersion)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	// Trashed posts keep their slug until purged
+	_, err = store.Insert(ctx, testPostOne)
+	assertError(t, err, storage.ErrAlreadyExists)
+
+	testPostRename := testPostThree
+	testPostRename.Slug = testPostOne.Slug
+
+	_, err = store.Modify(ctx, testPostThree.Slug, storage.AnyVersion, testPostRename)
+	assertError(t, err, storage.ErrAlreadyExists)
+}
+
+func (s *suite) testRestore(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	testPostModify := testPostOne
+	testPostModify.Content = "Golang is awesome!!"
+
+	modifiedPost, err := store.Modify(ctx, testPostOne.Slug, storage.AnyVersion, testPostModify)
+	assertNil(t, err)
+
+	assertNil(t, store.Remove(ctx, testPostOne.Slug, storage.AnyVersion))
+
+	restoredPost, err := store.Restore(ctx, testPostOne.Slug)
+	assertNil(t, err)
+
+	if !restoredPost.IsEqual(testPostModify) || restoredPost.DeletedAt != nil || restoredPost.Version != modifiedPost.Version {
+		t.Errorf("Expected restored post to be %v but got %v", modifiedPost, restoredPost)
+	}
+
+	assertTrash(t, store)
+
+	// Restored posts keep their position and history
+	s.assertPostsSlice(t, store, []models.Post{testPostModify, testPostTwo})
+
+	revisions, err := store.Revisions(ctx, testPostOne.Slug)
+	assertNil(t, err)
+
+	if len(revisions) != 2 {
+		t.Errorf("Expected 2 revisions but got %d", len(revisions))
+	}
+
+	// Only trashed posts can be restored
+	_, err = store.Restore(ctx, testPostTwo.Slug)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Restore(ctx, "Nonexistent")
+	assertError(t, err, storage.ErrDoesNotExist)
+}
+
+func (s *suite) testPurge(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, _ := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo)
+
+	foundPost, err := store.Find(ctx, testPostOne.Slug)
+	assertNil(t, err)
+
+	assertNil(t, store.Remove(ctx, testPostOne.Slug, storage.AnyVersion))
+
+	// The ID of the purged post is returned to delete what belongs to it
+	id, err := store.Purge(ctx, testPostOne.Slug)
+	assertNil(t, err)
+
+	if id != foundPost.ID {
+		t.Errorf("Expected the ID of the purged post %s but got %s", foundPost.ID.Hex(), id.Hex())
+	}
+
+	assertTrash(t, store)
+
+	_, err = store.Restore(ctx, testPostOne.Slug)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	// Only trashed posts can be purged
+	_, err = store.Purge(ctx, testPostTwo.Slug)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	_, err = store.Purge(ctx, testPostOne.Slug)
+	assertError(t, err, storage.ErrDoesNotExist)
+
+	s.assertPostsSlice(t, store, []models.Post{testPostTwo})
+}
+
+func (s *suite) testPurgeDeletedBefore(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	testPostOne, testPostTwo, testPostThree := testPosts()
+	insertAll(t, store, testPostOne, testPostTwo, testPostThree)
+
+	expiredPost, err := store.Find(ctx, testPostOne.Slug)
+	assertNil(t, err)
+
+	assertNil(t, store.Remove(ctx, testPostOne.Slug, storage.AnyVersion))
+	time.Sleep(2 * time.Millisecond)
+
+	before := time.Now()
+
+	time.Sleep(2 * time.Millisecond)
+	assertNil(t, store.Remove(ctx, testPostTwo.Slug, storage.AnyVersion))
+
+	purged, err := store.PurgeDeletedBefore(ctx, before)
+	assertNil(t, err)
+
+	if len(purged) != 1 || purged[0] != expiredPost.ID {
+		t.Errorf("Expected post %s to be purged but got %v", expiredPost.ID.Hex(), purged)
+	}
+
+	assertTrash(t, store, testPostTwo.Title)
+	s.assertPostsSlice(t, store, []models.Post{testPostThree})
+
+	// The purged title is free again
+	insertAll(t, store, testPostOne)
+}
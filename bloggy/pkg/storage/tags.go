@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"examples/bloggy/pkg/models"
+	"examples/bloggy/pkg/slug"
+)
+
+var ErrInvalidTag = errors.New("storage: invalid tag or category")
+
+// Facet is a tag or category along with the number of posts that have it
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// normalizeTag lowercases tag, which must then be in slug form
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	if !slug.Valid(tag) {
+		return "", ErrInvalidTag
+	}
+
+	return tag, nil
+}
+
+// normalizeTags lowercases, sorts and deduplicates tags. It never returns
+// nil so that posts without tags have an empty list in every backend
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		normalized = append(normalized, tag)
+	}
+
+	sort.Strings(normalized)
+
+	unique := normalized[:0]
+
+	for i, tag := range normalized {
+		if i == 0 || tag != normalized[i-1] {
+			unique = append(unique, tag)
+		}
+	}
+
+	return unique, nil
+}
+
+// normalizeTaxonomy normalizes the tags and category of post, returning
+// ErrInvalidTag if any of them is not valid. No category is valid
+func normalizeTaxonomy(post models.Post) (models.Post, error) {
+	tags, err := normalizeTags(post.Tags)
+
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post.Tags = tags
+
+	if strings.TrimSpace(post.Category) == "" {
+		post.Category = ""
+		return post, nil
+	}
+
+	post.Category, err = normalizeTag(post.Category)
+
+	return post, err
+}
+
+// hasTag reports whether tags, which are sorted, hold tag
+func hasTag(tags []string, tag string) bool {
+	i := sort.SearchStrings(tags, tag)
+
+	return i < len(tags) && tags[i] == tag
+}
+
+// hasAnyTag reports whether tags hold any of from
+func hasAnyTag(tags []string, from []string) bool {
+	for _, tag := range from {
+		if hasTag(tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renamedTags replaces every tag of from in tags with to, merging them if
+// tags hold several
+func renamedTags(tags []string, from []string, to string) []string {
+	renamed := []string{to}
+
+	for _, tag := range tags {
+		if !hasTag(from, tag) {
+			renamed = append(renamed, tag)
+		}
+	}
+
+	// Normalized tags are valid, normalizing only sorts and deduplicates
+	renamed, _ = normalizeTags(renamed)
+
+	return renamed
+}
+
+// parseRename normalizes the arguments of RenameTags. from is returned
+// sorted so it can be searched with hasTag and without to, so that only
+// posts whose tags change match it
+func parseRename(from []string, to string) ([]string, string, error) {
+	if len(from) == 0 {
+		return nil, "", ErrInvalidTag
+	}
+
+	from, err := normalizeTags(from)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	to, err = normalizeTag(to)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	others := from[:0]
+
+	for _, tag := range from {
+		if tag != to {
+			others = append(others, tag)
+		}
+	}
+
+	return others, to, nil
+}
+
+// sortFacets orders facets by count, most used first, and then by value
+func sortFacets(counts map[string]int) []Facet {
+	facets := make([]Facet, 0, len(counts))
+
+	for value, count := range counts {
+		facets = append(facets, Facet{value, count})
+	}
+
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+
+		return facets[i].Value < facets[j].Value
+	})
+
+	return facets
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+// trashedPost marks post as moved to the trash now
+func trashedPost(post models.Post) models.Post {
+	t := now()
+	post.DeletedAt = &t
+
+	return post
+}
+
+// sortTrash orders trashed posts most recently deleted first, ties are
+// broken by slug
+func sortTrash(posts []models.Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+
+		if a.DeletedAt.Equal(*b.DeletedAt) {
+			return a.Slug < b.Slug
+		}
+
+		return a.DeletedAt.After(*b.DeletedAt)
+	})
+}
+
+// purgeComments deletes the comments of the purged posts with ids
+func purgeComments(ctx context.Context, comments CommentStorage, ids []primitive.ObjectID) error {
+	for _, id := range ids {
+		err := comments.RemovePost(ctx, id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently deletes posts that have been in the trash for
+// longer than retention along with their comments, checking every interval
+// until ctx is done
+func PurgeTrash(ctx context.Context, s Storage, comments CommentStorage, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := s.PurgeDeletedBefore(ctx, now().Add(-retention))
+
+		if err == nil {
+			err = purgeComments(ctx, comments, ids)
+		}
+
+		if err != nil && ctx.Err() == nil {
+			log.Printf("ERROR Unable to purge trash: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"examples/bloggy/pkg/models"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	store := CreateMemoryStore()
+	comments := CreateMemoryCommentStore()
+
+	insert(t, store, models.Post{Title: "Hello", Name: "Vishnu", Content: "Hello world"})
+	insert(t, store, models.Post{Title: "World", Name: "Shankar", Content: "This is golang!!"})
+
+	// Comments of purged posts are deleted along with them
+	for _, slug := range []string{"hello", "world"} {
+		post, err := store.Find(context.Background(), slug)
+		assertNil(t, err)
+
+		_, err = comments.Insert(context.Background(), models.Comment{PostID: post.ID, Name: "Reader", Content: "Nice post"})
+		assertNil(t, err)
+	}
+
+	assertNil(t, store.Remove(context.Background(), "hello", AnyVersion))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		PurgeTrash(ctx, store, comments, time.Millisecond, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		trashedPosts, err := store.Trash(context.Background())
+		assertNil(t, err)
+
+		if len(trashedPosts) == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Expected trash to be purged")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	assertCount(t, store, 1)
+
+	world, err := store.Find(context.Background(), "world")
+	assertNil(t, err)
+
+	left, err := comments.List(context.Background(), primitive.NilObjectID, "")
+	assertNil(t, err)
+
+	if len(left) != 1 || left[0].PostID != world.ID {
+		t.Errorf("Expected only the comments of the post left to be kept but got %v", left)
+	}
+}
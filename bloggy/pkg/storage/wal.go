@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"examples/bloggy/pkg/models"
+)
+
+// Every record in the log and snapshot files is framed as a little endian
+// uint32 payload length, a uint32 CRC-32C checksum of the payload and the
+// BSON encoded payload itself
+const walHeaderSize = 8
+
+// Records are single BSON documents which are limited to 16MB
+const walMaxRecordSize = 16 * 1024 * 1024
+
+const (
+	walSnapshotFile = "snapshot"
+	walLogFile      = "wal"
+)
+
+const (
+	walOpPut      = "put"
+	walOpDelete   = "delete"
+	walOpClean    = "clean"
+	walOpRevision = "revision"
+	walOpAlias    = "alias"
+
+	// The snapshot and the log each start with their generation, which
+	// compaction increments
+	walOpGeneration = "generation"
+)
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errWALCorrupt = errors.New("storage: corrupt write-ahead log record")
+
+// errWALTorn reports a bad record that nothing follows, as left behind by a
+// crash in the middle of appending it
+var errWALTorn = errors.New("storage: torn write-ahead log record")
+
+// ErrWALLocked is returned when opening a write-ahead log another process
+// has open
+var ErrWALLocked = errors.New("storage: write-ahead log is in use by another process")
+
+// ErrReadOnly is returned when changing a store opened read-only
+var ErrReadOnly = errors.New("storage: store is read-only")
+
+// walRecord is a logged change. Puts carry the revision they create so that
+// the post and its revision are stored atomically. Aliases are only written
+// by compaction, puts that change the slug of a post imply them. Logs of
+// author stores put authors instead of posts. Generation records only carry
+// the generation of the snapshot or log they start
+type walRecord struct {
+	Op       string           `bson:"op"`
+	Key      string           `bson:"key,omitempty"`
+	Post     models.Post      `bson:"post"`
+	Revision *models.Revision `bson:"revision,omitempty"`
+	Target   string           `bson:"target,omitempty"`
+	Author   *models.Author   `bson:"author,omitempty"`
+	Comment  *models.Comment  `bson:"comment,omitempty"`
+
+	Generation uint64 `bson:"generation,omitempty"`
+}
+
+// writeAheadLog is of the generation of the snapshot it follows. A log of
+// an older generation is already held by the snapshot, as it is when
+// compaction is cut short before emptying the log, and is not replayed.
+// Logs and snapshots written before there were generations are of
+// generation zero
+type writeAheadLog struct {
+	dir          string
+	file         *os.File
+	appended     int
+	compactEvery int
+	readOnly     bool
+	generation   uint64
+
+	// stale is set while the log is of an older generation than the
+	// snapshot, it is emptied before anything is appended
+	stale bool
+}
+
+func encodeWALRecord(rec walRecord) ([]byte, error) {
+	payload, err := bson.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, walCRCTable))
+	copy(frame[walHeaderSize:], payload)
+
+	return frame, nil
+}
+
+// badWALRecord reports a record that does not check out as errWALTorn if
+// it is the last one of r, errWALCorrupt if more follows it
+func badWALRecord(r io.Reader) error {
+	_, err := io.ReadFull(r, make([]byte, 1))
+
+	if err == io.EOF {
+		return errWALTorn
+	}
+
+	return errWALCorrupt
+}
+
+// readWALRecords decodes framed records from r until EOF and returns the
+// offset just past the last intact record. A record cut short or the last
+// one not checking out stops reading and is reported as errWALTorn, any
+// other record not checking out as errWALCorrupt
+func readWALRecords(r io.Reader, apply func(walRecord)) (int64, error) {
+	var offset int64
+	header := make([]byte, walHeaderSize)
+
+	for {
+		_, err := io.ReadFull(r, header)
+
+		if err == io.EOF {
+			return offset, nil
+		}
+
+		if err != nil {
+			return offset, errWALTorn
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+
+		if size > walMaxRecordSize {
+			return offset, badWALRecord(r)
+		}
+
+		payload := make([]byte, size)
+
+		_, err = io.ReadFull(r, payload)
+
+		if err != nil {
+			return offset, errWALTorn
+		}
+
+		if crc32.Checksum(payload, walCRCTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			return offset, badWALRecord(r)
+		}
+
+		var rec walRecord
+
+		err = bson.Unmarshal(payload, &rec)
+
+		if err != nil {
+			return offset, badWALRecord(r)
+		}
+
+		apply(rec)
+
+		offset += int64(walHeaderSize + len(payload))
+	}
+}
+
+// replayWALSnapshot replays the snapshot found in dir through apply, if
+// any, and returns its generation. It is replaced atomically so it is never
+// torn
+func replayWALSnapshot(dir string, apply func(walRecord)) (uint64, error) {
+	snapshot, err := os.Open(filepath.Join(dir, walSnapshotFile))
+
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer snapshot.Close()
+
+	var generation uint64
+
+	_, err = readWALRecords(snapshot, func(rec walRecord) {
+		if rec.Op == walOpGeneration {
+			generation = rec.Generation
+			return
+		}
+
+		apply(rec)
+	})
+
+	if err == errWALTorn {
+		err = errWALCorrupt
+	}
+
+	return generation, err
+}
+
+// replayWALLog replays the records of the log in r through apply unless the
+// log is of an older generation than the snapshot, the first record of a
+// log without one is of generation zero. It returns the number of
+// records replayed, whether the log is stale and the offset just past the
+// last intact record, like readWALRecords
+func replayWALLog(r io.Reader, generation uint64, apply func(walRecord)) (int, bool, int64, error) {
+	first := true
+	stale := false
+	replayed := 0
+
+	offset, err := readWALRecords(r, func(rec walRecord) {
+		if first {
+			first = false
+			stale = rec.Generation < generation
+		}
+
+		if rec.Op == walOpGeneration || stale {
+			return
+		}
+
+		replayed++
+		apply(rec)
+	})
+
+	return replayed, stale, offset, err
+}
+
+// openWriteAheadLog replays the snapshot and log found in dir through apply
+// and opens the log for appending, locking it so that no other process
+// opens it meanwhile. A torn final log record, left behind by a crash in the
+// middle of a write, is truncated away, any other corruption is an error
+func openWriteAheadLog(dir string, compactEvery int, apply func(walRecord)) (*writeAheadLog, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, walLogFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock before replaying, compaction by another process could change
+	// the snapshot and log in between
+	var generation uint64
+
+	err = lockFile(file, true)
+
+	if err == nil {
+		generation, err = replayWALSnapshot(dir, apply)
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Replay log
+	appended, stale, offset, err := replayWALLog(file, generation, apply)
+
+	if err == errWALTorn {
+		err = file.Truncate(offset)
+	}
+
+	if err == nil {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+
+	w := &writeAheadLog{dir: dir, file: file, appended: appended, compactEvery: compactEvery, generation: generation, stale: stale}
+
+	// New logs start with their generation
+	if err == nil && (stale || offset == 0) {
+		err = w.reset()
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// openReadOnlyWriteAheadLog replays the snapshot and log found in dir
+// through apply like openWriteAheadLog, but leaves them as they are. A torn
+// final log record is skipped rather than truncated. The log is locked
+// shared, so that processes writing it can not open it meanwhile, and must
+// exist as it does in every dir a write-ahead log was opened in
+func openReadOnlyWriteAheadLog(dir string, apply func(walRecord)) (*writeAheadLog, error) {
+	file, err := os.Open(filepath.Join(dir, walLogFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var generation uint64
+
+	err = lockFile(file, false)
+
+	if err == nil {
+		generation, err = replayWALSnapshot(dir, apply)
+	}
+
+	if err == nil {
+		_, _, _, err = replayWALLog(file, generation, apply)
+	}
+
+	if err == errWALTorn {
+		err = nil
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &writeAheadLog{dir: dir, file: file, readOnly: true}, nil
+}
+
+// reset empties the log, leaving only its generation
+func (w *writeAheadLog) reset() error {
+	frame, err := encodeWALRecord(walRecord{Op: walOpGeneration, Generation: w.generation})
+	if err != nil {
+		return err
+	}
+
+	err = w.file.Truncate(0)
+
+	if err == nil {
+		_, err = w.file.Seek(0, io.SeekStart)
+	}
+
+	if err == nil {
+		_, err = w.file.Write(frame)
+	}
+
+	if err == nil {
+		err = w.file.Sync()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.appended = 0
+	w.stale = false
+
+	return nil
+}
+
+func (w *writeAheadLog) append(rec walRecord) error {
+	if w.readOnly {
+		return ErrReadOnly
+	}
+
+	// Records appended to a stale log would not be replayed
+	if w.stale {
+		err := w.reset()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	frame, err := encodeWALRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	start, err := w.file.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.file.Write(frame)
+
+	if err != nil {
+		// Drop the partial record so records appended later are still replayed
+		truncateErr := w.file.Truncate(start)
+
+		if truncateErr == nil {
+			_, truncateErr = w.file.Seek(start, io.SeekStart)
+		}
+
+		if truncateErr != nil {
+			return fmt.Errorf("%w, dropping the partial record failed: %s", err, truncateErr)
+		}
+
+		return err
+	}
+
+	w.appended++
+
+	return w.file.Sync()
+}
+
+func (w *writeAheadLog) compactionDue() bool {
+	return w.compactEvery > 0 && w.appended >= w.compactEvery
+}
+
+// compact writes recs as the snapshot of the next generation and empties
+// the log. The log is stale from when the snapshot is in place until it is
+// emptied
+func (w *writeAheadLog) compact(recs []walRecord) error {
+	tmpPath := filepath.Join(w.dir, walSnapshotFile+".tmp")
+
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	recs = append([]walRecord{{Op: walOpGeneration, Generation: w.generation + 1}}, recs...)
+
+	for _, rec := range recs {
+		var frame []byte
+
+		frame, err = encodeWALRecord(rec)
+
+		if err == nil {
+			_, err = tmp.Write(frame)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(w.dir, walSnapshotFile))
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	w.generation++
+	w.stale = true
+
+	err = syncDir(w.dir)
+
+	if err != nil {
+		return err
+	}
+
+	// Snapshot is durable, records in the log are no longer needed
+	return w.reset()
+}
+
+func (w *writeAheadLog) close() error {
+	return w.file.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+
+	return d.Sync()
+}